@@ -0,0 +1,227 @@
+// Package availability keeps listings' availability calendars: dated windows
+// that open or block move-in dates for a whole apartment or one unit. The
+// Availability column of apartments and units that have windows is derived
+// from today's calendar by Sync.
+package availability
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+
+	"gorm.io/gorm"
+)
+
+// Window kinds
+const (
+	KindAvailable = "available"
+	KindBlocked   = "blocked"
+)
+
+// DateLayout is how window dates and ?move_in= are written.
+const DateLayout = "2006-01-02"
+
+// Day truncates t to its calendar date.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Today is the current calendar date.
+func Today() time.Time {
+	return Day(time.Now())
+}
+
+func covers(w model.AvailabilityWindow, day time.Time) bool {
+	return !Day(w.StartDate).After(day) && (w.EndDate == nil || !Day(*w.EndDate).Before(day))
+}
+
+// OpenOn reports whether the windows of one apartment let a tenant move in on
+// day. A blocked window for the whole apartment closes every unit; a blocked
+// window for a unit closes only that unit.
+func OpenOn(windows []model.AvailabilityWindow, day time.Time) bool {
+	for _, w := range windows {
+		if w.Kind != KindAvailable || !covers(w, day) {
+			continue
+		}
+		blocked := false
+		for _, b := range windows {
+			if b.Kind == KindBlocked && covers(b, day) &&
+				(b.UnitID == nil || (w.UnitID != nil && *b.UnitID == *w.UnitID)) {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen is the first day from from onwards that OpenOn allows, or nil.
+// Only the days where a window starts or a block ends can change the answer,
+// so those are the only ones tried.
+func NextOpen(windows []model.AvailabilityWindow, from time.Time) *time.Time {
+	from = Day(from)
+	candidates := []time.Time{from}
+	for _, w := range windows {
+		switch {
+		case w.Kind == KindAvailable && Day(w.StartDate).After(from):
+			candidates = append(candidates, Day(w.StartDate))
+		case w.Kind == KindBlocked && w.EndDate != nil && !Day(*w.EndDate).Before(from):
+			candidates = append(candidates, Day(*w.EndDate).AddDate(0, 0, 1))
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	for _, day := range candidates {
+		if OpenOn(windows, day) {
+			return &day
+		}
+	}
+	return nil
+}
+
+// NextAvailable is the first day a tenant can move into apt. Apartments
+// without windows use their Availability column and ExpiresAt.
+func NextAvailable(apt model.Apartment, windows []model.AvailabilityWindow) *time.Time {
+	if len(windows) > 0 {
+		return NextOpen(windows, Today())
+	}
+	if apt.Availability != "Available" || (apt.ExpiresAt != nil && apt.ExpiresAt.Before(time.Now())) {
+		return nil
+	}
+	today := Today()
+	return &today
+}
+
+// Upcoming returns the windows of the given apartments that have not ended,
+// earliest first.
+func Upcoming(db *gorm.DB, apartmentIDs []uint) ([]model.AvailabilityWindow, error) {
+	var windows []model.AvailabilityWindow
+	err := db.Where("apartment_id IN ? AND (end_date IS NULL OR end_date >= ?)", apartmentIDs, Today().Format(DateLayout)).
+		Order("start_date, id").
+		Find(&windows).Error
+	return windows, err
+}
+
+// openSQL is OpenOn in SQL for the apartments row, with day a date
+// expression.
+func openSQL(day string) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM availability_windows w
+		WHERE w.apartment_id = apartments.id AND w.kind = 'available'
+		AND w.start_date <= %[1]s AND (w.end_date IS NULL OR w.end_date >= %[1]s)
+		AND NOT EXISTS (SELECT 1 FROM availability_windows b
+			WHERE b.apartment_id = w.apartment_id AND b.kind = 'blocked'
+			AND (b.unit_id IS NULL OR b.unit_id = w.unit_id)
+			AND b.start_date <= %[1]s AND (b.end_date IS NULL OR b.end_date >= %[1]s)))`, day)
+}
+
+const hasWindowsSQL = "EXISTS (SELECT 1 FROM availability_windows WHERE availability_windows.apartment_id = apartments.id)"
+
+// MoveInSQL matches apartments a tenant can move into on the date named
+// @move_in. Apartments without windows match while they are listed as
+// Available and have not expired by then.
+var MoveInSQL = "(" + openSQL("CAST(@move_in AS date)") + " OR (NOT " + hasWindowsSQL +
+	" AND apartments.availability = 'Available'" +
+	" AND (apartments.expires_at IS NULL OR apartments.expires_at::date >= CAST(@move_in AS date))))"
+
+// unitOpenSQL is whether the apartment_units row is open today by its own
+// windows and not blocked for the whole apartment.
+const unitOpenSQL = `EXISTS (SELECT 1 FROM availability_windows w
+		WHERE w.unit_id = apartment_units.id AND w.kind = 'available'
+		AND w.start_date <= CURRENT_DATE AND (w.end_date IS NULL OR w.end_date >= CURRENT_DATE))
+	AND NOT EXISTS (SELECT 1 FROM availability_windows b
+		WHERE b.apartment_id = apartment_units.apartment_id AND b.kind = 'blocked'
+		AND (b.unit_id IS NULL OR b.unit_id = apartment_units.id)
+		AND b.start_date <= CURRENT_DATE AND (b.end_date IS NULL OR b.end_date >= CURRENT_DATE))`
+
+// unitFullSQL is whether confirmed, active agreements fill the unit.
+const unitFullSQL = `(SELECT COUNT(*) FROM rental_agreements
+		WHERE rental_agreements.unit_id = apartment_units.id AND rental_agreements.is_active
+		AND rental_agreements.tenant_confirmed AND rental_agreements.landlord_confirmed) >= apartment_units.capacity`
+
+// Sync brings the Availability column of approved apartments and of units
+// with windows in line with today's calendar, for one apartment or for all
+// when apartmentID is 0. It returns the apartments that just opened so
+// callers can alert tenants.
+func Sync(db *gorm.DB, apartmentID uint) (opened []uint, err error) {
+	windowed := func() *gorm.DB {
+		query := db.Model(&model.Apartment{}).Where("apartments.status = ? AND "+hasWindowsSQL, "Approved")
+		if apartmentID != 0 {
+			query = query.Where("apartments.id = ?", apartmentID)
+		}
+		return query
+	}
+
+	if err := windowed().Where("apartments.availability <> ? AND "+openSQL("CURRENT_DATE"), "Available").
+		Pluck("apartments.id", &opened).Error; err != nil {
+		return nil, err
+	}
+	if len(opened) > 0 {
+		if err := db.Model(&model.Apartment{}).Where("id IN ?", opened).
+			Update("availability", "Available").Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := windowed().Where("apartments.availability = ? AND NOT "+openSQL("CURRENT_DATE"), "Available").
+		Update("availability", "Not Available").Error; err != nil {
+		return nil, err
+	}
+
+	units := db.Model(&model.ApartmentUnit{}).
+		Where("EXISTS (SELECT 1 FROM availability_windows WHERE availability_windows.unit_id = apartment_units.id)")
+	if apartmentID != 0 {
+		units = units.Where("apartment_units.apartment_id = ?", apartmentID)
+	}
+	err = units.Update("availability", gorm.Expr("CASE WHEN "+unitOpenSQL+" AND NOT "+unitFullSQL+
+		" THEN 'Available' ELSE 'Not Available' END")).Error
+	return opened, err
+}
+
+// cutAt ends the apartment's windows of kind that cover today, so they stop
+// yesterday. Windows starting today are removed.
+func cutAt(tx *gorm.DB, apartmentID uint, kind string, today time.Time) error {
+	todayStr := today.Format(DateLayout)
+	current := func() *gorm.DB {
+		return tx.Model(&model.AvailabilityWindow{}).
+			Where("apartment_id = ? AND kind = ? AND start_date <= ? AND (end_date IS NULL OR end_date >= ?)",
+				apartmentID, kind, todayStr, todayStr)
+	}
+	if err := current().Where("start_date = ?", todayStr).Delete(&model.AvailabilityWindow{}).Error; err != nil {
+		return err
+	}
+	return current().Update("end_date", today.AddDate(0, 0, -1).Format(DateLayout)).Error
+}
+
+// OpenNow is the "Available" shortcut: it lifts the apartment's blocks
+// covering today and opens the whole apartment from today until until.
+func OpenNow(tx *gorm.DB, apartmentID uint, until time.Time) error {
+	today := Today()
+	if err := cutAt(tx, apartmentID, KindBlocked, today); err != nil {
+		return err
+	}
+	end := Day(until)
+
+	// Setting Available again should not pile up identical windows
+	var covered int64
+	if err := tx.Model(&model.AvailabilityWindow{}).
+		Where("apartment_id = ? AND unit_id IS NULL AND kind = ? AND start_date <= ? AND (end_date IS NULL OR end_date >= ?)",
+			apartmentID, KindAvailable, today.Format(DateLayout), end.Format(DateLayout)).
+		Count(&covered).Error; err != nil || covered > 0 {
+		return err
+	}
+	return tx.Create(&model.AvailabilityWindow{
+		ApartmentID: apartmentID,
+		Kind:        KindAvailable,
+		StartDate:   today,
+		EndDate:     &end,
+	}).Error
+}
+
+// CloseNow is the "Not Available" shortcut: it ends every open window of the
+// apartment at yesterday. Windows that start later are kept.
+func CloseNow(tx *gorm.DB, apartmentID uint) error {
+	return cutAt(tx, apartmentID, KindAvailable, Today())
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
@@ -20,8 +22,20 @@ func InitCloudinary() {
 	}
 }
 
+// isRemoteSource reports whether source is something Cloudinary should fetch
+// itself: an http(s) URL or a data URI. Anything else would be read as a
+// path on this server.
+func isRemoteSource(source string) bool {
+	lower := strings.ToLower(strings.TrimSpace(source))
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "data:")
+}
+
 // Upload image to Cloudinary
 func UploadImage(filePath string) (string, error) {
+	if !isRemoteSource(filePath) {
+		return "", fmt.Errorf("image must be an http(s) URL or a data URI; upload files through /media/uploads")
+	}
+
 	// Upload image to Cloudinary
 	resp, err := cld.Upload.Upload(context.Background(), filePath, uploader.UploadParams{
 		ResourceType: "image", // Specify image type
@@ -37,6 +51,10 @@ func UploadImage(filePath string) (string, error) {
 
 // Upload video to Cloudinary
 func UploadVideo(filePath string) (string, error) {
+	if !isRemoteSource(filePath) {
+		return "", fmt.Errorf("video must be an http(s) URL or a data URI; upload files through /media/uploads")
+	}
+
 	// Upload video to Cloudinary
 	resp, err := cld.Upload.Upload(context.Background(), filePath, uploader.UploadParams{
 		ResourceType: "video", // Specify video type
@@ -49,3 +67,19 @@ func UploadVideo(filePath string) (string, error) {
 	return resp.SecureURL, nil
 }
 
+// UploadStream streams r to Cloudinary as resourceType ("image", "video" or
+// "raw") into folder, without reading it into memory first. It returns the
+// secure URL and the public ID of the stored asset.
+func UploadStream(ctx context.Context, r io.Reader, resourceType, folder string) (string, string, error) {
+	resp, err := cld.Upload.Upload(ctx, r, uploader.UploadParams{
+		ResourceType: resourceType,
+		Folder:       folder,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload %s to Cloudinary: %v", resourceType, err)
+	}
+	if resp.Error.Message != "" {
+		return "", "", fmt.Errorf("failed to upload %s to Cloudinary: %s", resourceType, resp.Error.Message)
+	}
+	return resp.SecureURL, resp.PublicID, nil
+}
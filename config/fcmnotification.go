@@ -13,10 +13,13 @@ import (
 
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
+	"github.com/Conding-Student/backend/pagination"
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Message Structures
@@ -47,8 +50,9 @@ var (
 	projectID       string
 )
 
-// Initialization
-func init() {
+// InitFirestore connects the Firestore client that stores device tokens and
+// notification logs. Until it runs, e.g. in tests, pushes are skipped.
+func InitFirestore() {
 	initializeFirebase()
 }
 
@@ -131,6 +135,10 @@ func extractProjectID(serviceAccountPath string) string {
 
 // Core Functions
 func SendPushNotification(fcmToken, title, body, conversationId, senderId string) {
+	if firestoreClient == nil {
+		log.Printf("Firestore is not initialized, skipping push %q", title)
+		return
+	}
 	ctx := context.Background()
 
 	// Always send the push notification
@@ -169,6 +177,33 @@ func SendPushNotification(fcmToken, title, body, conversationId, senderId string
 	}()
 }
 
+// SendPushToUser sends a push to every device token stored for uid in the
+// user_tokens collection. A user without tokens is not an error.
+func SendPushToUser(uid, title, body string) error {
+	if firestoreClient == nil {
+		log.Printf("Firestore is not initialized, skipping push %q to %s", title, uid)
+		return nil
+	}
+	doc, err := firestoreClient.Collection("user_tokens").Doc(uid).Get(context.Background())
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var data struct {
+		Tokens []string `firestore:"tokens"`
+	}
+	if err := doc.DataTo(&data); err != nil {
+		return err
+	}
+	for _, token := range data.Tokens {
+		SendPushNotification(token, title, body, "general", "")
+	}
+	return nil
+}
+
 func sendPushOnly(fcmToken, title, body, conversationId, senderId string) {
 	ctx := context.Background()
 
@@ -292,14 +327,41 @@ func GetNotificationsHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	page, err := pagination.Parse(c, pagination.Sorts{
+		Fields: map[string]pagination.Field{
+			"created_at": {Type: pagination.TypeTime, Desc: true},
+		},
+		Default: "created_at",
+		ID:      pagination.Field{Type: pagination.TypeText},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	ctx := context.Background()
 
-	var notifications []NotificationLog
+	type notificationRow struct {
+		NotificationLog
+		key pagination.Key
+	}
+	var rows []notificationRow
 
-	iter := firestoreClient.Collection("notification_logs").
+	// Document ID breaks ties between logs written at the same instant
+	dir := firestore.Asc
+	if page.Desc() {
+		dir = firestore.Desc
+	}
+	query := firestoreClient.Collection("notification_logs").
 		Where("receiver_id", "==", uid).
-		OrderBy("timestamp", firestore.Desc).
-		Documents(ctx)
+		OrderBy("timestamp", dir).
+		OrderBy(firestore.DocumentID, dir)
+	if value, id, ok := page.After(); ok {
+		after, _ := time.Parse(time.RFC3339Nano, value)
+		query = query.StartAfter(after, id)
+	}
+	iter := query.Limit(page.Limit + 1).Documents(ctx)
 
 	for {
 		doc, err := iter.Next()
@@ -317,10 +379,20 @@ func GetNotificationsHandler(c *fiber.Ctx) error {
 			log.Printf("Failed to parse document: %v", err)
 			continue
 		}
-		notifications = append(notifications, notif)
+		rows = append(rows, notificationRow{
+			NotificationLog: notif,
+			key:             pagination.Key{SortValue: notif.Timestamp.UTC().Format(time.RFC3339Nano), SortID: doc.Ref.ID},
+		})
+	}
+
+	rows, meta := pagination.Finish(page, rows, func(r notificationRow) pagination.Key { return r.key })
+	notifications := make([]NotificationLog, len(rows))
+	for i, row := range rows {
+		notifications[i] = row.NotificationLog
 	}
 
 	return c.JSON(fiber.Map{
 		"notifications": notifications,
+		"pagination":    meta,
 	})
 }
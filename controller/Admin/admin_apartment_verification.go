@@ -1,21 +1,26 @@
 package controller
 
 import (
+	"strings"
 	"time"
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
+	"github.com/Conding-Student/backend/pricehistory"
+	"github.com/Conding-Student/backend/savedsearch"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
-// Get pending status
+// GetPendingApartments lists listings waiting for review, including
+// resubmitted ones, oldest submission first
 func GetPendingApartments(c *fiber.Ctx) error {
 	var pendingApartments []model.Apartment
 
-	// Fetch apartments where status is "Pending"
-	result := middleware.DBConn.Where("status = ?", "Pending").Find(&pendingApartments)
+	// Fetch apartments where status is "Pending" or "Resubmitted"
+	result := middleware.DBConn.Where("status IN ?", moderation.InReview).Order("submitted_at, id").Find(&pendingApartments)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch pending apartments",
@@ -29,15 +34,15 @@ func GetPendingApartments(c *fiber.Ctx) error {
 }
 
 type VerifyApartmentRequest struct {
-	Status string `json:"status"` // Expected values: "Approved" or "Rejected"
+	Status     string `json:"status"`      // Expected values: "Approved" or "Rejected"
+	ReasonCode string `json:"reason_code"` // Required when rejecting, see moderation.ReasonCodes
+	Note       string `json:"note"`
 }
 
 // Verify (Approve/Reject) an Apartment with availability and expiration
 func VerifyApartment(c *fiber.Ctx) error {
 	apartmentID := c.Params("ID")
-	var req struct {
-		Status string `json:"status"`
-	}
+	var req VerifyApartmentRequest
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -46,7 +51,7 @@ func VerifyApartment(c *fiber.Ctx) error {
 	}
 
 	// Validate status input
-	if req.Status != "Approved" && req.Status != "Rejected" {
+	if req.Status != moderation.StatusApproved && req.Status != moderation.StatusRejected {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid status. Use 'Approved' or 'Rejected'.",
 		})
@@ -64,29 +69,34 @@ func VerifyApartment(c *fiber.Ctx) error {
 	responseMessage := ""
 
 	switch req.Status {
-	case "Approved":
+	case moderation.StatusApproved:
 		expiration := time.Now().Add(14 * 24 * time.Hour)
 		updates = map[string]interface{}{
-			"status":       "Approved",
 			"availability": "Available",
 			"expires_at":   expiration,
 		}
 		responseMessage = "Apartment approved and made available"
 
-	case "Rejected":
+	case moderation.StatusRejected:
 		updates = map[string]interface{}{
-			"status":       "Rejected",
 			"availability": "Not Available",
 			"expires_at":   gorm.Expr("NULL"),
 		}
 		responseMessage = "Apartment rejected and marked as unavailable"
 	}
 
-	// Perform database update
-	if err := middleware.DBConn.Model(&apartment).Updates(updates).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update apartment status",
-		})
+	// Perform database update and record the decision
+	actor := moderation.Actor{UID: adminUIDFromToken(c), Role: moderation.RoleAdmin}
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		return moderation.Transition(tx, &apartment, req.Status, actor, req.ReasonCode, strings.TrimSpace(req.Note), updates)
+	})
+	if err != nil {
+		return moderationError(c, err, "Failed to update apartment status")
+	}
+
+	// Alert tenants whose saved searches match the newly approved listing
+	if req.Status == moderation.StatusApproved {
+		go savedsearch.NotifyListing(apartment.ID)
 	}
 
 	// Prepare response
@@ -97,7 +107,7 @@ func VerifyApartment(c *fiber.Ctx) error {
 	}
 
 	// Add expiration time if approved
-	if req.Status == "Approved" {
+	if req.Status == moderation.StatusApproved {
 		if exp, ok := updates["expires_at"].(time.Time); ok {
 			response["expires_at"] = exp.Format(time.RFC3339)
 		}
@@ -140,6 +150,9 @@ func UpdateApartmentInfo(c *fiber.Ctx) error {
 		})
 	}
 
+	becameAvailable := apartment.Availability != "Available" && req.Availability == "Available"
+	oldPrice := apartment.RentPrice
+
 	// Update the fields
 	apartment.PropertyName = req.PropertyName
 	apartment.PropertyType = req.PropertyType
@@ -148,13 +161,31 @@ func UpdateApartmentInfo(c *fiber.Ctx) error {
 	apartment.Allowed_Gender = req.Allowed_gender
 	apartment.Availability = req.Availability
 
-	// Save updates
-	if err := middleware.DBConn.Save(&apartment).Error; err != nil {
+	adminUID := adminUIDFromToken(c)
+
+	// Save updates along with any rent change
+	var priceDropped bool
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&apartment).Error; err != nil {
+			return err
+		}
+		var err error
+		priceDropped, err = pricehistory.Change(tx, apartment.ID, oldPrice, apartment.RentPrice, adminUID)
+		return err
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update apartment info",
 		})
 	}
 
+	if becameAvailable {
+		go savedsearch.NotifyListing(apartment.ID)
+	}
+	if priceDropped {
+		go pricehistory.NotifyDrop(apartment.ID, oldPrice, apartment.RentPrice)
+	}
+
 	return c.JSON(fiber.Map{
 		"message":      "Apartment information updated successfully",
 		"apartment_id": apartmentID,
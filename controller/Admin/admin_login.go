@@ -1,40 +1,25 @@
 package controller
 
 import (
-	"os"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5" // Correct version for your case
+	"fmt"
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/service"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Generate JWT Token using jwt/v5
-func generateJWT(adminID uint, email string) (string, error) {
-	// Get the secret key from the environment variable
-	secretKey := os.Getenv("JWT_SECRET")
-
-	// Create JWT claims
-	claims := jwt.MapClaims{
-		"adminID": adminID,
-		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
-	}
-
-	// Create the token using the claims and secret key
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token with the secret key
-	signedToken, err := token.SignedString([]byte(secretKey))
-	if err != nil {
-		return "", err
+// generateJWT issues an admin token pair through the same token service used
+// for user tokens so AuthMiddleware and RequireRole("Admin") accept it.
+func generateJWT(admin model.Admins) (*service.TokenPair, error) {
+	uid := admin.Uid
+	if uid == "" {
+		uid = fmt.Sprintf("admin-%d", admin.ID)
 	}
 
-	return signedToken, nil
+	return service.Tokens.IssuePair(uid, admin.Email, "Admin")
 }
 
 // Admin Login Function with JWT
@@ -63,7 +48,7 @@ func LoginHandler(c *fiber.Ctx) error {
 	}
 
 	// Generate a JWT token after successful login
-	token, err := generateJWT(admin.ID, admin.Email)
+	tokens, err := generateJWT(admin)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not generate token",
@@ -76,7 +61,10 @@ func LoginHandler(c *fiber.Ctx) error {
 		"admin": fiber.Map{
 			"id":    admin.ID,
 			"email": admin.Email,
+			"role":  "Admin",
 		},
-		"token": token, // Include the token in the response
+		"token":         tokens.AccessToken, // Include the token in the response
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
@@ -2,17 +2,21 @@ package controller
 
 import (
 	"log"
-	"math"
 	"strconv"
 	"strings"
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
 	"github.com/Conding-Student/backend/model/response"
+	"github.com/Conding-Student/backend/moderation"
+	"github.com/Conding-Student/backend/pagination"
+	"github.com/Conding-Student/backend/repository"
 
 	//"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 func GetFilteredApartments(c *fiber.Ctx) error {
@@ -26,21 +30,26 @@ func GetFilteredApartments(c *fiber.Ctx) error {
 	houseRule := c.Query("house_rules", "")
 
 	// 📄 Pagination
-	pageStr := c.Query("page", "1")
-	limitStr := c.Query("limit", "10")
-	page, _ := strconv.Atoi(pageStr)
-	limit, _ := strconv.Atoi(limitStr)
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 {
-		limit = 10
+	page, err := pagination.Parse(c, pagination.Sorts{
+		Fields: map[string]pagination.Field{
+			"price":         {Column: "apartments.rent_price", Type: pagination.TypeNumber},
+			"created_at":    {Column: "apartments.created_at", Type: pagination.TypeTime, Desc: true},
+			"rating":        {Column: repository.AverageRatingSQL, Type: pagination.TypeNumber, Desc: true},
+			"property_name": {Column: "apartments.property_name", Type: pagination.TypeText},
+		},
+		Default: "created_at",
+		ID:      pagination.Field{Column: "apartments.id", Type: pagination.TypeBigint},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
 	}
-	offset := (page - 1) * limit
 
 	// 🏘️ Base query
 	query := middleware.DBConn.Table("apartments").
-		Select("apartments.*, users.fullname as landlord_name").
 		Joins("JOIN users ON users.uid = apartments.user_id").
 		Where("LOWER(apartments.status) <> ?", "deleted")
 
@@ -61,38 +70,31 @@ func GetFilteredApartments(c *fiber.Ctx) error {
 		query = query.Where("LOWER(users.fullname) LIKE ?", "%"+strings.ToLower(landlordName)+"%")
 	}
 
-	// 🔗 Join with amenities if filtering
+	// 🔗 Match amenities without joining, so each apartment is listed once
 	if amenity != "" {
-		query = query.Joins("JOIN apartment_amenities aa ON aa.apartment_id = apartments.id").
-			Joins("JOIN amenities a ON a.id = aa.amenity_id").
-			Where("LOWER(a.name) LIKE ?", "%"+strings.ToLower(amenity)+"%")
+		query = query.Where("EXISTS (SELECT 1 FROM apartment_amenities aa JOIN amenities a ON a.id = aa.amenity_id "+
+			"WHERE aa.apartment_id = apartments.id AND LOWER(a.name) LIKE ?)", "%"+strings.ToLower(amenity)+"%")
 	}
 
-	// 🔗 Join with house rules if filtering
+	// 🔗 Same for house rules
 	if houseRule != "" {
-		query = query.Joins("JOIN apartment_house_rules ahr ON ahr.apartment_id = apartments.id").
-			Joins("JOIN house_rules hr ON hr.id = ahr.house_rule_id").
-			Where("LOWER(hr.rule) LIKE ?", "%"+strings.ToLower(houseRule)+"%")
+		query = query.Where("EXISTS (SELECT 1 FROM apartment_house_rules ahr JOIN house_rules hr ON hr.id = ahr.house_rule_id "+
+			"WHERE ahr.apartment_id = apartments.id AND LOWER(hr.rule) LIKE ?)", "%"+strings.ToLower(houseRule)+"%")
 	}
 
 	// 🔢 Count total
 	var total int64
-	query.Count(&total)
-
-	// ⛔ Reset pagination if out of bounds
-	if offset >= int(total) {
-		page = 1
-		offset = 0
-	}
+	query.Session(&gorm.Session{}).Count(&total)
 
 	// 🧾 Final fetch
 	type ApartmentInfo struct {
 		model.Apartment
 		LandlordName string `json:"landlord_name"`
+		pagination.Key
 	}
 
 	var results []ApartmentInfo
-	err := query.Offset(offset).Limit(limit).Scan(&results).Error
+	err = page.Apply(page.Select(query, "apartments.*, users.fullname as landlord_name")).Scan(&results).Error
 	if err != nil {
 		log.Println("[ERROR] Failed to fetch apartments:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
@@ -102,16 +104,16 @@ func GetFilteredApartments(c *fiber.Ctx) error {
 		})
 	}
 
+	results, meta := pagination.Finish(page, results, func(r ApartmentInfo) pagination.Key { return r.Key })
+
 	// ✅ Response
 	return c.Status(fiber.StatusOK).JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Apartments fetched successfully",
 		Data: fiber.Map{
-			"limit":       limit,
-			"page":        page,
-			"total":       total,
-			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
-			"apartments":  results,
+			"total":      total,
+			"pagination": meta,
+			"apartments": results,
 		},
 	})
 }
@@ -138,8 +140,20 @@ func DeleteApartmentByID(c *fiber.Ctx) error {
 		})
 	}
 
-	// Step 3: Delete the apartment (cascade deletes everything linked)
-	if err := middleware.DBConn.Delete(&apartment).Error; err != nil {
+	adminUID := ""
+	if claims, ok := c.Locals("user").(jwt.MapClaims); ok {
+		adminUID, _ = claims["uid"].(string)
+	}
+
+	// Step 3: Record who deleted it, then delete the apartment (cascade
+	// deletes everything linked except the moderation history)
+	actor := moderation.Actor{UID: adminUID, Role: moderation.RoleAdmin}
+	if err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := moderation.Record(tx, apartment.ID, apartment.Status, moderation.StatusDeleted, actor, "", ""); err != nil {
+			return err
+		}
+		return tx.Delete(&apartment).Error
+	}); err != nil {
 		log.Println("[ERROR] Failed to delete apartment:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
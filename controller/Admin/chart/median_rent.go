@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/Conding-Student/backend/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// medianRentQuery takes the rent each approved listing had at the end of
+// every month from its price history, then the median per property type.
+// Months are counted back from the current one.
+const medianRentQuery = `
+	WITH months AS (
+		SELECT generate_series(
+			date_trunc('month', NOW()) - (? - 1) * interval '1 month',
+			date_trunc('month', NOW()),
+			interval '1 month') AS month
+	), prices AS (
+		SELECT m.month, a.property_type, (
+			SELECT h.new_price FROM apartment_price_histories h
+			WHERE h.apartment_id = a.id AND h.changed_at < m.month + interval '1 month'
+			ORDER BY h.changed_at DESC, h.id DESC
+			LIMIT 1) AS price
+		FROM months m
+		JOIN apartments a ON a.created_at < m.month + interval '1 month'
+		WHERE a.status = 'Approved' AND (? = '' OR a.property_type = ?)
+	)
+	SELECT to_char(month, 'YYYY-MM') AS month, property_type,
+		percentile_cont(0.5) WITHIN GROUP (ORDER BY price) AS median_rent,
+		COUNT(*) AS listings
+	FROM prices
+	WHERE price IS NOT NULL
+	GROUP BY month, property_type
+	ORDER BY month, property_type`
+
+// GetMedianRentByPropertyType returns a monthly median-rent series for each
+// property type of approved listings. ?months= defaults to 12, max 60, and
+// ?property_type= narrows it to one type.
+func GetMedianRentByPropertyType(c *fiber.Ctx) error {
+	type MedianRentPoint struct {
+		Month      string  `json:"month"`
+		MedianRent float64 `json:"median_rent"`
+		Listings   int     `json:"listings"`
+	}
+	type MedianRentSeries struct {
+		PropertyType string            `json:"property_type"`
+		Points       []MedianRentPoint `json:"points"`
+	}
+
+	months := c.QueryInt("months", 12)
+	if months < 1 || months > 60 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "months must be between 1 and 60",
+		})
+	}
+	propertyType := strings.TrimSpace(c.Query("property_type"))
+
+	var rows []struct {
+		Month        string
+		PropertyType string
+		MedianRent   float64
+		Listings     int
+	}
+	if err := middleware.DBConn.Raw(medianRentQuery, months, propertyType, propertyType).Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error fetching median rent",
+			"msg":   err.Error(),
+		})
+	}
+
+	series := []MedianRentSeries{}
+	index := map[string]int{}
+	for _, row := range rows {
+		i, ok := index[row.PropertyType]
+		if !ok {
+			i = len(series)
+			index[row.PropertyType] = i
+			series = append(series, MedianRentSeries{PropertyType: row.PropertyType})
+		}
+		series[i].Points = append(series[i].Points, MedianRentPoint{
+			Month:      row.Month,
+			MedianRent: row.MedianRent,
+			Listings:   row.Listings,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"months": months,
+		"series": series,
+	})
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
+	"github.com/Conding-Student/backend/pagination"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func adminUIDFromToken(c *fiber.Ctx) string {
+	adminUID := ""
+	if claims, ok := c.Locals("user").(jwt.MapClaims); ok {
+		adminUID, _ = claims["uid"].(string)
+	}
+	return adminUID
+}
+
+// moderationError maps moderation errors to responses, falling back to a 500
+// with fallback.
+func moderationError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, moderation.ErrReasonRequired), errors.Is(err, moderation.ErrNoteRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, moderation.ErrInvalidTransition),
+		errors.Is(err, moderation.ErrClaimedByOther),
+		errors.Is(err, moderation.ErrStatusChanged):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
+
+// GetRejectionReasons lists the reason codes admins can reject a listing with.
+func GetRejectionReasons(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"reasons": moderation.ReasonCodes})
+}
+
+// GetModerationQueue lists listings waiting for review, oldest submission
+// first, with who has claimed each one. ?claimed=mine|unclaimed narrows the
+// queue; claims older than moderation.ClaimTTL count as unclaimed.
+func GetModerationQueue(c *fiber.Ctx) error {
+	type QueueItem struct {
+		model.Apartment
+		LandlordName string  `json:"landlord_name"`
+		WaitingHours float64 `json:"waiting_hours"`
+		ClaimActive  bool    `json:"claim_active"`
+		ClaimedByMe  bool    `json:"claimed_by_me"`
+	}
+
+	page, err := pagination.Parse(c, pagination.Sorts{
+		Fields: map[string]pagination.Field{
+			"submitted_at": {Column: "COALESCE(apartments.submitted_at, apartments.created_at)", Type: pagination.TypeTime},
+		},
+		Default: "submitted_at",
+		ID:      pagination.Field{Column: "apartments.id", Type: pagination.TypeBigint},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	adminUID := adminUIDFromToken(c)
+	staleBefore := time.Now().Add(-moderation.ClaimTTL)
+	query := middleware.DBConn.Model(&model.Apartment{}).
+		Joins("LEFT JOIN users ON users.uid = apartments.uid").
+		Where("apartments.status IN ?", moderation.InReview)
+	switch c.Query("claimed") {
+	case "mine":
+		query = query.Where("apartments.review_claimed_by = ? AND apartments.review_claimed_at >= ?", adminUID, staleBefore)
+	case "unclaimed":
+		query = query.Where("(apartments.review_claimed_by IS NULL OR apartments.review_claimed_at < ?)", staleBefore)
+	case "", "all":
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "claimed must be mine, unclaimed or all"})
+	}
+
+	type queueRow struct {
+		model.Apartment
+		pagination.Key
+		LandlordName string
+	}
+	var rows []queueRow
+	if err := page.Apply(page.Select(query, "apartments.*, users.fullname AS landlord_name")).Find(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch review queue"})
+	}
+	rows, meta := pagination.Finish(page, rows, func(r queueRow) pagination.Key { return r.Key })
+
+	now := time.Now()
+	items := make([]QueueItem, len(rows))
+	for i, row := range rows {
+		submitted := row.CreatedAt
+		if row.SubmittedAt != nil {
+			submitted = *row.SubmittedAt
+		}
+		active := row.ReviewClaimedBy != nil && row.ReviewClaimedAt != nil && row.ReviewClaimedAt.After(staleBefore)
+		items[i] = QueueItem{
+			Apartment:    row.Apartment,
+			LandlordName: row.LandlordName,
+			WaitingHours: float64(int(now.Sub(submitted).Hours()*10)) / 10,
+			ClaimActive:  active,
+			ClaimedByMe:  active && *row.ReviewClaimedBy == adminUID,
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"apartments": items,
+		"pagination": meta,
+	})
+}
+
+func parseIDParam(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	return uint(id), err
+}
+
+// ClaimApartmentReview reserves a listing in the review queue for the
+// calling admin so no one else decides on it. Claiming again refreshes it.
+func ClaimApartmentReview(c *fiber.Ctx) error {
+	apartmentID, err := parseIDParam(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid apartment ID"})
+	}
+
+	adminUID := adminUIDFromToken(c)
+	claimed, err := moderation.Claim(middleware.DBConn, apartmentID, adminUID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to claim apartment"})
+	}
+	if !claimed {
+		var apartment model.Apartment
+		if err := middleware.DBConn.First(&apartment, apartmentID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Apartment not found"})
+		}
+		if apartment.Status != moderation.StatusPending && apartment.Status != moderation.StatusResubmitted {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Apartment is not waiting for review"})
+		}
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":             moderation.ErrClaimedByOther.Error(),
+			"review_claimed_by": apartment.ReviewClaimedBy,
+			"review_claimed_at": apartment.ReviewClaimedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Apartment claimed for review",
+		"apartment_id": apartmentID,
+		"expires_at":   time.Now().Add(moderation.ClaimTTL).Format(time.RFC3339),
+	})
+}
+
+// UnclaimApartmentReview releases the calling admin's claim on a listing.
+func UnclaimApartmentReview(c *fiber.Ctx) error {
+	apartmentID, err := parseIDParam(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid apartment ID"})
+	}
+
+	released, err := moderation.Unclaim(middleware.DBConn, apartmentID, adminUIDFromToken(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to release apartment"})
+	}
+	if !released {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "You have not claimed this apartment"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Apartment released",
+		"apartment_id": apartmentID,
+	})
+}
+
+// GetModerationHistory returns every status change of a listing.
+func GetModerationHistory(c *fiber.Ctx) error {
+	apartmentID, err := parseIDParam(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid apartment ID"})
+	}
+
+	events, err := moderation.History(middleware.DBConn, apartmentID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch moderation history"})
+	}
+
+	return c.JSON(fiber.Map{
+		"apartment_id": apartmentID,
+		"history":      events,
+	})
+}
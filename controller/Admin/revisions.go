@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/pagination"
+	"github.com/Conding-Student/backend/pricehistory"
+	"github.com/Conding-Student/backend/revision"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetApartmentRevisions lists landlords' edits to approved listings, oldest
+// first. ?status= defaults to pending.
+func GetApartmentRevisions(c *fiber.Ctx) error {
+	status := c.Query("status", revision.StatusPending)
+	switch status {
+	case revision.StatusPending, revision.StatusApproved, revision.StatusRejected, revision.StatusWithdrawn:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "status must be pending, approved, rejected or withdrawn"})
+	}
+
+	page, err := pagination.Parse(c, pagination.Sorts{
+		Fields: map[string]pagination.Field{
+			"created_at": {Column: "apartment_revisions.created_at", Type: pagination.TypeTime},
+		},
+		Default: "created_at",
+		ID:      pagination.Field{Column: "apartment_revisions.id", Type: pagination.TypeBigint},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	type revisionRow struct {
+		model.ApartmentRevision
+		pagination.Key
+		PropertyName string
+	}
+	var rows []revisionRow
+	query := middleware.DBConn.Model(&model.ApartmentRevision{}).
+		Joins("JOIN apartments ON apartments.id = apartment_revisions.apartment_id").
+		Where("apartment_revisions.status = ?", status)
+	if err := page.Apply(page.Select(query, "apartment_revisions.*, apartments.property_name")).Find(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch revisions"})
+	}
+	rows, meta := pagination.Finish(page, rows, func(r revisionRow) pagination.Key { return r.Key })
+
+	type RevisionItem struct {
+		revision.Detail
+		PropertyName string `json:"property_name"`
+	}
+	items := make([]RevisionItem, 0, len(rows))
+	for _, row := range rows {
+		detail, err := revision.Describe(middleware.DBConn, row.ApartmentRevision)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compare revision"})
+		}
+		items = append(items, RevisionItem{Detail: detail, PropertyName: row.PropertyName})
+	}
+
+	return c.JSON(fiber.Map{
+		"revisions":  items,
+		"pagination": meta,
+	})
+}
+
+// GetApartmentRevision shows one revision field by field next to the live
+// listing.
+func GetApartmentRevision(c *fiber.Ctx) error {
+	var r model.ApartmentRevision
+	if err := middleware.DBConn.First(&r, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+	}
+
+	detail, err := revision.Describe(middleware.DBConn, r)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compare revision"})
+	}
+	return c.JSON(fiber.Map{"revision": detail})
+}
+
+func reviewApartmentRevision(c *fiber.Ctx, approve bool) error {
+	var req struct {
+		Note string `json:"note"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request format"})
+		}
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if !approve && req.Note == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A note telling the landlord why is required"})
+	}
+
+	revisionID, err := parseIDParam(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid revision ID"})
+	}
+
+	var (
+		r            model.ApartmentRevision
+		apartment    model.Apartment
+		priceDropped bool
+		oldPrice     float64
+	)
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		var err error
+		r, apartment, priceDropped, oldPrice, err = revision.Review(tx, revisionID, approve, adminUIDFromToken(c), req.Note)
+		return err
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+	case errors.Is(err, revision.ErrNotPending):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to review revision"})
+	}
+
+	if priceDropped {
+		go pricehistory.NotifyDrop(apartment.ID, oldPrice, apartment.RentPrice)
+	}
+
+	message := "Revision rejected; the listing is unchanged"
+	if approve {
+		message = "Revision approved and applied to the listing"
+	}
+	return c.JSON(fiber.Map{
+		"message":   message,
+		"revision":  r,
+		"apartment": apartment,
+	})
+}
+
+// ApproveApartmentRevision applies a pending revision to the live listing.
+func ApproveApartmentRevision(c *fiber.Ctx) error {
+	return reviewApartmentRevision(c, true)
+}
+
+// RejectApartmentRevision discards a pending revision with a note for the
+// landlord. The listing keeps its current values.
+func RejectApartmentRevision(c *fiber.Ctx) error {
+	return reviewApartmentRevision(c, false)
+}
@@ -4,16 +4,17 @@ import (
 	//"fmt"
 	"fmt"
 	"log"
-	"math"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
 	"github.com/Conding-Student/backend/model/response"
+	"github.com/Conding-Student/backend/pagination"
+	"github.com/Conding-Student/backend/service"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 func GetLatestLandlordID(c *fiber.Ctx) error {
@@ -41,6 +42,30 @@ func GetLatestLandlordID(c *fiber.Ctx) error {
 	})
 }
 
+// userSorts is the ?sort= whitelist for the admin user lists.
+var userSorts = pagination.Sorts{
+	Fields: map[string]pagination.Field{
+		"created_at": {Column: "users.created_at", Type: pagination.TypeTime, Desc: true},
+		"fullname":   {Column: "users.fullname", Type: pagination.TypeText},
+	},
+	Default: "created_at",
+	ID:      pagination.Field{Column: "users.id", Type: pagination.TypeBigint},
+}
+
+const userColumns = "uid, email, phone_number, fullname, address, valid_id, account_status, user_type"
+
+type userRow struct {
+	UID           string `json:"uid"`
+	Email         string `json:"email"`
+	PhoneNumber   string `json:"phone_number"`
+	FullName      string `json:"fullname" gorm:"column:fullname"`
+	Address       string `json:"address"`
+	ValidID       string `json:"valid_id"`
+	AccountStatus string `json:"account_status"`
+	UserType      string `json:"user_type"`
+	pagination.Key
+}
+
 func GetFilteredUserDetails(c *fiber.Ctx) error {
 	// 🔍 Filters
 	userType := c.Query("user_type", "")
@@ -48,24 +73,17 @@ func GetFilteredUserDetails(c *fiber.Ctx) error {
 	name := c.Query("name", "")
 
 	// 📄 Pagination
-	pageStr := c.Query("page", "1")
-	limitStr := c.Query("limit", "10")
-
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 10
+	page, err := pagination.Parse(c, userSorts)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
 	}
 
-	offset := (page - 1) * limit
-
 	// Fetch users with status "Unverified" OR "Pending" (across all tenants)
 	query := middleware.DBConn.Table("users").
-		Select("uid, email, phone_number, fullname, address, valid_id, account_status, user_type").
 		Where("account_status IN ?", []string{"Unverified", "Pending", "Verified"}) // Explicit status filter
 
 	// ✅ Apply filters
@@ -81,27 +99,11 @@ func GetFilteredUserDetails(c *fiber.Ctx) error {
 
 	// 🔢 Count total filtered rows
 	var total int64
-	query.Count(&total)
-
-	// ✅ Prevent out-of-bound pages
-	if offset >= int(total) {
-		page = 1
-		offset = 0
-	}
+	query.Session(&gorm.Session{}).Count(&total)
 
 	// 🧾 Fetch paginated results
-	var users []struct {
-		UID           string `json:"uid"`
-		Email         string `json:"email"`
-		PhoneNumber   string `json:"phone_number"`
-		FullName      string `json:"fullname" gorm:"column:fullname"`
-		Address       string `json:"address"`
-		ValidID       string `json:"valid_id"`
-		AccountStatus string `json:"account_status"`
-		UserType      string `json:"user_type"`
-	}
-
-	err = query.Offset(offset).Limit(limit).Find(&users).Error
+	var users []userRow
+	err = page.Apply(page.Select(query, userColumns)).Find(&users).Error
 	if err != nil {
 		log.Println("[ERROR] Failed to fetch users:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
@@ -111,16 +113,16 @@ func GetFilteredUserDetails(c *fiber.Ctx) error {
 		})
 	}
 
+	users, meta := pagination.Finish(page, users, func(u userRow) pagination.Key { return u.Key })
+
 	// 📦 Paginated Response
 	return c.Status(fiber.StatusOK).JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Paginated user list retrieved successfully",
 		Data: fiber.Map{
-			"limit":       limit,
-			"page":        page,
-			"total":       total,
-			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
-			"users":       users,
+			"total":      total,
+			"pagination": meta,
+			"users":      users,
 		},
 	})
 }
@@ -135,10 +137,6 @@ func GetFilteredUserDetailsadvance(c *fiber.Ctx) error {
 	searchField := c.Query("field", "")
 	searchTerm := c.Query("search_term", "")
 
-	// 📄 Pagination
-	pageStr := c.Query("page", "1")
-	limitStr := c.Query("limit", "10")
-
 	// Validate Advanced Search Parameters
 	if (searchField != "" && searchTerm == "") || (searchTerm != "" && searchField == "") {
 		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
@@ -180,20 +178,17 @@ func GetFilteredUserDetailsadvance(c *fiber.Ctx) error {
 	}
 
 	// Pagination Processing
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 10
+	page, err := pagination.Parse(c, userSorts)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
 	}
-	offset := (page - 1) * limit
 
 	// Base Query
 	query := middleware.DBConn.Table("users").
-		Select("uid, email, phone_number, fullname, address, valid_id, account_status, user_type").
 		Where("account_status IN ?", []string{"Unverified", "Pending", "Verified"})
 
 	// ✅ Apply Basic Filters
@@ -214,27 +209,11 @@ func GetFilteredUserDetailsadvance(c *fiber.Ctx) error {
 
 	// 🔢 Count Total Filtered Rows
 	var total int64
-	query.Count(&total)
-
-	// ✅ Prevent Out-of-Bound Pages
-	if offset >= int(total) {
-		page = 1
-		offset = 0
-	}
+	query.Session(&gorm.Session{}).Count(&total)
 
 	// 🧾 Fetch Paginated Results
-	var users []struct {
-		UID           string `json:"uid"`
-		Email         string `json:"email"`
-		PhoneNumber   string `json:"phone_number"`
-		FullName      string `json:"fullname" gorm:"column:fullname"`
-		Address       string `json:"address"`
-		ValidID       string `json:"valid_id"`
-		AccountStatus string `json:"account_status"`
-		UserType      string `json:"user_type"`
-	}
-
-	err = query.Offset(offset).Limit(limit).Find(&users).Error
+	var users []userRow
+	err = page.Apply(page.Select(query, userColumns)).Find(&users).Error
 	if err != nil {
 		log.Println("[ERROR] Failed to fetch users:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
@@ -244,16 +223,16 @@ func GetFilteredUserDetailsadvance(c *fiber.Ctx) error {
 		})
 	}
 
+	users, meta := pagination.Finish(page, users, func(u userRow) pagination.Key { return u.Key })
+
 	// 📦 Paginated Response
 	return c.Status(fiber.StatusOK).JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Paginated user list retrieved successfully",
 		Data: fiber.Map{
-			"limit":       limit,
-			"page":        page,
-			"total":       total,
-			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
-			"users":       users,
+			"total":      total,
+			"pagination": meta,
+			"users":      users,
 		},
 	})
 }
@@ -496,6 +475,16 @@ func SoftDeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// End every session the user still has
+	if err := service.RevokeUser(tx, uid); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to revoke user sessions",
+			Data:    nil,
+		})
+	}
+
 	// Common updates for all user types
 	commonUpdates := func() error {
 		// Update inquiries
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// defaultPriceBuckets are the edges used when ?price_buckets= is omitted:
+// under 3k, 3k–5k, 5k–8k, 8k–12k and 12k and up.
+var defaultPriceBuckets = []float64{3000, 5000, 8000, 12000}
+
+const maxPriceBuckets = 20
+
+// FacetCount is how many listings have one value of a facet.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// PriceBucket counts listings whose cheapest unit rent is in [Min, Max). The
+// first bucket has no Min and the last no Max.
+type PriceBucket struct {
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+func parsePriceBuckets(value string) ([]float64, error) {
+	if value == "" {
+		return defaultPriceBuckets, nil
+	}
+	var edges []float64
+	for _, item := range repository.SplitList(value) {
+		edge, err := strconv.ParseFloat(item, 64)
+		if err != nil || edge <= 0 {
+			return nil, fmt.Errorf("price_buckets must be positive numbers, got %q", item)
+		}
+		edges = append(edges, edge)
+	}
+	if len(edges) == 0 || len(edges) > maxPriceBuckets {
+		return nil, fmt.Errorf("price_buckets takes between 1 and %d edges", maxPriceBuckets)
+	}
+	sort.Float64s(edges)
+	for i := 1; i < len(edges); i++ {
+		if edges[i] == edges[i-1] {
+			return nil, fmt.Errorf("price_buckets has %g twice", edges[i])
+		}
+	}
+	return edges, nil
+}
+
+// genderFacetJoin gives each apartment a row per gender it accepts, its own
+// and its units', the same genders ListingFilter matches ?allowed_genders= on.
+const genderFacetJoin = "JOIN LATERAL (SELECT apartments.allowed_gender AS gender " +
+	"UNION SELECT apartment_units.allowed_gender FROM apartment_units WHERE apartment_units.apartment_id = apartments.id) fg " +
+	"ON fg.gender <> ''"
+
+// availabilityFacetSQL is the availability ListingFilter matches an apartment
+// on: an Available apartment whose units are all taken matches none.
+const availabilityFacetSQL = "(CASE WHEN apartments.availability = 'Available' AND " + repository.UnitsAvailableSQL + " = 0 " +
+	"THEN NULL ELSE apartments.availability END)"
+
+// facetBase is the approved listings matching every filter except the
+// facet's own, so picking one value does not hide its siblings.
+func facetBase(f repository.ListingFilter) *gorm.DB {
+	return f.Apply(middleware.DBConn.Model(&model.Apartment{}).Where("apartments.status = ?", "Approved"))
+}
+
+func countColumn(f repository.ListingFilter, column string) ([]FacetCount, error) {
+	counts := []FacetCount{}
+	err := facetBase(f).
+		Select(column + " AS value, COUNT(*) AS count").
+		Where(column + " <> ''").
+		Group(column).
+		Order("count DESC, value").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func countJoined(f repository.ListingFilter, join, column string) ([]FacetCount, error) {
+	counts := []FacetCount{}
+	err := facetBase(f).
+		Joins(join).
+		Select(column + " AS value, COUNT(DISTINCT apartments.id) AS count").
+		Group(column).
+		Order("count DESC, value").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func countPrices(f repository.ListingFilter, edges []float64) ([]PriceBucket, error) {
+	buckets := make([]PriceBucket, len(edges)+1)
+	columns := make([]string, len(buckets))
+	var args []interface{}
+	for i := range buckets {
+		var conditions []string
+		if i > 0 {
+			buckets[i].Min = &edges[i-1]
+			conditions = append(conditions, repository.PriceMinSQL+" >= ?")
+			args = append(args, edges[i-1])
+		}
+		if i < len(edges) {
+			buckets[i].Max = &edges[i]
+			conditions = append(conditions, repository.PriceMinSQL+" < ?")
+			args = append(args, edges[i])
+		}
+		columns[i] = fmt.Sprintf("COUNT(*) FILTER (WHERE %s) AS b%d", strings.Join(conditions, " AND "), i)
+	}
+
+	row := map[string]interface{}{}
+	if err := facetBase(f).Select(strings.Join(columns, ", "), args...).Take(&row).Error; err != nil {
+		return nil, err
+	}
+	for i := range buckets {
+		if n, ok := row[fmt.Sprintf("b%d", i)].(int64); ok {
+			buckets[i].Count = n
+		}
+	}
+	return buckets, nil
+}
+
+// FetchApartmentFacets returns per-value listing counts for the filters of
+// FetchApprovedApartmentsForTenant: property type, allowed gender,
+// availability, amenity, house rule and price bucket. Price bucket edges
+// can be set with ?price_buckets=3000,5000,8000.
+func FetchApartmentFacets(c *fiber.Ctx) error {
+	filter := parseListingFilter(c)
+	edges, err := parsePriceBuckets(c.Query("price_buckets"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	failed := func(err error) error {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to count facets",
+			"error":   err.Error(),
+		})
+	}
+
+	var total int64
+	if err := facetBase(filter).Count(&total).Error; err != nil {
+		return failed(err)
+	}
+
+	without := filter
+	without.PropertyTypes = nil
+	propertyTypes, err := countColumn(without, "apartments.property_type")
+	if err != nil {
+		return failed(err)
+	}
+
+	without = filter
+	without.AllowedGenders = nil
+	genders, err := countJoined(without, genderFacetJoin, "fg.gender")
+	if err != nil {
+		return failed(err)
+	}
+
+	without = filter
+	without.Availability = nil
+	availability, err := countColumn(without, availabilityFacetSQL)
+	if err != nil {
+		return failed(err)
+	}
+
+	without = filter
+	without.Amenities = nil
+	amenities, err := countJoined(without,
+		"JOIN apartment_amenities fa ON fa.apartment_id = apartments.id JOIN amenities ON amenities.id = fa.amenity_id",
+		"amenities.name")
+	if err != nil {
+		return failed(err)
+	}
+
+	without = filter
+	without.HouseRules = nil
+	houseRules, err := countJoined(without,
+		"JOIN apartment_house_rules fh ON fh.apartment_id = apartments.id JOIN house_rules ON house_rules.id = fh.house_rule_id",
+		"house_rules.rule")
+	if err != nil {
+		return failed(err)
+	}
+
+	without = filter
+	without.MinPrice, without.MaxPrice = nil, nil
+	prices, err := countPrices(without, edges)
+	if err != nil {
+		return failed(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"total": total,
+		"facets": fiber.Map{
+			"property_types":  propertyTypes,
+			"allowed_genders": genders,
+			"availability":    availability,
+			"amenities":       amenities,
+			"house_rules":     houseRules,
+			"price":           prices,
+		},
+	})
+}
@@ -1,314 +1,178 @@
 package controller
 
 import (
+	"math"
 	"net/http"
-	"sort"
-	"strconv"
 	"strings"
 
+	"github.com/Conding-Student/backend/availability"
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/pagination"
+	"github.com/Conding-Student/backend/pricehistory"
+	"github.com/Conding-Student/backend/repository"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// FetchApprovedApartmentsForTenant returns filtered and sorted approved apartments
+func parseListingFilter(c *fiber.Ctx) repository.ListingFilter {
+	return repository.ParseListingFilter(func(key string) string { return c.Query(key) })
+}
+
+// FetchApprovedApartmentsForTenant returns a page of filtered approved
+// apartments, featured ones first. ?sort= is relevance (default), price,
+// created_at, rating or, with ?lat=&lng=, distance.
 func FetchApprovedApartmentsForTenant(c *fiber.Ctx) error {
 	type ApartmentDetails struct {
+		model.ApartmentDetails
+		DistanceKm *float64 `json:"distance_km,omitempty"`
+	}
+	type apartmentRow struct {
 		model.Apartment
-		LandlordName     string   `json:"landlord_name"`
-		LandlordEmail    string   `json:"landlord_email"`
-		LandlordPhone    string   `json:"landlord_phone"`
-		LandlordAddress  string   `json:"landlord_address"`
-		LandlordValidID  string   `json:"landlord_valid_id"`
-		LandlordPhotoURL string   `json:"landlord_photo_url"`
-		LandlordUserType string   `json:"landlord_user_type"`
-		LandlordStatus   string   `json:"landlord_account_status"`
-		Images           []string `json:"images"`
-		Videos           []string `json:"videos"`
-		Amenities        []string `json:"amenities"`
-		HouseRules       []string `json:"house_rules"`
-		InquiriesCount   int64    `json:"inquiries_count"`
-		RelevanceScore   int      `json:"-"`
+		pagination.Key
+		DistanceKm *float64
 	}
 
-	// Get query parameters
-	propertyTypes := c.Query("property_types")
-	minPriceStr := c.Query("min_price")
-	maxPriceStr := c.Query("max_price")
-	amenitiesFilter := strings.Split(c.Query("amenities"), ",")
-	houseRulesFilter := strings.Split(c.Query("house_rules"), ",")
-	allowedGenders := c.Query("allowed_genders")
-
-	var apartments []model.Apartment
-	db := middleware.DBConn.Where("status = ?", "Approved")
-
-	// Apply filters
-	if propertyTypes != "" {
-		propertyTypeList := strings.Split(propertyTypes, ",")
-		for i := range propertyTypeList {
-			propertyTypeList[i] = strings.TrimSpace(propertyTypeList[i])
+	var point *[2]float64
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		lat, err := parseCoordinate(c, "lat", 90)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
 		}
-		db = db.Where("property_type IN ?", propertyTypeList)
-	}
-
-	if minPriceStr != "" {
-		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
-			db = db.Where("rent_price >= ?", minPrice)
+		lng, err := parseCoordinate(c, "lng", 180)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
 		}
+		point = &[2]float64{lat, lng}
 	}
 
-	if maxPriceStr != "" {
-		if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil {
-			db = db.Where("rent_price <= ?", maxPrice)
-		}
+	filter := parseListingFilter(c)
+	page, err := pagination.Parse(c, repository.ApartmentSorts(point, filter))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	if allowedGenders != "" {
-		allowedGendersList := strings.Split(allowedGenders, ",")
-		for i := range allowedGendersList {
-			allowedGendersList[i] = strings.TrimSpace(allowedGendersList[i])
-		}
-		db = db.Where("allowed_gender IN ?", allowedGendersList)
+	columns, args := "apartments.*", []interface{}{}
+	if point != nil {
+		columns += ", " + repository.HaversineSQL + " AS distance_km"
+		args = append(args, point[0], point[0], point[1])
 	}
 
-	if err := db.Find(&apartments).Error; err != nil {
+	var rows []apartmentRow
+	query := filter.Apply(middleware.DBConn.Model(&model.Apartment{}).Where("apartments.status = ?", "Approved"))
+	if err := page.Apply(page.Select(query, columns, args...)).Find(&rows).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to fetch apartments",
 			"error":   err.Error(),
 		})
 	}
+	rows, meta := pagination.Finish(page, rows, func(r apartmentRow) pagination.Key { return r.Key })
 
-	var results []ApartmentDetails
-
-	countMatches := func(apartmentItems, filterItems []string) int {
-		count := 0
-		for _, f := range filterItems {
-			for _, item := range apartmentItems {
-				if strings.EqualFold(strings.TrimSpace(item), strings.TrimSpace(f)) {
-					count++
-					break
-				}
-			}
+	apartments := make([]model.Apartment, len(rows))
+	distanceByID := make(map[uint]*float64, len(rows))
+	for i, row := range rows {
+		apartments[i] = row.Apartment
+		if row.DistanceKm != nil {
+			rounded := math.Round(*row.DistanceKm*100) / 100
+			distanceByID[row.ID] = &rounded
 		}
-		return count
 	}
 
-	for _, apt := range apartments {
-		var landlord model.User
-		if err := middleware.DBConn.Where("uid = ?", apt.Uid).First(&landlord).Error; err != nil {
-			continue
-		}
-
-		// Fetch images
-		var images []model.ApartmentImage
-		middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&images)
-		imageUrls := make([]string, len(images))
-		for i, img := range images {
-			imageUrls[i] = img.ImageURL
-		}
-
-		// Fetch videos
-		var videos []model.ApartmentVideo
-		middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&videos)
-		videoUrls := make([]string, len(videos))
-		for i, vid := range videos {
-			videoUrls[i] = vid.VideoURL
-		}
-
-		// Fetch amenities
-		var amenities []model.Amenity
-		middleware.DBConn.
-			Joins("JOIN apartment_amenities ON amenities.id = apartment_amenities.amenity_id").
-			Where("apartment_amenities.apartment_id = ?", apt.ID).
-			Find(&amenities)
-		amenityNames := make([]string, len(amenities))
-		for i, a := range amenities {
-			amenityNames[i] = a.Name
-		}
-
-		// Fetch house rules
-		var houseRules []model.HouseRule
-		middleware.DBConn.
-			Joins("JOIN apartment_house_rules ON house_rules.id = apartment_house_rules.house_rule_id").
-			Where("apartment_house_rules.apartment_id = ?", apt.ID).
-			Find(&houseRules)
-		ruleNames := make([]string, len(houseRules))
-		for i, r := range houseRules {
-			ruleNames[i] = r.Rule
-		}
-
-		// Get inquiry count
-		var inquiryCount int64
-		middleware.DBConn.Model(&model.Inquiry{}).
-			Where("apartment_id = ? AND (status = ? OR status = ?)", apt.ID, "Accepted", "Pending").
-			Count(&inquiryCount)
-
-		// Calculate relevance score
-		score := 0
-		score += countMatches(amenityNames, amenitiesFilter)
-		score += countMatches(ruleNames, houseRulesFilter)
-
-		if score > 0 || (c.Query("amenities") == "" && c.Query("house_rules") == "") {
-			results = append(results, ApartmentDetails{
-				Apartment:        apt,
-				LandlordName:     landlord.Fullname,
-				LandlordEmail:    landlord.Email,
-				LandlordPhone:    landlord.PhoneNumber,
-				LandlordAddress:  landlord.Address,
-				LandlordValidID:  landlord.ValidID,
-				LandlordPhotoURL: landlord.PhotoURL,
-				LandlordUserType: landlord.UserType,
-				LandlordStatus:   landlord.AccountStatus,
-				Images:           imageUrls,
-				Videos:           videoUrls,
-				Amenities:        amenityNames,
-				HouseRules:       ruleNames,
-				InquiriesCount:   inquiryCount,
-				RelevanceScore:   score,
-			})
-		}
+	details, err := repository.LoadApartmentDetails(middleware.DBConn, apartments)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch apartments",
+			"error":   err.Error(),
+		})
 	}
 
-	// Sort by relevance then by inquiries count
-	sort.SliceStable(results, func(i, j int) bool {
-		if results[i].RelevanceScore == results[j].RelevanceScore {
-			return results[i].InquiriesCount > results[j].InquiriesCount
-		}
-		return results[i].RelevanceScore > results[j].RelevanceScore
-	})
+	results := make([]ApartmentDetails, 0, len(details))
+	for _, d := range details {
+		results = append(results, ApartmentDetails{ApartmentDetails: d, DistanceKm: distanceByID[d.ID]})
+	}
 
-	// At the end of your FetchApprovedApartmentsForTenant function
 	if len(results) == 0 {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"apartments": make([]interface{}, 0), // Explicit empty array
+			"apartments": results,
+			"pagination": meta,
 			"message":    "No apartments found matching filters",
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"apartments": results,
+		"pagination": meta,
 	})
 }
 
 // FetchSingleApartmentDetails returns complete details for a specific apartment
+// along with its rent history
 func FetchSingleApartmentDetails(c *fiber.Ctx) error {
-	type ApartmentDetails struct {
-		model.Apartment
-		LandlordName     string   `json:"landlord_name"`
-		LandlordEmail    string   `json:"landlord_email"`
-		LandlordPhone    string   `json:"landlord_phone"`
-		LandlordAddress  string   `json:"landlord_address"`
-		LandlordValidID  string   `json:"landlord_valid_id"`
-		LandlordPhotoURL string   `json:"landlord_photo_url"`
-		LandlordUserType string   `json:"landlord_user_type"`
-		LandlordStatus   string   `json:"landlord_account_status"`
-		Images           []string `json:"images"`
-		Videos           []string `json:"videos"`
-		Amenities        []string `json:"amenities"`
-		HouseRules       []string `json:"house_rules"`
-		InquiriesCount   int64    `json:"inquiries_count"`
-	}
-
 	apartmentID := c.Params("id")
 
 	var apt model.Apartment
-	if err := middleware.DBConn.Where("id = ? AND (status = ? OR status = ?)", apartmentID, "Approved", "Pending").First(&apt).Error; err != nil {
+	if err := middleware.DBConn.Where("id = ? AND status IN ?", apartmentID, []string{"Approved", "Pending", "Resubmitted"}).First(&apt).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Apartment not found or not approved/pending",
 			"error":   err.Error(),
 		})
 	}
 
-	var landlord model.User
-	if err := middleware.DBConn.Where("uid = ?", apt.Uid).First(&landlord).Error; err != nil {
+	details, err := repository.LoadApartmentDetails(middleware.DBConn, []model.Apartment{apt})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Landlord not found",
+			"message": "Failed to fetch apartment details",
 			"error":   err.Error(),
 		})
 	}
-
-	// Fetch images
-	var images []model.ApartmentImage
-	middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&images)
-	imageUrls := make([]string, len(images))
-	for i, img := range images {
-		imageUrls[i] = img.ImageURL
-	}
-
-	// Fetch videos
-	var videos []model.ApartmentVideo
-	middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&videos)
-	videoUrls := make([]string, len(videos))
-	for i, vid := range videos {
-		videoUrls[i] = vid.VideoURL
+	if len(details) == 0 {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Landlord not found",
+		})
 	}
 
-	// Fetch amenities
-	var amenities []model.Amenity
-	middleware.DBConn.
-		Joins("JOIN apartment_amenities ON amenities.id = apartment_amenities.amenity_id").
-		Where("apartment_amenities.apartment_id = ?", apt.ID).
-		Find(&amenities)
-	amenityNames := make([]string, len(amenities))
-	for i, a := range amenities {
-		amenityNames[i] = a.Name
+	history, err := pricehistory.Load(middleware.DBConn, apt.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch price history",
+			"error":   err.Error(),
+		})
 	}
 
-	// Fetch house rules
-	var houseRules []model.HouseRule
-	middleware.DBConn.
-		Joins("JOIN apartment_house_rules ON house_rules.id = apartment_house_rules.house_rule_id").
-		Where("apartment_house_rules.apartment_id = ?", apt.ID).
-		Find(&houseRules)
-	ruleNames := make([]string, len(houseRules))
-	for i, r := range houseRules {
-		ruleNames[i] = r.Rule
+	var units []model.ApartmentUnit
+	if err := middleware.DBConn.Where("apartment_id = ?", apt.ID).Order("label").Find(&units).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch units",
+			"error":   err.Error(),
+		})
 	}
 
-	// Get inquiry count
-	var inquiryCount int64
-	middleware.DBConn.Model(&model.Inquiry{}).
-		Where("property_id = ?", apt.ID).
-		Count(&inquiryCount)
-
-	result := ApartmentDetails{
-		Apartment:        apt,
-		LandlordName:     landlord.Fullname,
-		LandlordEmail:    landlord.Email,
-		LandlordPhone:    landlord.PhoneNumber,
-		LandlordAddress:  landlord.Address,
-		LandlordValidID:  landlord.ValidID,
-		LandlordPhotoURL: landlord.PhotoURL,
-		LandlordUserType: landlord.UserType,
-		LandlordStatus:   landlord.AccountStatus,
-		Images:           imageUrls,
-		Videos:           videoUrls,
-		Amenities:        amenityNames,
-		HouseRules:       ruleNames,
-		InquiriesCount:   inquiryCount,
+	windows, err := availability.Upcoming(middleware.DBConn, []uint{apt.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch availability",
+			"error":   err.Error(),
+		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+	return c.Status(fiber.StatusOK).JSON(struct {
+		model.ApartmentDetails
+		PriceHistory []model.ApartmentPriceHistory `json:"price_history"`
+		Units        []model.ApartmentUnit         `json:"units"`
+		Availability []model.AvailabilityWindow    `json:"availability_windows"`
+	}{details[0], history, units, windows})
 }
 
-// SearchApartments returns apartments matching search criteria with full details
+// SearchApartments runs a ranked full-text search over the name, address,
+// landmarks and property type of approved listings. Trigram similarity lets
+// misspelled street and barangay names still match. Results accept the
+// filter-apartments filters and are paged with ?limit=&cursor=; ?sort= is
+// rank (default) or any of the filter-apartments sorts.
 func SearchApartments(c *fiber.Ctx) error {
 	type ApartmentDetails struct {
-		model.Apartment
-		LandlordName     string   `json:"landlord_name"`
-		LandlordEmail    string   `json:"landlord_email"`
-		LandlordPhone    string   `json:"landlord_phone"`
-		LandlordAddress  string   `json:"landlord_address"`
-		LandlordValidID  string   `json:"landlord_valid_id"`
-		LandlordPhotoURL string   `json:"landlord_photo_url"`
-		LandlordUserType string   `json:"landlord_user_type"`
-		LandlordStatus   string   `json:"landlord_account_status"`
-		Images           []string `json:"images"`
-		Videos           []string `json:"videos"`
-		Amenities        []string `json:"amenities"`
-		HouseRules       []string `json:"house_rules"`
-		InquiriesCount   int64    `json:"inquiries_count"`
+		model.ApartmentDetails
+		Rank      float64 `json:"rank"`
+		Highlight string  `json:"highlight"`
 	}
 
 	var req struct {
@@ -328,92 +192,46 @@ func SearchApartments(c *fiber.Ctx) error {
 		})
 	}
 
-	searchPattern := "%" + req.SearchTerm + "%"
-	var apartments []model.Apartment
-	if err := middleware.DBConn.Where("location_link ILIKE ?", searchPattern).Find(&apartments).Error; err != nil {
+	term := strings.TrimSpace(req.SearchTerm)
+	filter := parseListingFilter(c)
+	page, err := pagination.Parse(c, searchSorts(term, filter))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	hits, err := searchListings(filter, term, page)
+	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Database error",
 			"error":   err.Error(),
 		})
 	}
+	hits, meta := pagination.Finish(page, hits, func(h searchHit) pagination.Key { return h.Key })
 
-	results := make([]ApartmentDetails, 0, len(apartments))
-
-	for _, apt := range apartments {
-		var landlord model.User
-		if err := middleware.DBConn.Where("uid = ?", apt.Uid).First(&landlord).Error; err != nil {
-			continue
-		}
-
-		// Fetch images
-		var images []model.ApartmentImage
-		middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&images)
-		imageUrls := make([]string, len(images))
-		for i, img := range images {
-			imageUrls[i] = img.ImageURL
-		}
-
-		// Fetch videos
-		var videos []model.ApartmentVideo
-		middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&videos)
-		videoUrls := make([]string, len(videos))
-		for i, vid := range videos {
-			videoUrls[i] = vid.VideoURL
-		}
-
-		// Fetch amenities
-		var amenities []model.Amenity
-		middleware.DBConn.
-			Joins("JOIN apartment_amenities ON amenities.id = apartment_amenities.amenity_id").
-			Where("apartment_amenities.apartment_id = ?", apt.ID).
-			Find(&amenities)
-		amenityNames := make([]string, len(amenities))
-		for i, a := range amenities {
-			amenityNames[i] = a.Name
-		}
-
-		// Fetch house rules
-		var houseRules []model.HouseRule
-		middleware.DBConn.
-			Joins("JOIN apartment_house_rules ON house_rules.id = apartment_house_rules.house_rule_id").
-			Where("apartment_house_rules.apartment_id = ?", apt.ID).
-			Find(&houseRules)
-		ruleNames := make([]string, len(houseRules))
-		for i, r := range houseRules {
-			ruleNames[i] = r.Rule
-		}
-
-		// Get inquiry count
-		var inquiryCount int64
-		middleware.DBConn.Model(&model.Inquiry{}).
-			Where("apartment_id = ?", apt.ID).
-			Count(&inquiryCount)
+	apartments := make([]model.Apartment, len(hits))
+	hitByID := make(map[uint]searchHit, len(hits))
+	for i, hit := range hits {
+		apartments[i] = hit.Apartment
+		hitByID[hit.ID] = hit
+	}
 
-		results = append(results, ApartmentDetails{
-			Apartment:        apt,
-			LandlordName:     landlord.Fullname,
-			LandlordEmail:    landlord.Email,
-			LandlordPhone:    landlord.PhoneNumber,
-			LandlordAddress:  landlord.Address,
-			LandlordValidID:  landlord.ValidID,
-			LandlordPhotoURL: landlord.PhotoURL,
-			LandlordUserType: landlord.UserType,
-			LandlordStatus:   landlord.AccountStatus,
-			Images:           imageUrls,
-			Videos:           videoUrls,
-			Amenities:        amenityNames,
-			HouseRules:       ruleNames,
-			InquiriesCount:   inquiryCount,
+	details, err := repository.LoadApartmentDetails(middleware.DBConn, apartments)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database error",
+			"error":   err.Error(),
 		})
 	}
 
-	// Sort by most popular first
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].InquiriesCount > results[j].InquiriesCount
-	})
+	results := make([]ApartmentDetails, 0, len(details))
+	for _, d := range details {
+		hit := hitByID[d.ID]
+		results = append(results, ApartmentDetails{ApartmentDetails: d, Rank: hit.Rank, Highlight: hit.Highlight})
+	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
-		"message": "Apartments retrieved successfully",
-		"data":    results,
+		"message":    "Apartments retrieved successfully",
+		"data":       results,
+		"pagination": meta,
 	})
 }
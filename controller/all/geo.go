@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	kmPerDegreeLat  = 111.045
+	maxNearbyRadius = 100.0
+	maxGeoResults   = 500
+)
+
+// GeoApartment is an approved apartment with its distance from the search
+// point.
+type GeoApartment struct {
+	model.ApartmentDetails
+	DistanceKm float64 `json:"distance_km"`
+}
+
+func parseCoordinate(c *fiber.Ctx, key string, limit float64) (float64, error) {
+	value, err := strconv.ParseFloat(c.Query(key), 64)
+	if err != nil || math.IsNaN(value) || value < -limit || value > limit {
+		return 0, fmt.Errorf("%s must be a number between %g and %g", key, -limit, limit)
+	}
+	return value, nil
+}
+
+func geoLimit(c *fiber.Ctx) int {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > maxGeoResults {
+		limit = 100
+	}
+	return limit
+}
+
+// FetchNearbyApartments returns approved apartments within ?radius_km= (default
+// 5, max 100) of ?lat=&lng=, nearest first. It accepts the same filters as
+// FetchApprovedApartmentsForTenant.
+func FetchNearbyApartments(c *fiber.Ctx) error {
+	lat, err := parseCoordinate(c, "lat", 90)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	lng, err := parseCoordinate(c, "lng", 180)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	radius := 5.0
+	if v := c.Query("radius_km"); v != "" {
+		if radius, err = strconv.ParseFloat(v, 64); err != nil || radius <= 0 || radius > maxNearbyRadius {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": fmt.Sprintf("radius_km must be between 0 and %g", maxNearbyRadius),
+			})
+		}
+	}
+
+	// A bounding box around the circle lets idx_geo narrow the rows before the
+	// exact distance is computed
+	latDelta := radius / kmPerDegreeLat
+	lngDelta := radius / (kmPerDegreeLat * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+
+	query := middleware.DBConn.Model(&model.Apartment{}).
+		Where("apartments.latitude BETWEEN ? AND ?", lat-latDelta, lat+latDelta).
+		Where("apartments.longitude BETWEEN ? AND ?", lng-lngDelta, lng+lngDelta).
+		Where(repository.HaversineSQL+" <= ?", lat, lat, lng, radius)
+
+	results, err := findGeoApartments(c, query, lat, lng)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch apartments",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"apartments": results,
+		"center":     fiber.Map{"lat": lat, "lng": lng},
+		"radius_km":  radius,
+	})
+}
+
+// FetchApartmentsInBounds returns approved apartments inside a map viewport
+// given by ?min_lat=&min_lng=&max_lat=&max_lng=, sorted by distance from
+// ?lat=&lng= or, when omitted, from the centre of the viewport.
+func FetchApartmentsInBounds(c *fiber.Ctx) error {
+	var bounds [4]float64
+	for i, key := range []string{"min_lat", "min_lng", "max_lat", "max_lng"} {
+		limit := 90.0
+		if i%2 == 1 {
+			limit = 180
+		}
+		value, err := parseCoordinate(c, key, limit)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+		}
+		bounds[i] = value
+	}
+	minLat, minLng, maxLat, maxLng := bounds[0], bounds[1], bounds[2], bounds[3]
+	if minLat > maxLat {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "min_lat must not be greater than max_lat"})
+	}
+
+	lat, lng := (minLat+maxLat)/2, (minLng+maxLng)/2
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		var err error
+		if lat, err = parseCoordinate(c, "lat", 90); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+		}
+		if lng, err = parseCoordinate(c, "lng", 180); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+
+	query := middleware.DBConn.Model(&model.Apartment{}).
+		Where("apartments.latitude BETWEEN ? AND ?", minLat, maxLat)
+	if minLng <= maxLng {
+		query = query.Where("apartments.longitude BETWEEN ? AND ?", minLng, maxLng)
+	} else {
+		// Viewport crossing the antimeridian
+		query = query.Where("(apartments.longitude >= ? OR apartments.longitude <= ?)", minLng, maxLng)
+	}
+
+	results, err := findGeoApartments(c, query, lat, lng)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch apartments",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"apartments": results,
+		"center":     fiber.Map{"lat": lat, "lng": lng},
+	})
+}
+
+// findGeoApartments runs a location query with the listing filters applied,
+// nearest first, and loads each result's details.
+func findGeoApartments(c *fiber.Ctx, query *gorm.DB, lat, lng float64) ([]GeoApartment, error) {
+	var rows []struct {
+		model.Apartment
+		DistanceKm float64
+	}
+	if err := parseListingFilter(c).Apply(query).
+		Select("apartments.*, "+repository.HaversineSQL+" AS distance_km", lat, lat, lng).
+		Where("apartments.status = ?", "Approved").
+		Where("(apartments.latitude <> 0 OR apartments.longitude <> 0)").
+		Order("distance_km ASC").
+		Limit(geoLimit(c)).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	apartments := make([]model.Apartment, len(rows))
+	distanceByID := make(map[uint]float64, len(rows))
+	for i, row := range rows {
+		apartments[i] = row.Apartment
+		distanceByID[row.ID] = row.DistanceKm
+	}
+
+	details, err := repository.LoadApartmentDetails(middleware.DBConn, apartments)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]GeoApartment, 0, len(details))
+	for _, d := range details {
+		results = append(results, GeoApartment{
+			ApartmentDetails: d,
+			DistanceKm:       math.Round(distanceByID[d.ID]*100) / 100,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+
+	"github.com/Conding-Student/backend/media"
+	"github.com/Conding-Student/backend/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	errUploadTooLarge = errors.New("upload is too large")
+	errMissingFile    = errors.New("upload has no file")
+)
+
+// UploadMedia stores one file sent as multipart/form-data with the fields
+// "purpose" (listing_image, listing_video, valid_id or business_permit) and
+// "file". The returned asset ID is then passed to CreateApartment,
+// UpdateApartmentMedia, RegisterLandlord or SetValidID. The body is read
+// from the request stream, never buffered whole.
+func UploadMedia(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized: Missing JWT claims"})
+	}
+	uid, ok := userClaims["uid"].(string)
+	if !ok || uid == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized: Invalid user UID"})
+	}
+
+	// Refuse oversized bodies before any of it is read
+	if c.Request().Header.ContentLength() > media.MaxUploadBytes+1<<20 {
+		c.Context().SetConnectionClose()
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"message": fmt.Sprintf("Uploads are limited to %d MB", media.MaxUploadBytes>>20),
+		})
+	}
+
+	purpose, f, size, err := readUpload(c)
+	if f != nil {
+		defer os.Remove(f.Name())
+		defer f.Close()
+	}
+	if err != nil {
+		// The rest of the body is unread, so the connection cannot be reused
+		c.Context().SetConnectionClose()
+	}
+	switch {
+	case errors.Is(err, errUploadTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"message": fmt.Sprintf("Uploads are limited to %d MB", media.MaxUploadBytes>>20),
+		})
+	case errors.Is(err, errMissingFile):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Missing file: send it as the multipart field \"file\""})
+	case err != nil:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Unable to read the uploaded file"})
+	}
+
+	asset, err := media.Store(c.UserContext(), middleware.DBConn, uid, purpose, f, size)
+	switch {
+	case errors.Is(err, media.ErrUnknownPurpose):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	case errors.Is(err, media.ErrTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": err.Error()})
+	case errors.Is(err, media.ErrUnsupportedType):
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"message": err.Error()})
+	case errors.Is(err, media.ErrBadDimensions):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"message": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to upload file",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "File uploaded",
+		"asset":   asset,
+	})
+}
+
+// readUpload reads the purpose and file fields from the multipart request
+// stream, spooling the file to a temp file that the caller must close and
+// remove. The whole body is bounded, so a client cannot stream past
+// media.MaxUploadBytes however it sends it.
+func readUpload(c *fiber.Ctx) (purpose string, f *os.File, size int64, err error) {
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	if boundary == "" {
+		return "", nil, 0, errMissingFile
+	}
+	// Without StreamRequestBody the body is already buffered
+	stream := c.Context().RequestBodyStream()
+	if stream == nil {
+		stream = bytes.NewReader(c.Body())
+	}
+
+	body := &io.LimitedReader{R: stream, N: media.MaxUploadBytes + 1<<20}
+	tooLarge := func(err error) error {
+		if body.N <= 0 {
+			return errUploadTooLarge
+		}
+		return err
+	}
+
+	form := multipart.NewReader(body, boundary)
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return purpose, f, size, tooLarge(err)
+		}
+
+		switch part.FormName() {
+		case "purpose":
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				return purpose, f, size, tooLarge(err)
+			}
+			purpose = string(value)
+		case "file":
+			if f != nil {
+				continue
+			}
+			if f, err = os.CreateTemp("", "upload-*"); err != nil {
+				return purpose, nil, 0, err
+			}
+			if size, err = io.Copy(f, io.LimitReader(part, media.MaxUploadBytes+1)); err != nil {
+				return purpose, f, size, tooLarge(err)
+			}
+			if size > media.MaxUploadBytes {
+				return purpose, f, size, errUploadTooLarge
+			}
+		}
+		part.Close()
+	}
+
+	if f == nil {
+		return purpose, nil, 0, errMissingFile
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return purpose, f, size, err
+}
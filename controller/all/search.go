@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/pagination"
+	"github.com/Conding-Student/backend/repository"
+
+	"gorm.io/gorm"
+)
+
+// searchSimilarityThreshold is the pg_trgm word similarity a misspelled term
+// needs to still count as a match.
+const searchSimilarityThreshold = "0.3"
+
+// searchHeadlineOptions wraps matched words in <mark> for the snippet.
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=3, MaxWords=15, FragmentDelimiter=\" … \""
+
+type searchHit struct {
+	model.Apartment
+	pagination.Key
+	Rank      float64
+	Highlight string
+}
+
+// searchRankSQL scores how well an apartment matches the term, which it
+// takes twice.
+const searchRankSQL = "(ts_rank(apartments.search_vector, websearch_to_tsquery('simple', ?)) + " +
+	"word_similarity(?, apartments.search_document))"
+
+// searchSorts adds ?sort=rank, the best matches first, to the listing sorts
+// and makes it the default.
+func searchSorts(term string, filter repository.ListingFilter) pagination.Sorts {
+	sorts := repository.ApartmentSorts(nil, filter)
+	sorts.Fields["rank"] = pagination.Field{Column: searchRankSQL, Args: []interface{}{term, term}, Type: pagination.TypeNumber, Desc: true}
+	sorts.Default = "rank"
+	return sorts
+}
+
+// searchListings matches term against the apartments.search_vector
+// (full text) and apartments.search_document (trigram) columns, which
+// Postgres keeps up to date as listings are created and edited. Featured
+// apartments come first, then the page's sort.
+func searchListings(filter repository.ListingFilter, term string, page pagination.Params) ([]searchHit, error) {
+	var hits []searchHit
+
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", searchSimilarityThreshold).Error; err != nil {
+			return err
+		}
+
+		query := filter.Apply(tx.Model(&model.Apartment{})).
+			Where("apartments.status = ?", "Approved").
+			Where("(apartments.search_vector @@ websearch_to_tsquery('simple', @term) "+
+				"OR @term <% apartments.search_document "+
+				"OR apartments.location_link ILIKE '%' || @term || '%')", map[string]interface{}{"term": term})
+
+		columns := "apartments.*, " + searchRankSQL + " AS rank, " +
+			"ts_headline('simple', apartments.property_name || ' — ' || apartments.address || ' — ' || apartments.landmarks, " +
+			"websearch_to_tsquery('simple', ?), ?) AS highlight"
+		return page.Apply(page.Select(query, columns, term, term, term, searchHeadlineOptions)).Find(&hits).Error
+	})
+	return hits, err
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Conding-Student/backend/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	suggestMinLength = 2
+	suggestMaxLength = 50
+	suggestPerType   = 5
+	suggestCacheTTL  = time.Minute
+	suggestCacheSize = 1000
+)
+
+// Suggestion is one typeahead entry and how many approved listings it leads to.
+type Suggestion struct {
+	Type  string `json:"type"` // property_name, locality, landmark, amenity
+	Text  string `json:"text"`
+	Count int64  `json:"count"`
+}
+
+type suggestCacheEntry struct {
+	suggestions []Suggestion
+	expiresAt   time.Time
+}
+
+// suggestCache keeps recent answers so per-keystroke calls for the same
+// prefix do not hit the database again.
+var suggestCache = struct {
+	sync.Mutex
+	entries map[string]suggestCacheEntry
+}{entries: map[string]suggestCacheEntry{}}
+
+func cachedSuggestions(key string) ([]Suggestion, bool) {
+	suggestCache.Lock()
+	defer suggestCache.Unlock()
+
+	entry, ok := suggestCache.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.suggestions, true
+}
+
+func storeSuggestions(key string, suggestions []Suggestion) {
+	suggestCache.Lock()
+	defer suggestCache.Unlock()
+
+	now := time.Now()
+	if len(suggestCache.entries) >= suggestCacheSize {
+		for k, entry := range suggestCache.entries {
+			if now.After(entry.expiresAt) {
+				delete(suggestCache.entries, k)
+			}
+		}
+		// Still full: start over rather than track recency
+		if len(suggestCache.entries) >= suggestCacheSize {
+			suggestCache.entries = map[string]suggestCacheEntry{}
+		}
+	}
+	suggestCache.entries[key] = suggestCacheEntry{suggestions: suggestions, expiresAt: now.Add(suggestCacheTTL)}
+}
+
+// escapeLike makes user input safe to use inside an ILIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// suggestQueries return (text, count) pairs for one suggestion type. Each takes
+// the contains pattern, then the prefix pattern used to rank prefix matches
+// first, then the limit. Addresses and landmarks are split on commas so a
+// barangay or city is suggested on its own.
+var suggestQueries = []struct {
+	Type string
+	SQL  string
+}{
+	{"property_name", `
+		SELECT property_name AS text, COUNT(*) AS count
+		FROM apartments
+		WHERE status = 'Approved' AND property_name ILIKE ?
+		GROUP BY property_name
+		ORDER BY bool_or(property_name ILIKE ?) DESC, count DESC, text
+		LIMIT ?`},
+	{"locality", `
+		SELECT TRIM(part) AS text, COUNT(DISTINCT apartments.id) AS count
+		FROM apartments, unnest(string_to_array(apartments.address, ',')) AS part
+		WHERE apartments.status = 'Approved' AND TRIM(part) ILIKE ?
+		GROUP BY TRIM(part)
+		ORDER BY bool_or(TRIM(part) ILIKE ?) DESC, count DESC, text
+		LIMIT ?`},
+	{"landmark", `
+		SELECT TRIM(part) AS text, COUNT(DISTINCT apartments.id) AS count
+		FROM apartments, unnest(string_to_array(apartments.landmarks, ',')) AS part
+		WHERE apartments.status = 'Approved' AND TRIM(part) ILIKE ?
+		GROUP BY TRIM(part)
+		ORDER BY bool_or(TRIM(part) ILIKE ?) DESC, count DESC, text
+		LIMIT ?`},
+	{"amenity", `
+		SELECT amenities.name AS text, COUNT(DISTINCT apartments.id) AS count
+		FROM amenities
+		JOIN apartment_amenities ON apartment_amenities.amenity_id = amenities.id
+		JOIN apartments ON apartments.id = apartment_amenities.apartment_id
+		WHERE apartments.status = 'Approved' AND amenities.name ILIKE ?
+		GROUP BY amenities.name
+		ORDER BY bool_or(amenities.name ILIKE ?) DESC, count DESC, text
+		LIMIT ?`},
+}
+
+// SuggestSearch returns typed typeahead suggestions for ?q=: property names,
+// localities, landmarks and amenities, each with its count of approved
+// listings.
+func SuggestSearch(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(q) < suggestMinLength {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"query": q, "suggestions": []Suggestion{}})
+	}
+	if utf8.RuneCountInString(q) > suggestMaxLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Search term is too long"})
+	}
+
+	key := strings.ToLower(q)
+	if suggestions, ok := cachedSuggestions(key); ok {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"query": q, "suggestions": suggestions})
+	}
+
+	contains := "%" + escapeLike(q) + "%"
+	prefix := escapeLike(q) + "%"
+
+	suggestions := make([]Suggestion, 0, len(suggestQueries)*suggestPerType)
+	for _, sq := range suggestQueries {
+		var rows []Suggestion
+		if err := middleware.DBConn.Raw(sq.SQL, contains, prefix, suggestPerType).Scan(&rows).Error; err != nil {
+			log.Printf("[ERROR] Failed to load %s suggestions: %v", sq.Type, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to load suggestions"})
+		}
+		for _, row := range rows {
+			row.Type = sq.Type
+			suggestions = append(suggestions, row)
+		}
+	}
+
+	storeSuggestions(key, suggestions)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"query": q, "suggestions": suggestions})
+}
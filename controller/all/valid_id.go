@@ -1,18 +1,21 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/Conding-Student/backend/media"
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
-// Struct for parsing valid ID URL request
+// Struct for parsing valid ID request
 type ValidIDRequest struct {
-	ValidID string `json:"valid_id"` // URL of the valid ID image
+	ValidIDAssetID *uint `json:"valid_id_asset_id"` // image uploaded to /media/uploads
 }
 
 // ✅ Function to insert/update valid ID for the user based on UID
@@ -43,9 +46,9 @@ func SetValidID(c *fiber.Ctx) error {
 	}
 
 	// 📌 Validate required fields
-	if req.ValidID == "" {
+	if req.ValidIDAssetID == nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"message": "Missing required field: valid_id",
+			"message": "Missing required field: valid_id_asset_id (upload the image to /media/uploads first)",
 		})
 	}
 
@@ -60,8 +63,18 @@ func SetValidID(c *fiber.Ctx) error {
 	}
 
 	// 🔄 Update the user's valid ID image URL
-	user.ValidID = req.ValidID
-	if err := middleware.DBConn.Save(&user).Error; err != nil {
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		assets, err := media.Claim(tx, uid, []uint{*req.ValidIDAssetID}, media.PurposeValidID)
+		if err != nil {
+			return err
+		}
+		user.ValidID = assets[0].URL
+		return tx.Save(&user).Error
+	})
+	if errors.Is(err, media.ErrAssetUnavailable) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Database error: Unable to update valid ID",
 			"error":   err.Error(),
@@ -73,7 +86,7 @@ func SetValidID(c *fiber.Ctx) error {
 		"message": "Valid ID updated successfully",
 		"data": fiber.Map{
 			"uid":      uid,
-			"valid_id": req.ValidID,
+			"valid_id": user.ValidID,
 		},
 	})
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/service"
 
 	"firebase.google.com/go/v4/auth"
 	"github.com/gofiber/fiber/v2"
@@ -93,7 +94,7 @@ func VerifyFirebaseTokenAdmin(c *fiber.Ctx) error {
 	}
 
 	// 🔑 Generate JWT
-	newJWT, err := middleware.GenerateJWT(uid, email, role)
+	tokens, err := service.Tokens.IssuePair(uid, email, role)
 	if err != nil {
 		log.Printf("[ERROR] JWT generation failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -103,11 +104,13 @@ func VerifyFirebaseTokenAdmin(c *fiber.Ctx) error {
 
 	log.Printf("✅ Successful admin authentication: %s (%s)", email, uid)
 	return c.JSON(fiber.Map{
-		"message":      "Authentication successful",
-		"uid":          uid,
-		"email":        email,
-		"role":         role,
-		"access_token": newJWT,
+		"message":       "Authentication successful",
+		"uid":           uid,
+		"email":         email,
+		"role":          role,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
 
@@ -203,7 +206,7 @@ func VerifyFirebaseToken(c *fiber.Ctx) error {
     }
 
     // Generate JWT
-    jwtToken, err := middleware.GenerateJWT(uid, email, role)
+    tokens, err := service.Tokens.IssuePair(uid, email, role)
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "error": "Token generation failed",
@@ -211,12 +214,14 @@ func VerifyFirebaseToken(c *fiber.Ctx) error {
     }
 
     return c.JSON(fiber.Map{
-        "uid":          uid,
-        "email":        email,
-        "fullname":     fullName,
-        "photo_url":    photoUrl,
-        "role":         role,
-        "access_token": jwtToken,
+        "uid":           uid,
+        "email":         email,
+        "fullname":      fullName,
+        "photo_url":     photoUrl,
+        "role":          role,
+        "access_token":  tokens.AccessToken,
+        "refresh_token": tokens.RefreshToken,
+        "expires_in":    tokens.ExpiresIn,
     })
 }
 
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"errors"
+	"log"
+
+	"github.com/Conding-Student/backend/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access/refresh pair.
+// The presented refresh token is revoked; presenting it again ends the session.
+func RefreshTokenHandler(c *fiber.Ctx) error {
+	var req refreshTokenRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "refresh_token is required",
+		})
+	}
+
+	tokens, err := service.Tokens.Refresh(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrRefreshTokenReused) {
+			log.Println("[WARN] Refresh token reuse detected, session revoked")
+		}
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) ||
+			errors.Is(err, service.ErrAccountDisabled) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		log.Printf("[ERROR] Token refresh failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Token refresh failed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// LogoutHandler revokes the refresh token and every token rotated from the
+// same login. Access tokens already issued stay valid until they expire.
+func LogoutHandler(c *fiber.Ctx) error {
+	var req refreshTokenRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "refresh_token is required",
+		})
+	}
+
+	if err := service.Tokens.Revoke(req.RefreshToken); err != nil {
+		log.Printf("[ERROR] Logout failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Logout failed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
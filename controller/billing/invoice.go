@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	rootcontroller "github.com/Conding-Student/backend/controller"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Payments is the payment service invoices are paid through, set when the
+// billing routes are registered.
+var Payments *rootcontroller.PayMongoService
+
+type invoiceResponse struct {
+	model.Invoice
+	PropertyName string `json:"property_name"`
+}
+
+func invoiceQuery() *gorm.DB {
+	return middleware.DBConn.Table("invoices").
+		Select("invoices.*, apartments.property_name").
+		Joins("LEFT JOIN apartments ON apartments.id = invoices.apartment_id").
+		Order("invoices.period_start DESC, invoices.id DESC")
+}
+
+// FetchTenantInvoices lists the logged-in tenant's invoices, optionally
+// filtered by ?status=unpaid|overdue|paid.
+func FetchTenantInvoices(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	tenantID, _ := userClaims["uid"].(string)
+
+	query := invoiceQuery().Where("invoices.tenant_id = ?", tenantID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("invoices.status = ?", status)
+	}
+
+	var invoices []invoiceResponse
+	if err := query.Scan(&invoices).Error; err != nil {
+		log.Printf("[ERROR] Failed to fetch tenant invoices: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch invoices"})
+	}
+
+	return c.JSON(fiber.Map{"invoices": invoices})
+}
+
+// FetchLandlordInvoices lists invoices for the logged-in landlord's
+// agreements, optionally filtered by ?status= and ?apartment_id=.
+func FetchLandlordInvoices(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	landlordID, _ := userClaims["uid"].(string)
+
+	query := invoiceQuery().Where("invoices.landlord_id = ?", landlordID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("invoices.status = ?", status)
+	}
+	if apartmentID := c.Query("apartment_id"); apartmentID != "" {
+		id, err := strconv.Atoi(apartmentID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid apartment_id"})
+		}
+		query = query.Where("invoices.apartment_id = ?", id)
+	}
+
+	var invoices []invoiceResponse
+	if err := query.Scan(&invoices).Error; err != nil {
+		log.Printf("[ERROR] Failed to fetch landlord invoices: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch invoices"})
+	}
+
+	return c.JSON(fiber.Map{"invoices": invoices})
+}
+
+// PayInvoice starts a checkout for an unpaid or overdue invoice through the
+// regular payment flow and links the resulting transaction to it. While an
+// earlier checkout for the invoice is still open it answers 409 instead.
+func PayInvoice(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	tenantID, _ := userClaims["uid"].(string)
+
+	var req struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = payment.MethodGCash
+	}
+
+	// Lock the invoice so two requests cannot both open a checkout for it
+	tx := middleware.DBConn.Begin()
+	defer tx.Rollback()
+
+	var invoice model.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&invoice).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Invoice not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Database error"})
+	}
+
+	if invoice.Status != "unpaid" && invoice.Status != "overdue" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invoice is already " + invoice.Status})
+	}
+
+	// A checkout that is still open may yet be paid; the reconciler settles
+	// abandoned ones, after which the tenant can try again
+	var open model.Transaction
+	err := tx.Where("invoice_id = ? AND status IN ?", invoice.ID, rootcontroller.UnsettledStatuses).
+		Order("id DESC").First(&open).Error
+	if err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":        "A payment for this invoice is already in progress",
+			"transaction_id": open.ID,
+		})
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Database error"})
+	}
+
+	apartmentID := invoice.ApartmentID
+	txn, checkout, err := Payments.StartCheckout(c.UserContext(), rootcontroller.CheckoutInput{
+		UserID:        tenantID,
+		BaseAmount:    invoice.TotalDue,
+		Availment:     "Rent Invoice",
+		PaymentMethod: req.PaymentMethod,
+		ApartmentID:   &apartmentID,
+		InvoiceID:     &invoice.ID,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to start checkout for invoice %d: %v", invoice.ID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"message": "Failed to start payment",
+			"details": err.Error(),
+		})
+	}
+
+	if err := tx.Model(&invoice).Update("transaction_id", txn.ID).Error; err != nil {
+		log.Printf("[ERROR] Failed to link transaction %d to invoice %d: %v", txn.ID, invoice.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Database error"})
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("[ERROR] Failed to link transaction %d to invoice %d: %v", txn.ID, invoice.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Database error"})
+	}
+
+	resp := fiber.Map{
+		"invoice_id":     invoice.ID,
+		"transaction_id": txn.ID,
+		"checkout_url":   checkout.CheckoutURL,
+		"source_id":      checkout.ID,
+		"payment_method": txn.PaymentMethod,
+		"total_amount":   txn.TotalAmount,
+	}
+	if checkout.Kind == payment.KindPaymentIntent {
+		resp["client_key"] = checkout.ClientKey
+	}
+	return c.JSON(resp)
+}
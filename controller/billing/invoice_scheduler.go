@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// invoiceDueDays is how many days after the period start rent is due;
+// invoiceLateFeeRate is the share of rent added once an invoice is overdue.
+func invoiceDueDays() int {
+	if days, err := strconv.Atoi(os.Getenv("INVOICE_DUE_DAYS")); err == nil && days >= 0 {
+		return days
+	}
+	return 5
+}
+
+func invoiceLateFeeRate() float64 {
+	if rate, err := strconv.ParseFloat(os.Getenv("INVOICE_LATE_FEE_RATE"), 64); err == nil && rate >= 0 {
+		return rate
+	}
+	return 0.05
+}
+
+// billingPeriod returns the monthly period, anchored on the agreement's start
+// day, that contains now. In months without that day the period starts on
+// the last day of the month instead, so a Jan 31 start bills Feb 28.
+func billingPeriod(start, now time.Time) (periodStart, periodEnd time.Time) {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, now.Location())
+	months := (now.Year()-start.Year())*12 + int(now.Month()-start.Month())
+	periodStart = addMonthsClamped(start, months)
+	if periodStart.After(now) {
+		months--
+		periodStart = addMonthsClamped(start, months)
+	}
+	periodEnd = addMonthsClamped(start, months+1).AddDate(0, 0, -1)
+	return periodStart, periodEnd
+}
+
+// addMonthsClamped adds months to t, keeping its day but clamping it to the
+// last day of the target month instead of overflowing into the next one.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return firstOfMonth.AddDate(0, 0, min(t.Day(), lastDay)-1)
+}
+
+// GenerateInvoices creates the current month's invoice for every confirmed,
+// active agreement that does not have one yet. It is safe to run repeatedly.
+func GenerateInvoices(db *gorm.DB, now time.Time) (int64, error) {
+	var agreements []struct {
+		ID          uint
+		ApartmentID uint
+		TenantID    string
+		LandlordID  string
+		StartDate   time.Time
+		EndDate     *time.Time
+		RentPrice   float64
+	}
+	if err := db.Table("rental_agreements").
+		Select("rental_agreements.id, rental_agreements.apartment_id, rental_agreements.tenant_id, "+
+			"rental_agreements.landlord_id, rental_agreements.start_date, rental_agreements.end_date, "+
+			"COALESCE(apartment_units.rent_price, apartments.rent_price) AS rent_price").
+		Joins("JOIN apartments ON apartments.id = rental_agreements.apartment_id").
+		Joins("LEFT JOIN apartment_units ON apartment_units.id = rental_agreements.unit_id").
+		Where("rental_agreements.tenant_confirmed = ? AND rental_agreements.landlord_confirmed = ?", true, true).
+		Where("rental_agreements.is_active = ? AND rental_agreements.start_date <= ?", true, now).
+		Scan(&agreements).Error; err != nil {
+		return 0, fmt.Errorf("failed to load agreements: %v", err)
+	}
+
+	dueDays := invoiceDueDays()
+	var created int64
+	for _, agreement := range agreements {
+		periodStart, periodEnd := billingPeriod(agreement.StartDate, now)
+		if agreement.EndDate != nil && !periodStart.Before(*agreement.EndDate) {
+			continue
+		}
+		if agreement.RentPrice <= 0 {
+			continue
+		}
+
+		invoice := model.Invoice{
+			RentalAgreementID: agreement.ID,
+			ApartmentID:       agreement.ApartmentID,
+			TenantID:          agreement.TenantID,
+			LandlordID:        agreement.LandlordID,
+			PeriodStart:       periodStart,
+			PeriodEnd:         periodEnd,
+			RentAmount:        agreement.RentPrice,
+			TotalDue:          agreement.RentPrice,
+			DueDate:           periodStart.AddDate(0, 0, dueDays),
+			Status:            "unpaid",
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&invoice)
+		if result.Error != nil {
+			log.Printf("[ERROR] Failed to create invoice for agreement %d: %v", agreement.ID, result.Error)
+			continue
+		}
+		created += result.RowsAffected
+	}
+	return created, nil
+}
+
+// MarkOverdueInvoices flags unpaid invoices past their due date and adds the
+// late fee once.
+func MarkOverdueInvoices(db *gorm.DB, now time.Time) (int64, error) {
+	rate := invoiceLateFeeRate()
+	result := db.Model(&model.Invoice{}).
+		Where("status = ? AND due_date < ?", "unpaid", now).
+		Updates(map[string]interface{}{
+			"status":     "overdue",
+			"late_fee":   gorm.Expr("ROUND(rent_amount * ?, 2)", rate),
+			"total_due":  gorm.Expr("rent_amount + ROUND(rent_amount * ?, 2)", rate),
+			"updated_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// ManageRentInvoices generates monthly invoices and applies late fees in the
+// background.
+func ManageRentInvoices() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		currentTime := time.Now()
+		fmt.Printf("[%s] Starting rent invoice cycle\n", currentTime.Format(time.RFC3339))
+
+		if created, err := GenerateInvoices(middleware.DBConn, currentTime); err != nil {
+			fmt.Printf("[%s] Error generating invoices: %v\n", currentTime.Format(time.RFC3339), err)
+		} else if created > 0 {
+			fmt.Printf("[%s] Generated %d rent invoices\n", currentTime.Format(time.RFC3339), created)
+		}
+
+		if overdue, err := MarkOverdueInvoices(middleware.DBConn, currentTime); err != nil {
+			fmt.Printf("[%s] Error marking overdue invoices: %v\n", currentTime.Format(time.RFC3339), err)
+		} else if overdue > 0 {
+			fmt.Printf("[%s] Marked %d invoices overdue\n", currentTime.Format(time.RFC3339), overdue)
+		}
+
+		<-ticker.C
+	}
+}
+
+// SettleInvoice is registered as a paid hook: when a transaction started
+// for an invoice is paid, the invoice is marked paid in the same DB
+// transaction. The invoice is found through the transaction's InvoiceID, so
+// any checkout the tenant started for it settles it, not only the latest.
+// The amount charged is the total due when that checkout started.
+func SettleInvoice(tx *gorm.DB, txn *model.Transaction) error {
+	query := tx.Model(&model.Invoice{}).Where("status IN ?", []string{"unpaid", "overdue"})
+	if txn.InvoiceID != nil {
+		query = query.Where("id = ?", *txn.InvoiceID)
+	} else {
+		// Checkouts started before transactions recorded their invoice
+		query = query.Where("transaction_id = ?", txn.ID)
+	}
+
+	now := time.Now()
+	result := query.Updates(map[string]interface{}{
+		"status":         "paid",
+		"paid_at":        now,
+		"transaction_id": txn.ID,
+		"updated_at":     now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✅ Invoice for transaction %d settled (%.2f)", txn.ID, txn.BaseAmount)
+	} else if txn.InvoiceID != nil {
+		log.Printf("⚠️ Transaction %d paid invoice %d, which was already settled", txn.ID, *txn.InvoiceID)
+	}
+	return nil
+}
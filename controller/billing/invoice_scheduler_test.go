@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBillingPeriod(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name      string
+		start     time.Time
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"mid-month start", day(2026, 1, 15), day(2026, 3, 20), day(2026, 3, 15), day(2026, 4, 14)},
+		{"before this month's anniversary", day(2026, 1, 15), day(2026, 3, 10), day(2026, 2, 15), day(2026, 3, 14)},
+		{"Jan 31 bills February", day(2026, 1, 31), day(2026, 2, 28), day(2026, 2, 28), day(2026, 3, 30)},
+		{"Jan 31 before February's last day", day(2026, 1, 31), day(2026, 2, 27), day(2026, 1, 31), day(2026, 2, 27)},
+		{"back to the 31st in March", day(2026, 1, 31), day(2026, 3, 31), day(2026, 3, 31), day(2026, 4, 29)},
+		{"leap year", day(2028, 1, 31), day(2028, 2, 29), day(2028, 2, 29), day(2028, 3, 30)},
+		{"30th across the new year", day(2025, 11, 30), day(2026, 1, 5), day(2025, 12, 30), day(2026, 1, 29)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStart, gotEnd := billingPeriod(tt.start, tt.now.Add(12*time.Hour))
+			if !gotStart.Equal(tt.wantStart) || !gotEnd.Equal(tt.wantEnd) {
+				t.Errorf("got %s to %s, want %s to %s",
+					gotStart.Format(time.DateOnly), gotEnd.Format(time.DateOnly),
+					tt.wantStart.Format(time.DateOnly), tt.wantEnd.Format(time.DateOnly))
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	rootcontroller "github.com/Conding-Student/backend/controller"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+	"github.com/Conding-Student/backend/testdb"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPayInvoiceRejectsSecondCheckout(t *testing.T) {
+	db := testdb.Open(t)
+	t.Setenv("PAYMENT_PROVIDER", "fake")
+
+	cfg := payment.ConfigFromEnv()
+	saved := Payments
+	Payments = &rootcontroller.PayMongoService{DB: db, Provider: payment.NewProvider(cfg), Config: cfg}
+	t.Cleanup(func() { Payments = saved })
+
+	now := time.Now()
+	invoice := model.Invoice{
+		RentalAgreementID: 1, ApartmentID: 1, TenantID: "tenant-1", LandlordID: "landlord-1",
+		PeriodStart: now, PeriodEnd: now.AddDate(0, 1, 0), RentAmount: 5000, TotalDue: 5000,
+		DueDate: now.AddDate(0, 0, 5), Status: "unpaid",
+	}
+	if err := db.Create(&invoice).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Post("/tenant/invoices/:id/pay", middleware.AuthMiddleware, PayInvoice)
+	token := testdb.Token(t, "tenant-1", "Tenant")
+
+	pay := func() (int, uint) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/tenant/invoices/%d/pay", invoice.ID), nil)
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			TransactionID uint `json:"transaction_id"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.TransactionID
+	}
+
+	status, first := pay()
+	if status != http.StatusOK || first == 0 {
+		t.Fatalf("first pay: got %d with transaction %d, want 200", status, first)
+	}
+	status, second := pay()
+	if status != http.StatusConflict || second != first {
+		t.Errorf("second pay: got %d with transaction %d, want 409 with %d", status, second, first)
+	}
+
+	var checkouts int64
+	db.Model(&model.Transaction{}).Where("invoice_id = ?", invoice.ID).Count(&checkouts)
+	if checkouts != 1 {
+		t.Errorf("opened %d checkouts for the invoice, want 1", checkouts)
+	}
+
+	// Once the open checkout fails, paying again starts a new one
+	db.Model(&model.Transaction{}).Where("id = ?", first).Update("status", "failed")
+	if status, third := pay(); status != http.StatusOK || third == first {
+		t.Errorf("pay after failure: got %d with transaction %d, want 200 with a new one", status, third)
+	}
+}
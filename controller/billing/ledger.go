@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Conding-Student/backend/ledger"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const ledgerDateLayout = "2006-01-02"
+
+// parsePeriod reads ?from= and ?to= (YYYY-MM-DD, both inclusive). Missing
+// bounds default to the start of the current month and today.
+func parsePeriod(c *fiber.Ctx) (from, to time.Time, err error) {
+	now := time.Now()
+	from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation(ledgerDateLayout, v, now.Location()); err != nil {
+			return from, to, fmt.Errorf("invalid from date, use YYYY-MM-DD")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.ParseInLocation(ledgerDateLayout, v, now.Location()); err != nil {
+			return from, to, fmt.Errorf("invalid to date, use YYYY-MM-DD")
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to must not be before from")
+	}
+	// Make the end exclusive so the whole "to" day is included
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+type statementLine struct {
+	EntryID       uint      `json:"entry_id"`
+	JournalID     uint      `json:"journal_id"`
+	Kind          string    `json:"kind"`
+	TransactionID *uint     `json:"transaction_id"`
+	RefundID      *uint     `json:"refund_id"`
+	PayoutID      *uint     `json:"payout_id"`
+	Memo          string    `json:"memo"`
+	Debit         float64   `json:"debit"`
+	Credit        float64   `json:"credit"`
+	Balance       float64   `json:"balance"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// FetchLandlordBalance returns the logged-in landlord's current balance and
+// their statement for ?from=&to= with a running balance per line.
+func FetchLandlordBalance(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	landlordID, _ := userClaims["uid"].(string)
+
+	from, to, err := parsePeriod(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	balance, err := ledger.LandlordBalance(middleware.DBConn, landlordID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load balance for %s: %v", landlordID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to load balance"})
+	}
+
+	var opening float64
+	if err := middleware.DBConn.Model(&model.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("account = ? AND landlord_id = ? AND created_at < ?", ledger.AccountLandlordPayable, landlordID, from).
+		Scan(&opening).Error; err != nil {
+		log.Printf("[ERROR] Failed to load opening balance for %s: %v", landlordID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to load statement"})
+	}
+
+	var lines []statementLine
+	if err := middleware.DBConn.Table("ledger_entries").
+		Select("ledger_entries.id AS entry_id, ledger_entries.journal_id, ledger_journals.kind, "+
+			"ledger_journals.transaction_id, ledger_journals.refund_id, ledger_journals.payout_id, ledger_journals.memo, "+
+			"ledger_entries.debit, ledger_entries.credit, ledger_entries.created_at").
+		Joins("JOIN ledger_journals ON ledger_journals.id = ledger_entries.journal_id").
+		Where("ledger_entries.account = ? AND ledger_entries.landlord_id = ?", ledger.AccountLandlordPayable, landlordID).
+		Where("ledger_entries.created_at >= ? AND ledger_entries.created_at < ?", from, to).
+		Order("ledger_entries.created_at ASC, ledger_entries.id ASC").
+		Scan(&lines).Error; err != nil {
+		log.Printf("[ERROR] Failed to load statement for %s: %v", landlordID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to load statement"})
+	}
+
+	running := opening
+	for i := range lines {
+		running = math.Round((running+lines[i].Credit-lines[i].Debit)*100) / 100
+		lines[i].Balance = running
+	}
+
+	return c.JSON(fiber.Map{
+		"balance":         balance,
+		"from":            from.Format(ledgerDateLayout),
+		"to":              to.AddDate(0, 0, -1).Format(ledgerDateLayout),
+		"opening_balance": math.Round(opening*100) / 100,
+		"closing_balance": running,
+		"lines":           lines,
+	})
+}
+
+// RecordPayout lets an admin record money sent to a landlord. The payout is
+// posted to the ledger in the same DB transaction and may not exceed the
+// landlord's balance.
+func RecordPayout(c *fiber.Ctx) error {
+	var req struct {
+		LandlordID string  `json:"landlord_id"`
+		Amount     float64 `json:"amount"`
+		Method     string  `json:"method"`
+		Reference  string  `json:"reference"`
+		Notes      string  `json:"notes"`
+		PaidAt     string  `json:"paid_at"` // YYYY-MM-DD, defaults to today
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if req.LandlordID == "" || req.Method == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "landlord_id and method are required"})
+	}
+	req.Amount = math.Round(req.Amount*100) / 100
+	if req.Amount <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Amount must be positive"})
+	}
+
+	paidAt := time.Now()
+	if req.PaidAt != "" {
+		parsed, err := time.ParseInLocation(ledgerDateLayout, req.PaidAt, paidAt.Location())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid paid_at, use YYYY-MM-DD"})
+		}
+		paidAt = parsed
+	}
+
+	var landlord model.User
+	if err := middleware.DBConn.Where("uid = ? AND user_type = ?", req.LandlordID, "Landlord").First(&landlord).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Landlord not found"})
+	}
+
+	adminUID := ""
+	if claims, ok := c.Locals("user").(jwt.MapClaims); ok {
+		adminUID, _ = claims["uid"].(string)
+	}
+
+	payout := model.Payout{
+		LandlordID: req.LandlordID,
+		Amount:     req.Amount,
+		Method:     req.Method,
+		Reference:  req.Reference,
+		Notes:      req.Notes,
+		RecordedBy: adminUID,
+		PaidAt:     paidAt,
+	}
+
+	var balance float64
+	errInsufficient := fmt.Errorf("insufficient balance")
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		// Serialize payouts per landlord so two admins cannot overdraw a balance
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "payout:"+req.LandlordID).Error; err != nil {
+			return err
+		}
+		var err error
+		if balance, err = ledger.LandlordBalance(tx, req.LandlordID); err != nil {
+			return err
+		}
+		if req.Amount > balance {
+			return errInsufficient
+		}
+		if err := tx.Create(&payout).Error; err != nil {
+			return err
+		}
+		return ledger.PostPayout(tx, &payout)
+	})
+	if err == errInsufficient {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Payout exceeds landlord balance",
+			"balance": balance,
+		})
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to record payout for %s: %v", req.LandlordID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to record payout"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Payout recorded",
+		"payout":  payout,
+		"balance": math.Round((balance-payout.Amount)*100) / 100,
+	})
+}
+
+// FetchPayouts lists recorded payouts, optionally for one ?landlord_id=.
+func FetchPayouts(c *fiber.Ctx) error {
+	query := middleware.DBConn.Order("paid_at DESC, id DESC")
+	if landlordID := c.Query("landlord_id"); landlordID != "" {
+		query = query.Where("landlord_id = ?", landlordID)
+	}
+
+	var payouts []model.Payout
+	if err := query.Find(&payouts).Error; err != nil {
+		log.Printf("[ERROR] Failed to fetch payouts: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch payouts"})
+	}
+	return c.JSON(fiber.Map{"payouts": payouts})
+}
+
+type reconciliationRow struct {
+	LandlordID string  `json:"landlord_id"`
+	Fullname   string  `json:"fullname"`
+	Opening    float64 `json:"opening"`
+	Collected  float64 `json:"collected"`
+	Refunded   float64 `json:"refunded"`
+	Payouts    float64 `json:"payouts"`
+	Closing    float64 `json:"closing"`
+}
+
+// ReconciliationReport summarizes every landlord's payable account for
+// ?from=&to=: opening balance, rent collected, refunds, payouts and closing
+// balance, plus the platform's cash and fee revenue movements. Pass
+// ?format=csv to download it.
+func ReconciliationReport(c *fiber.Ctx) error {
+	from, to, err := parsePeriod(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var rows []reconciliationRow
+	if err := middleware.DBConn.Table("ledger_entries").
+		Select("ledger_entries.landlord_id, COALESCE(MAX(users.fullname), '') AS fullname, "+
+			"COALESCE(SUM(CASE WHEN ledger_entries.created_at < ? THEN ledger_entries.credit - ledger_entries.debit ELSE 0 END), 0) AS opening, "+
+			"COALESCE(SUM(CASE WHEN ledger_entries.created_at >= ? AND ledger_journals.kind = 'payment' THEN ledger_entries.credit - ledger_entries.debit ELSE 0 END), 0) AS collected, "+
+			"COALESCE(SUM(CASE WHEN ledger_entries.created_at >= ? AND ledger_journals.kind = 'refund' THEN ledger_entries.debit - ledger_entries.credit ELSE 0 END), 0) AS refunded, "+
+			"COALESCE(SUM(CASE WHEN ledger_entries.created_at >= ? AND ledger_journals.kind = 'payout' THEN ledger_entries.debit - ledger_entries.credit ELSE 0 END), 0) AS payouts",
+			from, from, from, from).
+		Joins("JOIN ledger_journals ON ledger_journals.id = ledger_entries.journal_id").
+		Joins("LEFT JOIN users ON users.uid = ledger_entries.landlord_id").
+		Where("ledger_entries.account = ? AND ledger_entries.created_at < ?", ledger.AccountLandlordPayable, to).
+		Group("ledger_entries.landlord_id").
+		Order("ledger_entries.landlord_id").
+		Scan(&rows).Error; err != nil {
+		log.Printf("[ERROR] Failed to build reconciliation report: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to build report"})
+	}
+
+	var totals reconciliationRow
+	for i := range rows {
+		r := &rows[i]
+		r.Closing = math.Round((r.Opening+r.Collected-r.Refunded-r.Payouts)*100) / 100
+		totals.Opening += r.Opening
+		totals.Collected += r.Collected
+		totals.Refunded += r.Refunded
+		totals.Payouts += r.Payouts
+		totals.Closing += r.Closing
+	}
+
+	var platform []struct {
+		Account string  `json:"account"`
+		Debit   float64 `json:"debit"`
+		Credit  float64 `json:"credit"`
+	}
+	if err := middleware.DBConn.Model(&model.LedgerEntry{}).
+		Select("account, COALESCE(SUM(debit), 0) AS debit, COALESCE(SUM(credit), 0) AS credit").
+		Where("account IN ? AND created_at >= ? AND created_at < ?",
+			[]string{ledger.AccountPlatformCash, ledger.AccountPlatformFeeRevenue}, from, to).
+		Group("account").
+		Scan(&platform).Error; err != nil {
+		log.Printf("[ERROR] Failed to load platform totals: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to build report"})
+	}
+
+	fromLabel, toLabel := from.Format(ledgerDateLayout), to.AddDate(0, 0, -1).Format(ledgerDateLayout)
+
+	if c.Query("format") == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"reconciliation_%s_%s.csv\"", fromLabel, toLabel))
+
+		w := csv.NewWriter(c)
+		money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+		w.Write([]string{"landlord_id", "fullname", "opening", "collected", "refunded", "payouts", "closing"})
+		for _, r := range rows {
+			w.Write([]string{r.LandlordID, r.Fullname, money(r.Opening), money(r.Collected), money(r.Refunded), money(r.Payouts), money(r.Closing)})
+		}
+		w.Write([]string{"TOTAL", "", money(totals.Opening), money(totals.Collected), money(totals.Refunded), money(totals.Payouts), money(totals.Closing)})
+		w.Write(nil)
+		w.Write([]string{"account", "debit", "credit"})
+		for _, p := range platform {
+			w.Write([]string{p.Account, money(p.Debit), money(p.Credit)})
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	return c.JSON(fiber.Map{
+		"from":      fromLabel,
+		"to":        toLabel,
+		"landlords": rows,
+		"totals": fiber.Map{
+			"opening":   math.Round(totals.Opening*100) / 100,
+			"collected": math.Round(totals.Collected*100) / 100,
+			"refunded":  math.Round(totals.Refunded*100) / 100,
+			"payouts":   math.Round(totals.Payouts*100) / 100,
+			"closing":   math.Round(totals.Closing*100) / 100,
+		},
+		"platform": platform,
+	})
+}
@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	rootcontroller "github.com/Conding-Student/backend/controller"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// PromotionPackage is a featured-listing option landlords can buy.
+type PromotionPackage struct {
+	Code  string  `json:"code"`
+	Name  string  `json:"name"`
+	Days  int     `json:"days"`
+	Price float64 `json:"price"`
+}
+
+var promotionPackages = []PromotionPackage{
+	{Code: "featured_7", Name: "Featured for 7 days", Days: 7, Price: 149},
+	{Code: "featured_30", Name: "Featured for 30 days", Days: 30, Price: 499},
+}
+
+func findPromotionPackage(code string) (PromotionPackage, bool) {
+	for _, p := range promotionPackages {
+		if p.Code == code {
+			return p, true
+		}
+	}
+	return PromotionPackage{}, false
+}
+
+// FetchPromotionPackages lists the featured-listing packages for sale.
+func FetchPromotionPackages(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"packages": promotionPackages})
+}
+
+// PromoteApartment starts a checkout for a promotion package on one of the
+// landlord's approved apartments. The promotion is activated by a paid hook
+// once the payment webhook arrives.
+func PromoteApartment(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	landlordID, _ := userClaims["uid"].(string)
+
+	var req struct {
+		Package       string `json:"package"`
+		PaymentMethod string `json:"payment_method"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	pkg, ok := findPromotionPackage(req.Package)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message":  "Unknown promotion package",
+			"packages": promotionPackages,
+		})
+	}
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = payment.MethodGCash
+	}
+
+	var apartment model.Apartment
+	if err := middleware.DBConn.Where("id = ? AND uid = ?", c.Params("id"), landlordID).First(&apartment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Database error"})
+	}
+	if apartment.Status != "Approved" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Only approved apartments can be promoted"})
+	}
+
+	apartmentID := apartment.ID
+	txn, checkout, err := Payments.StartCheckout(c.UserContext(), rootcontroller.CheckoutInput{
+		UserID:        landlordID,
+		BaseAmount:    pkg.Price,
+		Availment:     "Ad Post",
+		PaymentMethod: req.PaymentMethod,
+		ApartmentID:   &apartmentID,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to start checkout for promotion of apartment %d: %v", apartment.ID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"message": "Failed to start payment",
+			"details": err.Error(),
+		})
+	}
+
+	promotion := model.ApartmentPromotion{
+		ApartmentID:   apartment.ID,
+		LandlordID:    landlordID,
+		Package:       pkg.Code,
+		Days:          pkg.Days,
+		Price:         pkg.Price,
+		TransactionID: txn.ID,
+		Status:        "pending",
+	}
+	if err := middleware.DBConn.Create(&promotion).Error; err != nil {
+		log.Printf("[ERROR] Failed to save promotion for transaction %d: %v", txn.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Database error"})
+	}
+
+	resp := fiber.Map{
+		"promotion_id":   promotion.ID,
+		"transaction_id": txn.ID,
+		"checkout_url":   checkout.CheckoutURL,
+		"source_id":      checkout.ID,
+		"payment_method": txn.PaymentMethod,
+		"total_amount":   txn.TotalAmount,
+	}
+	if checkout.Kind == payment.KindPaymentIntent {
+		resp["client_key"] = checkout.ClientKey
+	}
+	return c.JSON(resp)
+}
+
+// FetchLandlordPromotions lists the logged-in landlord's promotions,
+// optionally filtered by ?apartment_id=.
+func FetchLandlordPromotions(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	landlordID, _ := userClaims["uid"].(string)
+
+	query := middleware.DBConn.Where("landlord_id = ?", landlordID).Order("created_at DESC")
+	if apartmentID := c.Query("apartment_id"); apartmentID != "" {
+		query = query.Where("apartment_id = ?", apartmentID)
+	}
+
+	var promotions []model.ApartmentPromotion
+	if err := query.Find(&promotions).Error; err != nil {
+		log.Printf("[ERROR] Failed to fetch promotions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch promotions"})
+	}
+	return c.JSON(fiber.Map{"promotions": promotions})
+}
+
+// ActivatePromotion is registered as a paid hook: when a promotion's
+// transaction is paid, the apartment is featured for the package's days,
+// stacking on top of any promotion that is still running.
+func ActivatePromotion(tx *gorm.DB, txn *model.Transaction) error {
+	var promotion model.ApartmentPromotion
+	if err := tx.Where("transaction_id = ? AND status = ?", txn.ID, "pending").First(&promotion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var apartment model.Apartment
+	if err := tx.Select("id, featured_until").First(&apartment, promotion.ApartmentID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	startsAt := now
+	if apartment.FeaturedUntil != nil && apartment.FeaturedUntil.After(now) {
+		startsAt = *apartment.FeaturedUntil
+	}
+	endsAt := startsAt.AddDate(0, 0, promotion.Days)
+
+	if err := tx.Model(&model.Apartment{}).Where("id = ?", promotion.ApartmentID).
+		Update("featured_until", endsAt).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&promotion).Updates(map[string]interface{}{
+		"status":    "active",
+		"starts_at": startsAt,
+		"ends_at":   endsAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	log.Printf("✅ Apartment %d featured until %s (promotion %d)", promotion.ApartmentID, endsAt.Format(time.RFC3339), promotion.ID)
+	return nil
+}
+
+// CancelPromotion is registered as a refunded hook: when a promotion's
+// transaction is refunded in full, the promotion is cancelled, promotions
+// stacked after it move up into the time it no longer uses, and the
+// apartment is featured only until the last remaining one ends.
+func CancelPromotion(tx *gorm.DB, txn *model.Transaction) error {
+	var promotion model.ApartmentPromotion
+	if err := tx.Where("transaction_id = ? AND status IN ?", txn.ID, []string{"pending", "active"}).First(&promotion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := tx.Model(&promotion).Update("status", "cancelled").Error; err != nil {
+		return err
+	}
+	if promotion.StartsAt == nil || promotion.EndsAt == nil {
+		log.Printf("✅ Pending promotion %d cancelled after refund of transaction %d", promotion.ID, txn.ID)
+		return nil
+	}
+
+	now := time.Now()
+	unused := promotion.EndsAt.Sub(*promotion.StartsAt)
+	if promotion.StartsAt.Before(now) {
+		unused = promotion.EndsAt.Sub(now)
+	}
+	if unused > 0 {
+		if err := tx.Model(&model.ApartmentPromotion{}).
+			Where("apartment_id = ? AND status = ? AND starts_at >= ?", promotion.ApartmentID, "active", *promotion.EndsAt).
+			Updates(map[string]interface{}{
+				"starts_at": gorm.Expr("starts_at - make_interval(secs => ?)", unused.Seconds()),
+				"ends_at":   gorm.Expr("ends_at - make_interval(secs => ?)", unused.Seconds()),
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	var featuredUntil *time.Time
+	if err := tx.Model(&model.ApartmentPromotion{}).
+		Select("MAX(ends_at)").
+		Where("apartment_id = ? AND status = ? AND ends_at > ?", promotion.ApartmentID, "active", now).
+		Row().Scan(&featuredUntil); err != nil {
+		return err
+	}
+	if err := tx.Model(&model.Apartment{}).Where("id = ?", promotion.ApartmentID).
+		Update("featured_until", featuredUntil).Error; err != nil {
+		return err
+	}
+
+	log.Printf("✅ Promotion %d cancelled after refund of transaction %d; apartment %d featured until %v",
+		promotion.ID, txn.ID, promotion.ApartmentID, featuredUntil)
+	return nil
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/testdb"
+)
+
+func TestCancelPromotionMovesUpStackedPromotions(t *testing.T) {
+	db := testdb.Open(t)
+
+	now := time.Now()
+	firstEnds, secondEnds := now.AddDate(0, 0, 7), now.AddDate(0, 0, 37)
+	apartment := model.Apartment{
+		Uid: "landlord-1", UserID: "landlord-1", PropertyName: "Featured Flats", Address: "4 Test St",
+		PropertyType: "Apartment", RentPrice: 5000, LocationLink: "-", Landmarks: "-",
+		Allowed_Gender: "Any", Status: "Approved", Availability: "Available", FeaturedUntil: &secondEnds,
+	}
+	if err := db.Create(&apartment).Error; err != nil {
+		t.Fatal(err)
+	}
+	promotions := []model.ApartmentPromotion{
+		{ApartmentID: apartment.ID, LandlordID: "landlord-1", Package: "featured_7", Days: 7, Price: 149,
+			TransactionID: 1, Status: "active", StartsAt: &now, EndsAt: &firstEnds},
+		{ApartmentID: apartment.ID, LandlordID: "landlord-1", Package: "featured_30", Days: 30, Price: 499,
+			TransactionID: 2, Status: "active", StartsAt: &firstEnds, EndsAt: &secondEnds},
+	}
+	if err := db.Create(&promotions).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CancelPromotion(db, &model.Transaction{ID: 1, Status: "refunded"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second model.ApartmentPromotion
+	db.First(&first, promotions[0].ID)
+	db.First(&second, promotions[1].ID)
+	if first.Status != "cancelled" {
+		t.Errorf("refunded promotion is %s, want cancelled", first.Status)
+	}
+	if second.Status != "active" || second.StartsAt.Sub(now).Abs() > time.Minute {
+		t.Errorf("stacked promotion starts at %s, want it moved up to now", second.StartsAt)
+	}
+
+	var featured model.Apartment
+	db.First(&featured, apartment.ID)
+	if featured.FeaturedUntil == nil || featured.FeaturedUntil.Sub(now.AddDate(0, 0, 30)).Abs() > time.Minute {
+		t.Errorf("featured until %v, want 30 days from now", featured.FeaturedUntil)
+	}
+
+	// Cancelling the remaining promotion ends the feature
+	if err := CancelPromotion(db, &model.Transaction{ID: 2, Status: "refunded"}); err != nil {
+		t.Fatal(err)
+	}
+	var unfeatured model.Apartment
+	db.First(&unfeatured, apartment.ID)
+	if unfeatured.FeaturedUntil != nil {
+		t.Errorf("featured until %v after every promotion was refunded, want nil", unfeatured.FeaturedUntil)
+	}
+}
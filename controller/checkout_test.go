@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Conding-Student/backend/ledger"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+	"github.com/Conding-Student/backend/testdb"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestFakeCheckoutEndToEnd runs create → webhook → success redirect fully
+// offline against the fake provider.
+func TestFakeCheckoutEndToEnd(t *testing.T) {
+	db := testdb.Open(t)
+	t.Setenv("PAYMENT_PROVIDER", "fake")
+	t.Setenv("PAYMONGO_WEBHOOK_SECRET", "")
+	t.Setenv("PAYMENT_FEE_RATE", "0.002")
+
+	saved := paidHooks
+	paidHooks = []PaidHook{ledger.PostPayment}
+	t.Cleanup(func() { paidHooks = saved })
+
+	apartment := model.Apartment{
+		Uid: "landlord-1", UserID: "landlord-1", PropertyName: "Fake Pay Flats", Address: "2 Test St",
+		PropertyType: "Apartment", RentPrice: 5000, LocationLink: "-", Landmarks: "-",
+		Allowed_Gender: "Any", Status: "Approved", Availability: "Available",
+	}
+	if err := db.Create(&apartment).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := payment.ConfigFromEnv()
+	svc := &PayMongoService{DB: db, Provider: payment.NewProvider(cfg), Config: cfg}
+	fake, ok := svc.Provider.(*payment.FakeProvider)
+	if !ok {
+		t.Fatalf("PAYMENT_PROVIDER=fake gave %T", svc.Provider)
+	}
+
+	app := fiber.New()
+	app.Post("/api/create-source", middleware.AuthMiddleware, svc.CreateSource)
+	app.Post("/api/webhook", svc.HandleWebhook)
+	app.Get("/success", svc.HandleSuccessRedirect)
+	token := testdb.Token(t, "tenant-1", "Tenant")
+
+	// 1. Create the checkout
+	req := httptest.NewRequest(http.MethodPost, "/api/create-source", strings.NewReader(fmt.Sprintf(
+		`{"user_id":"tenant-1","base_amount":5000,"availment":"Deposit","payment_method":"gcash","apartment_id":%d}`, apartment.ID)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created struct {
+		SourceID    string `json:"source_id"`
+		CheckoutURL string `json:"checkout_url"`
+		Status      string `json:"status"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || created.SourceID == "" || created.Status != "pending" {
+		t.Fatalf("create-source: status %d, %+v", resp.StatusCode, created)
+	}
+	if !strings.HasSuffix(created.CheckoutURL, "/fake-checkout/"+created.SourceID) {
+		t.Errorf("checkout URL %q does not point at the fake checkout", created.CheckoutURL)
+	}
+
+	// 2. The customer authorizes and the fake sends the signed webhook
+	if err := fake.Authorize(created.SourceID); err != nil {
+		t.Fatal(err)
+	}
+	event, err := fake.Event(created.SourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/webhook", strings.NewReader(string(event)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Paymongo-Signature", payment.SignWebhook(cfg.WebhookSecret, event, false, time.Now()))
+	resp, err = app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("webhook: status %d", resp.StatusCode)
+	}
+
+	// 3. The provider redirects the customer back
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/success?id="+created.SourceID, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "paymentsuccess?id="+created.SourceID) {
+		t.Fatalf("success redirect: status %d, body %s", resp.StatusCode, page)
+	}
+
+	// The transaction is paid and the ledger owes the landlord the base amount
+	var txn model.Transaction
+	if err := db.Where("pay_mongo_source_id = ?", created.SourceID).First(&txn).Error; err != nil {
+		t.Fatal(err)
+	}
+	if txn.Status != "paid" || txn.PayMongoPaymentID == "" {
+		t.Fatalf("transaction is %s with payment %q, want paid", txn.Status, txn.PayMongoPaymentID)
+	}
+	if txn.TotalAmount != 5010 {
+		t.Errorf("total %.2f, want 5010.00 with the 0.2%% fee", txn.TotalAmount)
+	}
+
+	var journal model.LedgerJournal
+	if err := db.Where("journal_key = ?", fmt.Sprintf("payment:%d", txn.ID)).First(&journal).Error; err != nil {
+		t.Fatalf("no ledger journal for the payment: %v", err)
+	}
+	if journal.LandlordID != "landlord-1" {
+		t.Errorf("journal credits %q, want landlord-1", journal.LandlordID)
+	}
+	balance, err := ledger.LandlordBalance(db, "landlord-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 5000 {
+		t.Errorf("landlord balance %.2f, want 5000.00", balance)
+	}
+}
+
+func TestCreateSourceChecksPayerAndApartment(t *testing.T) {
+	db := testdb.Open(t)
+	t.Setenv("PAYMENT_PROVIDER", "fake")
+
+	pending := model.Apartment{
+		Uid: "landlord-1", UserID: "landlord-1", PropertyName: "Not Yet Listed", Address: "3 Test St",
+		PropertyType: "Apartment", RentPrice: 5000, LocationLink: "-", Landmarks: "-",
+		Allowed_Gender: "Any", Status: "Pending", Availability: "Not Available",
+	}
+	if err := db.Create(&pending).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := payment.ConfigFromEnv()
+	svc := &PayMongoService{DB: db, Provider: payment.NewProvider(cfg), Config: cfg}
+	app := fiber.New()
+	app.Post("/api/create-source", middleware.AuthMiddleware, svc.CreateSource)
+	token := testdb.Token(t, "tenant-1", "Tenant")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"someone else's user_id", `{"user_id":"tenant-2","base_amount":100}`, http.StatusForbidden},
+		{"unapproved apartment", fmt.Sprintf(`{"base_amount":100,"apartment_id":%d}`, pending.ID), http.StatusBadRequest},
+		{"missing apartment", `{"base_amount":100,"apartment_id":999999}`, http.StatusBadRequest},
+		{"user_id taken from the token", `{"base_amount":100}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/create-source", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", token)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	var txns []model.Transaction
+	db.Find(&txns)
+	if len(txns) != 1 || txns[0].UserID != "tenant-1" {
+		t.Errorf("got transactions %+v, want one for tenant-1", txns)
+	}
+}
@@ -1,14 +1,22 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Conding-Student/backend/config"
+	"github.com/Conding-Student/backend/media"
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
+	"github.com/Conding-Student/backend/pricehistory"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // Struct for parsing apartment creation request
@@ -24,7 +32,9 @@ type ApartmentRequest struct {
 	VideoURLs     []string `json:"video_urls"`
 	Latitude      float64  `json:"latitude"`
 	Longitude     float64  `json:"longitude"`
-	AllowedGender string   `json:"allowed_gender"` // New field
+	AllowedGender string   `json:"allowed_gender"`  // New field
+	ImageAssetIDs []uint   `json:"image_asset_ids"` // from /media/uploads
+	VideoAssetIDs []uint   `json:"video_asset_ids"`
 }
 
 func CreateApartment(c *fiber.Ctx) error {
@@ -56,15 +66,9 @@ func CreateApartment(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.PropertyName == "" || req.PropertyType == "" || req.RentPrice <= 0 || req.LocationLink == "" || req.AllowedGender == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"message": "Missing required fields: property_name, property_type, rent_price, location_link, or allowed_gender",
-		})
-	}
-
-	if req.Latitude == 0 || req.Longitude == 0 {
+	if msg := req.validate(); msg != "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"message": "Latitude and Longitude are required and must be valid coordinates",
+			"message": msg,
 		})
 	}
 
@@ -75,14 +79,84 @@ func CreateApartment(c *fiber.Ctx) error {
 		})
 	}
 
-	var existing model.Apartment
-	if err := tx.Where("property_name = ? AND location_link = ? AND uid = ?", req.PropertyName, req.LocationLink, uid).First(&existing).Error; err == nil {
+	apartment, err := createApartment(tx, uid, req)
+	if errors.Is(err, errDuplicateApartment) {
 		tx.Rollback()
 		return c.Status(http.StatusConflict).JSON(fiber.Map{
-			"message": "Apartment with the same property name and location already exists for this landlord",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		tx.Rollback()
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database error: Unable to create apartment",
+			"error":   err.Error(),
+		})
+	}
+
+	imageURLs, videoURLs, err := attachMedia(tx, uid, apartment.ID, req.media())
+	if err != nil {
+		tx.Rollback()
+		return assetError(c, err, "Failed to upload media to Cloudinary")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database error: Transaction commit failed",
+			"error":   err.Error(),
 		})
 	}
 
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Apartment created successfully",
+		"data": fiber.Map{
+			"apartment_id": apartment.ID,
+			"image_urls":   imageURLs,
+			"video_urls":   videoURLs,
+		},
+	})
+}
+
+// validate applies the listing rules shared by CreateApartment and the bulk
+// importer. It returns the problem, or "" when the request is valid.
+func (req *ApartmentRequest) validate() string {
+	req.PropertyName = strings.TrimSpace(req.PropertyName)
+	req.PropertyType = strings.TrimSpace(req.PropertyType)
+	req.LocationLink = strings.TrimSpace(req.LocationLink)
+	req.AllowedGender = strings.TrimSpace(req.AllowedGender)
+
+	if req.PropertyName == "" || req.PropertyType == "" || req.RentPrice <= 0 || req.LocationLink == "" || req.AllowedGender == "" {
+		return "Missing required fields: property_name, property_type, rent_price, location_link, or allowed_gender"
+	}
+	if req.Latitude == 0 || req.Longitude == 0 ||
+		req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		return "Latitude and Longitude are required and must be valid coordinates"
+	}
+	return ""
+}
+
+var errDuplicateApartment = errors.New("Apartment with the same property name and location already exists for this landlord")
+
+// apartmentExists reports whether the landlord already lists a property with
+// this name and location link.
+func apartmentExists(db *gorm.DB, uid, propertyName, locationLink string) (bool, error) {
+	var count int64
+	err := db.Model(&model.Apartment{}).
+		Where("property_name = ? AND location_link = ? AND uid = ?", propertyName, locationLink, uid).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// createApartment saves a validated listing for review with its amenities and
+// house rules, inside tx. Media is attached separately by attachMedia.
+func createApartment(tx *gorm.DB, uid string, req ApartmentRequest) (model.Apartment, error) {
+	if exists, err := apartmentExists(tx, uid, req.PropertyName, req.LocationLink); err != nil {
+		return model.Apartment{}, err
+	} else if exists {
+		return model.Apartment{}, errDuplicateApartment
+	}
+
+	submittedAt := time.Now()
 	apartment := model.Apartment{
 		Uid:            uid,
 		PropertyName:   req.PropertyName,
@@ -96,69 +170,158 @@ func CreateApartment(c *fiber.Ctx) error {
 		UserID:         uid,
 		Availability:   "Not Available",
 		Allowed_Gender: req.AllowedGender,
+		SubmittedAt:    &submittedAt,
 	}
-
 	if err := tx.Create(&apartment).Error; err != nil {
-		tx.Rollback()
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Database error: Unable to create apartment",
-			"error":   err.Error(),
-		})
+		return apartment, err
 	}
 
-	for _, name := range req.Amenities {
+	if err := moderation.Record(tx, apartment.ID, "", moderation.StatusPending, moderation.Actor{UID: uid, Role: moderation.RoleLandlord}, "", ""); err != nil {
+		return apartment, fmt.Errorf("unable to submit apartment for review: %w", err)
+	}
+	if err := pricehistory.Record(tx, apartment.ID, nil, apartment.RentPrice, uid); err != nil {
+		return apartment, fmt.Errorf("unable to record rent price: %w", err)
+	}
+
+	amenities, _, err := resolveAmenities(tx, req.Amenities, true)
+	if err != nil {
+		return apartment, fmt.Errorf("unable to add amenities: %w", err)
+	}
+	for _, a := range amenities {
+		if err := tx.Create(&model.ApartmentAmenity{ApartmentID: apartment.ID, AmenityID: a.ID}).Error; err != nil {
+			return apartment, fmt.Errorf("unable to add amenities: %w", err)
+		}
+	}
+
+	rules, _, err := resolveHouseRules(tx, req.HouseRules, true)
+	if err != nil {
+		return apartment, fmt.Errorf("unable to add house rules: %w", err)
+	}
+	for _, h := range rules {
+		if err := tx.Create(&model.ApartmentHouseRule{ApartmentID: apartment.ID, HouseRuleID: h.ID}).Error; err != nil {
+			return apartment, fmt.Errorf("unable to add house rules: %w", err)
+		}
+	}
+	return apartment, nil
+}
+
+// resolveAmenities matches names to the amenity catalog, ignoring case and
+// surrounding spaces, and returns the names it did not know. With create the
+// unknown ones are added to the catalog; without, only known ones are
+// returned.
+func resolveAmenities(db *gorm.DB, names []string, create bool) ([]model.Amenity, []string, error) {
+	var resolved []model.Amenity
+	var unknown []string
+	for _, name := range dedupeNames(names) {
 		var a model.Amenity
-		if err := tx.Where("name = ?", name).FirstOrCreate(&a, model.Amenity{Name: name}).Error; err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Database error: Unable to add amenities", "error": err.Error()})
+		err := db.Where("LOWER(TRIM(name)) = ?", strings.ToLower(name)).First(&a).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			unknown = append(unknown, name)
+			if !create {
+				continue
+			}
+			a = model.Amenity{Name: name}
+			err = db.Create(&a).Error
 		}
-		tx.Create(&model.ApartmentAmenity{ApartmentID: apartment.ID, AmenityID: a.ID})
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved = append(resolved, a)
 	}
+	return resolved, unknown, nil
+}
 
-	for _, rule := range req.HouseRules {
+// resolveHouseRules is resolveAmenities for the house rule catalog.
+func resolveHouseRules(db *gorm.DB, rules []string, create bool) ([]model.HouseRule, []string, error) {
+	var resolved []model.HouseRule
+	var unknown []string
+	for _, rule := range dedupeNames(rules) {
 		var h model.HouseRule
-		if err := tx.Where("rule = ?", rule).FirstOrCreate(&h, model.HouseRule{Rule: rule}).Error; err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Database error: Unable to add house rules", "error": err.Error()})
+		err := db.Where("LOWER(TRIM(rule)) = ?", strings.ToLower(rule)).First(&h).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			unknown = append(unknown, rule)
+			if !create {
+				continue
+			}
+			h = model.HouseRule{Rule: rule}
+			err = db.Create(&h).Error
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved = append(resolved, h)
+	}
+	return resolved, unknown, nil
+}
+
+// dedupeNames trims names and drops blanks and case-insensitive repeats.
+func dedupeNames(names []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
 		}
-		tx.Create(&model.ApartmentHouseRule{ApartmentID: apartment.ID, HouseRuleID: h.ID})
+		seen[key] = true
+		out = append(out, name)
 	}
+	return out
+}
 
+func (req ApartmentRequest) media() UpdateMediaRequest {
+	return UpdateMediaRequest{
+		ImageURLs:     req.ImageURLs,
+		VideoURLs:     req.VideoURLs,
+		ImageAssetIDs: req.ImageAssetIDs,
+		VideoAssetIDs: req.VideoAssetIDs,
+	}
+}
+
+// attachMedia links images and videos to the apartment: uploaded assets of
+// uid by ID, and URLs, which are uploaded to Cloudinary first.
+func attachMedia(tx *gorm.DB, uid string, apartmentID uint, m UpdateMediaRequest) ([]string, []string, error) {
 	var imageURLs []string
-	for _, img := range req.ImageURLs {
+	images, err := media.Claim(tx, uid, m.ImageAssetIDs, media.PurposeListingImage)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, asset := range images {
+		imageURLs = append(imageURLs, asset.URL)
+	}
+	for _, img := range m.ImageURLs {
 		url, err := config.UploadImage(img)
 		if err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to upload image to Cloudinary", "error": err.Error()})
+			return nil, nil, err
 		}
 		imageURLs = append(imageURLs, url)
-		tx.Create(&model.ApartmentImage{ApartmentID: apartment.ID, ImageURL: url})
+	}
+	for _, url := range imageURLs {
+		if err := tx.Create(&model.ApartmentImage{ApartmentID: apartmentID, ImageURL: url}).Error; err != nil {
+			return nil, nil, err
+		}
 	}
 
 	var videoURLs []string
-	for _, vid := range req.VideoURLs {
+	videos, err := media.Claim(tx, uid, m.VideoAssetIDs, media.PurposeListingVideo)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, asset := range videos {
+		videoURLs = append(videoURLs, asset.URL)
+	}
+	for _, vid := range m.VideoURLs {
 		url, err := config.UploadVideo(vid)
 		if err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to upload video to Cloudinary", "error": err.Error()})
+			return nil, nil, err
 		}
 		videoURLs = append(videoURLs, url)
-		tx.Create(&model.ApartmentVideo{ApartmentID: apartment.ID, VideoURL: url})
 	}
-
-	if err := tx.Commit().Error; err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Database error: Transaction commit failed",
-			"error":   err.Error(),
-		})
+	for _, url := range videoURLs {
+		if err := tx.Create(&model.ApartmentVideo{ApartmentID: apartmentID, VideoURL: url}).Error; err != nil {
+			return nil, nil, err
+		}
 	}
-
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "Apartment created successfully",
-		"data": fiber.Map{
-			"apartment_id": apartment.ID,
-			"image_urls":   imageURLs,
-			"video_urls":   videoURLs,
-		},
-	})
+	return imageURLs, videoURLs, nil
 }
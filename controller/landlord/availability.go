@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Conding-Student/backend/availability"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/savedsearch"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AvailabilityWindowRequest is the body of CreateAvailabilityWindow. Dates
+// are YYYY-MM-DD; leave end_date out for a window with no end.
+type AvailabilityWindowRequest struct {
+	UnitID    *uint  `json:"unit_id"`
+	Kind      string `json:"kind"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Note      string `json:"note"`
+}
+
+// syncAvailability re-derives the listing's Availability from its calendar
+// and alerts saved searches if that opened it.
+func syncAvailability(apartmentID uint) error {
+	opened, err := availability.Sync(middleware.DBConn, apartmentID)
+	for _, id := range opened {
+		go savedsearch.NotifyListing(id)
+	}
+	return err
+}
+
+// FetchAvailabilityWindows lists the calendar of one of the landlord's
+// apartments, past windows included.
+func FetchAvailabilityWindows(c *fiber.Ctx) error {
+	apartment, err := ownedApartment(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	var windows []model.AvailabilityWindow
+	if err := middleware.DBConn.Where("apartment_id = ?", apartment.ID).Order("start_date, id").Find(&windows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch availability",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"apartment_id":        apartment.ID,
+		"availability":        apartment.Availability,
+		"next_available_date": availability.NextAvailable(apartment, windows),
+		"windows":             windows,
+	})
+}
+
+// CreateAvailabilityWindow opens or blocks a range of dates for the whole
+// apartment or one of its units, e.g. "available from Nov 1".
+func CreateAvailabilityWindow(c *fiber.Ctx) error {
+	apartment, err := ownedApartment(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	var req AvailabilityWindowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request format"})
+	}
+
+	window := model.AvailabilityWindow{
+		ApartmentID: apartment.ID,
+		UnitID:      req.UnitID,
+		Kind:        req.Kind,
+		Note:        strings.TrimSpace(req.Note),
+	}
+	if window.Kind == "" {
+		window.Kind = availability.KindAvailable
+	}
+	if window.Kind != availability.KindAvailable && window.Kind != availability.KindBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "kind must be available or blocked"})
+	}
+
+	window.StartDate, err = time.Parse(availability.DateLayout, req.StartDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "start_date must be YYYY-MM-DD"})
+	}
+	if req.EndDate != "" {
+		end, err := time.Parse(availability.DateLayout, req.EndDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "end_date must be YYYY-MM-DD"})
+		}
+		if end.Before(window.StartDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "end_date cannot be before start_date"})
+		}
+		if end.Before(availability.Today()) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "end_date is in the past"})
+		}
+		window.EndDate = &end
+	}
+
+	if req.UnitID != nil {
+		var unit model.ApartmentUnit
+		if err := middleware.DBConn.First(&unit, "id = ? AND apartment_id = ?", *req.UnitID, apartment.ID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Unit not found in this apartment"})
+		}
+	}
+
+	if err := middleware.DBConn.Create(&window).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to save availability",
+			"error":   err.Error(),
+		})
+	}
+	if err := syncAvailability(apartment.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update availability",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Availability saved",
+		"window":  window,
+	})
+}
+
+// DeleteAvailabilityWindow removes a window from the calendar.
+func DeleteAvailabilityWindow(c *fiber.Ctx) error {
+	apartment, err := ownedApartment(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	result := middleware.DBConn.Where("id = ? AND apartment_id = ?", c.Params("windowId"), apartment.ID).
+		Delete(&model.AvailabilityWindow{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete availability",
+			"error":   result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Availability window not found"})
+	}
+
+	// Without any windows left the listing keeps its last derived value
+	if err := syncAvailability(apartment.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update availability",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "Availability window deleted"})
+}
@@ -1,10 +1,11 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
-	"github.com/Conding-Student/backend/config"
+	"github.com/Conding-Student/backend/media"
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
 
@@ -14,11 +15,12 @@ import (
 )
 
 type LandlordRegistrationRequest struct {
-	BusinessName    string   `json:"business_name" validate:"required"`
-	BusinessAddress string   `json:"business_address" validate:"required"`
-	BusinessContact string   `json:"business_contact" validate:"required"`
-	IDImageURL      string   `json:"id_image_url" validate:"required,url"`
-	PermitImageURLs []string `json:"permit_image_urls" validate:"required,min=1,dive,url"`
+	BusinessName    string `json:"business_name" validate:"required"`
+	BusinessAddress string `json:"business_address" validate:"required"`
+	BusinessContact string `json:"business_contact" validate:"required"`
+	// Files uploaded to /media/uploads
+	IDAssetID      *uint  `json:"id_asset_id"`
+	PermitAssetIDs []uint `json:"permit_asset_ids"`
 }
 
 func RegisterLandlord(c *fiber.Ctx) error {
@@ -46,6 +48,17 @@ func RegisterLandlord(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.IDAssetID == nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Missing required field: id_asset_id (upload the image to /media/uploads first)",
+		})
+	}
+	if len(req.PermitAssetIDs) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Missing required field: permit_asset_ids (upload the files to /media/uploads first)",
+		})
+	}
+
 	// Start database transaction
 	tx := middleware.DBConn.Begin()
 	if tx.Error != nil {
@@ -91,28 +104,22 @@ func RegisterLandlord(c *fiber.Ctx) error {
 		})
 	}
 
-	// Upload ID image
-	idImageURL, err := config.UploadImage(req.IDImageURL)
+	// Claim the uploaded ID and business permits
+	ids, err := media.Claim(tx, uid, []uint{*req.IDAssetID}, media.PurposeValidID)
 	if err != nil {
 		tx.Rollback()
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to upload ID image",
-			"error":   err.Error(),
-		})
+		return assetError(c, err, "Failed to attach ID image")
 	}
+	idImageURL := ids[0].URL
 
-	// Upload business permits
 	var permitURLs []string
-	for _, permit := range req.PermitImageURLs {
-		url, err := config.UploadImage(permit)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to upload business permit",
-				"error":   err.Error(),
-			})
-		}
-		permitURLs = append(permitURLs, url)
+	permits, err := media.Claim(tx, uid, req.PermitAssetIDs, media.PurposeBusinessPermit)
+	if err != nil {
+		tx.Rollback()
+		return assetError(c, err, "Failed to attach business permit")
+	}
+	for _, permit := range permits {
+		permitURLs = append(permitURLs, permit.URL)
 	}
 
 	// Create landlord profile
@@ -158,3 +165,15 @@ func RegisterLandlord(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// assetError reports a failed media.Claim: 400 when the client named an asset
+// it cannot use, 500 otherwise.
+func assetError(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, media.ErrAssetUnavailable) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"message": message,
+		"error":   err.Error(),
+	})
+}
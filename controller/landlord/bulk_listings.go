@@ -0,0 +1,383 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// listingColumns are the CSV columns of the listing export and import, in
+// order. List columns hold their items separated by listSeparator.
+var listingColumns = []string{
+	"property_name", "property_type", "rent_price", "location_link", "landmarks",
+	"latitude", "longitude", "allowed_gender", "amenities", "house_rules", "image_urls", "video_urls",
+}
+
+const listSeparator = "|"
+
+// maxImportRows caps how many listings one import may hold.
+const maxImportRows = 500
+
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+// listingFormat picks csv or jsonl from ?format=, then the uploaded file's
+// extension, then the content type. CSV is the default.
+func listingFormat(c *fiber.Ctx, filename string) (string, error) {
+	switch format := strings.ToLower(c.Query("format")); format {
+	case formatCSV, formatJSONL:
+		return format, nil
+	case "ndjson":
+		return formatJSONL, nil
+	case "":
+	default:
+		return "", errors.New("format must be csv or jsonl")
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".ndjson":
+		return formatJSONL, nil
+	case ".csv":
+		return formatCSV, nil
+	}
+	if strings.Contains(string(c.Request().Header.ContentType()), "json") {
+		return formatJSONL, nil
+	}
+	return formatCSV, nil
+}
+
+// listingRow is one listing read from an import file. Err is set when the
+// row could not be read at all.
+type listingRow struct {
+	Line    int
+	Request ApartmentRequest
+	Err     string
+}
+
+func splitItems(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// readListingCSV reads a CSV with a header row naming listingColumns. Columns
+// may come in any order; unknown ones are ignored.
+func readListingCSV(r io.Reader) ([]listingRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %v", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := index["property_name"]; !ok {
+		return nil, errors.New("the CSV header must name the columns: " + strings.Join(listingColumns, ","))
+	}
+
+	var rows []listingRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, listingRow{Line: parseErr.Line, Err: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := listingRow{Line: line, Request: ApartmentRequest{
+			PropertyName:  get("property_name"),
+			PropertyType:  get("property_type"),
+			LocationLink:  get("location_link"),
+			Landmarks:     get("landmarks"),
+			AllowedGender: get("allowed_gender"),
+			Amenities:     splitItems(get("amenities")),
+			HouseRules:    splitItems(get("house_rules")),
+			ImageURLs:     splitItems(get("image_urls")),
+			VideoURLs:     splitItems(get("video_urls")),
+		}}
+		var problems []string
+		parse := func(column string, dst *float64) {
+			if value := get(column); value != "" {
+				n, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					problems = append(problems, column+" must be a number")
+				}
+				*dst = n
+			}
+		}
+		parse("rent_price", &row.Request.RentPrice)
+		parse("latitude", &row.Request.Latitude)
+		parse("longitude", &row.Request.Longitude)
+		row.Err = strings.Join(problems, "; ")
+		rows = append(rows, row)
+	}
+}
+
+// readListingJSONL reads one ApartmentRequest JSON object per line. Blank
+// lines are skipped.
+func readListingJSONL(r io.Reader) ([]listingRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []listingRow
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		row := listingRow{Line: line}
+		if err := json.Unmarshal(text, &row.Request); err != nil {
+			row.Err = "invalid JSON: " + err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// ImportRowReport is what happened to one row of an import.
+type ImportRowReport struct {
+	Line          int      `json:"line"`
+	PropertyName  string   `json:"property_name"`
+	Status        string   `json:"status"` // created, valid (dry run), duplicate, error
+	ApartmentID   uint     `json:"apartment_id,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	NewAmenities  []string `json:"new_amenities,omitempty"`   // not in the catalog yet; added on import
+	NewHouseRules []string `json:"new_house_rules,omitempty"` // not in the catalog yet; added on import
+}
+
+// ImportApartments creates many listings from a CSV or JSON Lines file, sent
+// as the multipart field "file" or as the raw body. Every row gets the same
+// checks as CreateApartment and is saved on its own, so one bad row does not
+// stop the rest. ?dry_run=true checks the file without saving anything.
+func ImportApartments(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+	}
+	if err := middleware.DBConn.Where("uid = ? AND user_type = ?", uid, "Landlord").First(&model.User{}).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Unauthorized: User is not a registered landlord",
+		})
+	}
+	dryRun := c.QueryBool("dry_run")
+
+	var input io.Reader = bytes.NewReader(c.Body())
+	filename := ""
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Unable to read the uploaded file"})
+		}
+		defer f.Close()
+		input, filename = f, file.Filename
+	}
+
+	format, err := listingFormat(c, filename)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	var rows []listingRow
+	if format == formatJSONL {
+		rows, err = readListingJSONL(input)
+	} else {
+		rows, err = readListingCSV(input)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if len(rows) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "The file has no listings"})
+	}
+	if len(rows) > maxImportRows {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("An import can hold at most %d listings", maxImportRows),
+		})
+	}
+
+	reports := make([]ImportRowReport, len(rows))
+	summary := map[string]int{"created": 0, "valid": 0, "duplicate": 0, "error": 0}
+	seen := map[string]int{}
+	for i, row := range rows {
+		report := importListingRow(uid, row, seen, dryRun)
+		reports[i] = report
+		summary[report.Status]++
+	}
+
+	message := "Import finished"
+	if dryRun {
+		message = "Dry run finished; nothing was saved"
+	}
+	return c.JSON(fiber.Map{
+		"message": message,
+		"dry_run": dryRun,
+		"format":  format,
+		"total":   len(rows),
+		"summary": summary,
+		"rows":    reports,
+	})
+}
+
+// importListingRow checks one row and, unless dryRun, saves it. seen maps the
+// name and location of earlier rows to their line to catch repeats in the
+// file.
+func importListingRow(uid string, row listingRow, seen map[string]int, dryRun bool) ImportRowReport {
+	req := row.Request
+	report := ImportRowReport{Line: row.Line, PropertyName: strings.TrimSpace(req.PropertyName), Status: "error"}
+	if row.Err != "" {
+		report.Error = row.Err
+		return report
+	}
+	if msg := req.validate(); msg != "" {
+		report.Error = msg
+		return report
+	}
+
+	key := strings.ToLower(req.PropertyName) + "\x00" + req.LocationLink
+	if line, ok := seen[key]; ok {
+		report.Status = "duplicate"
+		report.Error = fmt.Sprintf("Same property name and location as line %d", line)
+		return report
+	}
+	seen[key] = row.Line
+
+	exists, err := apartmentExists(middleware.DBConn, uid, req.PropertyName, req.LocationLink)
+	if err != nil {
+		report.Error = "Unable to check for duplicates"
+		return report
+	}
+	if exists {
+		report.Status = "duplicate"
+		report.Error = errDuplicateApartment.Error()
+		return report
+	}
+
+	if _, report.NewAmenities, err = resolveAmenities(middleware.DBConn, req.Amenities, false); err != nil {
+		report.Error = "Unable to resolve amenities"
+		return report
+	}
+	if _, report.NewHouseRules, err = resolveHouseRules(middleware.DBConn, req.HouseRules, false); err != nil {
+		report.Error = "Unable to resolve house rules"
+		return report
+	}
+
+	if dryRun {
+		report.Status = "valid"
+		return report
+	}
+
+	var apartment model.Apartment
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if apartment, err = createApartment(tx, uid, req); err != nil {
+			return err
+		}
+		_, _, err = attachMedia(tx, uid, apartment.ID, req.media())
+		return err
+	})
+	switch {
+	case errors.Is(err, errDuplicateApartment):
+		report.Status = "duplicate"
+		report.Error = err.Error()
+	case err != nil:
+		report.Error = err.Error()
+	default:
+		report.Status = "created"
+		report.ApartmentID = apartment.ID
+	}
+	return report
+}
+
+// toApartmentRequest turns a listing back into the shape the importer reads.
+func toApartmentRequest(d model.ApartmentDetails) ApartmentRequest {
+	return ApartmentRequest{
+		PropertyName:  d.PropertyName,
+		PropertyType:  d.PropertyType,
+		RentPrice:     d.RentPrice,
+		LocationLink:  d.LocationLink,
+		Landmarks:     d.Landmarks,
+		Amenities:     d.Amenities,
+		HouseRules:    d.HouseRules,
+		ImageURLs:     d.Images,
+		VideoURLs:     d.Videos,
+		Latitude:      d.Latitude,
+		Longitude:     d.Longitude,
+		AllowedGender: d.Allowed_Gender,
+	}
+}
+
+// sendListingExport writes the listings as a CSV or JSON Lines download that
+// ImportApartments reads back.
+func sendListingExport(c *fiber.Ctx, format string, listings []model.ApartmentDetails) error {
+	var buf bytes.Buffer
+	if format == formatJSONL {
+		encoder := json.NewEncoder(&buf)
+		for _, d := range listings {
+			if err := encoder.Encode(toApartmentRequest(d)); err != nil {
+				return err
+			}
+		}
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	} else {
+		writer := csv.NewWriter(&buf)
+		if err := writer.Write(listingColumns); err != nil {
+			return err
+		}
+		formatFloat := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+		for _, d := range listings {
+			req := toApartmentRequest(d)
+			if err := writer.Write([]string{
+				req.PropertyName, req.PropertyType, formatFloat(req.RentPrice), req.LocationLink, req.Landmarks,
+				formatFloat(req.Latitude), formatFloat(req.Longitude), req.AllowedGender,
+				strings.Join(req.Amenities, listSeparator), strings.Join(req.HouseRules, listSeparator),
+				strings.Join(req.ImageURLs, listSeparator), strings.Join(req.VideoURLs, listSeparator),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="listings.%s"`, format))
+	return c.Send(buf.Bytes())
+}
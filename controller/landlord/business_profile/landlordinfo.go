@@ -1,12 +1,14 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -333,7 +335,14 @@ func RejectLandlordRequest(c *fiber.Ctx) error {
 }
 
 // APARTMENT REJECTION
-// RejectionRequest represents the payload for landlord rejection
+// ApartmentRejectionRequest is the payload for rejecting a listing. ReasonCode
+// is one of moderation.ReasonCodes and defaults to "other", in which case
+// RejectionReason is required.
+type ApartmentRejectionRequest struct {
+	ReasonCode      string `json:"reason_code"`
+	RejectionReason string `json:"rejection_reason"`
+}
+
 func RejectApartmentRequest(c *fiber.Ctx) error {
 	// Get apartment ID from params
 	apartmentID := c.Params("id")
@@ -344,32 +353,26 @@ func RejectApartmentRequest(c *fiber.Ctx) error {
 	}
 
 	// Parse rejection reason from request body
-	var req RejectionRequest
+	var req ApartmentRejectionRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid request format",
 			"error":   err.Error(),
 		})
 	}
+	if req.ReasonCode == "" {
+		req.ReasonCode = "other"
+	}
+	req.RejectionReason = strings.TrimSpace(req.RejectionReason)
 
-	// Start database transaction
-	tx := middleware.DBConn.Begin()
-	if tx.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to start database transaction",
-			"error":   tx.Error.Error(),
-		})
+	adminUID := ""
+	if claims, ok := c.Locals("user").(jwt.MapClaims); ok {
+		adminUID, _ = claims["uid"].(string)
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
 
 	// Get apartment by ID
 	var apartment model.Apartment
-	if err := tx.Where("id = ?", apartmentID).First(&apartment).Error; err != nil {
-		tx.Rollback()
+	if err := middleware.DBConn.Where("id = ?", apartmentID).First(&apartment).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(http.StatusNotFound).JSON(fiber.Map{
 				"message": "Apartment not found",
@@ -382,44 +385,35 @@ func RejectApartmentRequest(c *fiber.Ctx) error {
 	}
 
 	// Check if already rejected
-	if apartment.Status == "Rejected" {
-		tx.Rollback()
+	if apartment.Status == moderation.StatusRejected {
 		return c.Status(http.StatusConflict).JSON(fiber.Map{
 			"message": "This apartment was already rejected",
 		})
 	}
 
-	// Update apartment with rejection details
-	updateData := map[string]interface{}{
-		"message": req.RejectionReason,
-		"status":  "Rejected",
-		// Add any other fields you want to update, like rejected_at if your table has that column
-		// "rejected_at": time.Now(),
-	}
-
-	if err := tx.Model(&model.Apartment{}).
-		Where("id = ?", apartmentID).
-		Updates(updateData).Error; err != nil {
-		tx.Rollback()
+	// Update apartment with rejection details and record the decision
+	actor := moderation.Actor{UID: adminUID, Role: moderation.RoleAdmin}
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		return moderation.Transition(tx, &apartment, moderation.StatusRejected, actor, req.ReasonCode, req.RejectionReason, nil)
+	})
+	switch {
+	case errors.Is(err, moderation.ErrReasonRequired), errors.Is(err, moderation.ErrNoteRequired):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	case errors.Is(err, moderation.ErrInvalidTransition), errors.Is(err, moderation.ErrClaimedByOther), errors.Is(err, moderation.ErrStatusChanged):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	case err != nil:
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to update apartment",
 			"error":   err.Error(),
 		})
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to commit transaction",
-			"error":   err.Error(),
-		})
-	}
-
 	return c.JSON(fiber.Map{
 		"message": "Apartment request rejected",
 		"data": fiber.Map{
 			"apartment_id":     apartmentID,
-			"rejection_reason": req.RejectionReason,
+			"reason_code":      req.ReasonCode,
+			"rejection_reason": apartment.Message,
 			"updated_at":       time.Now().Format(time.RFC3339),
 		},
 	})
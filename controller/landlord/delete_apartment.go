@@ -1,14 +1,17 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // DeleteApartmentRequest is used to confirm deletion
@@ -76,21 +79,20 @@ func DeleteApartment(c *fiber.Ctx) error {
 		})
 	}
 
-	// 🗑 Delete the apartment via raw SQL (cascading deletions will occur based on your DB constraints)
-	result := middleware.DBConn.Exec("DELETE FROM apartments WHERE id = ? AND uid = ?", apartment.ID, uid)
-	if result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to delete apartment",
-			"error":   result.Error.Error(),
-		})
-	}
-
+	// 🗑 Record the deletion and delete the apartment (cascading deletions will occur based on your DB constraints)
+	err = deleteOwnApartment(apartment, uid)
 	// Check if any rows were affected.
-	if result.RowsAffected == 0 {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{
 			"message": "No apartment deleted. It may not exist or you might not have permission.",
 		})
 	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete apartment",
+			"error":   err.Error(),
+		})
+	}
 
 	// 🎉 Successfully deleted
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -135,23 +137,43 @@ func DeleteApartmentAny(c *fiber.Ctx) error {
 		})
 	}
 
-	// 🗑 Delete apartment (cascading deletes via foreign key constraints)
-	result := middleware.DBConn.Exec("DELETE FROM apartments WHERE id = ? AND uid = ?", apartment.ID, uid)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to delete apartment",
-			"error":   result.Error.Error(),
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	// 🗑 Record the deletion and delete the apartment (cascading deletes via foreign key constraints)
+	err = deleteOwnApartment(apartment, uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "No apartment deleted. It may not exist or you might not have permission.",
 		})
 	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete apartment",
+			"error":   err.Error(),
+		})
+	}
 
 	// ✅ Success
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Apartment and all related data deleted successfully",
 	})
 }
+
+// deleteOwnApartment records a "Deleted" moderation event for the landlord
+// and then deletes the apartment, in one transaction. The event has no
+// foreign key to the apartment, so it stays in the listing's history. It
+// returns gorm.ErrRecordNotFound, and records nothing, if no row was deleted.
+func deleteOwnApartment(apartment model.Apartment, uid string) error {
+	actor := moderation.Actor{UID: uid, Role: moderation.RoleLandlord}
+	return middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := moderation.Record(tx, apartment.ID, apartment.Status, moderation.StatusDeleted, actor, "", ""); err != nil {
+			return err
+		}
+		result := tx.Exec("DELETE FROM apartments WHERE id = ? AND uid = ?", apartment.ID, uid)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
 	"github.com/Conding-Student/backend/model/response"
+	"github.com/Conding-Student/backend/pagination"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -52,11 +53,34 @@ func FetchInquiriesByLandlord(c *fiber.Ctx) error {
 		})
 	}
 
-	var inquiries []InquiryResponse
+	page, err := pagination.Parse(c, pagination.Sorts{
+		Fields: map[string]pagination.Field{
+			"created_at": {Column: "inquiries.created_at", Type: pagination.TypeTime, Desc: true},
+			"expires_at": {Column: "inquiries.expires_at", Type: pagination.TypeTime},
+		},
+		Default: "created_at",
+		ID:      pagination.Field{Column: "inquiries.id", Type: pagination.TypeBigint},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	type inquiryRow struct {
+		InquiryResponse
+		pagination.Key
+	}
+	var rows []inquiryRow
 
 	// Query to join users and properties
-	err = middleware.DBConn.Model(&model.Inquiry{}).
-		Select(`
+	query := middleware.DBConn.Model(&model.Inquiry{}).
+		Joins("JOIN users ON users.uid = inquiries.tenant_uid").
+		Joins("JOIN apartments ON apartments.id = inquiries.property_id").
+		Where("apartments.uid = ?", uid)
+	err = page.Apply(page.Select(query, `
 	inquiries.id,
 	inquiries.tenant_uid,
 	users.fullname AS tenant_name,
@@ -67,13 +91,8 @@ func FetchInquiriesByLandlord(c *fiber.Ctx) error {
 	inquiries.initial_message,
 	inquiries.preferred_visit,
 	inquiries.created_at,
-	inquiries.expires_at
-`).
-		Joins("JOIN users ON users.uid = inquiries.tenant_uid").
-		Joins("JOIN apartments ON apartments.id = inquiries.property_id").
-		Where("apartments.uid = ?", uid).
-		Order("inquiries.created_at DESC").
-		Find(&inquiries).Error
+	inquiries.expires_at`)).
+		Find(&rows).Error
 
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
@@ -83,12 +102,19 @@ func FetchInquiriesByLandlord(c *fiber.Ctx) error {
 		})
 	}
 
+	rows, meta := pagination.Finish(page, rows, func(r inquiryRow) pagination.Key { return r.Key })
+	inquiries := make([]InquiryResponse, len(rows))
+	for i, row := range rows {
+		inquiries[i] = row.InquiryResponse
+	}
+
 	// Success response
 	return c.Status(fiber.StatusOK).JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Inquiries retrieved successfully",
 		Data: fiber.Map{
-			"inquiries": inquiries,
+			"inquiries":  inquiries,
+			"pagination": meta,
 		},
 	})
 }
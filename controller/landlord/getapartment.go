@@ -1,9 +1,15 @@
 package controller
 
 import (
+	"errors"
+
 	"github.com/Conding-Student/backend/config"
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
+	"github.com/Conding-Student/backend/pricehistory"
+	"github.com/Conding-Student/backend/repository"
+	"github.com/Conding-Student/backend/revision"
 
 	//"net/http"
 
@@ -12,23 +18,6 @@ import (
 )
 
 func FetchApartmentsByLandlord(c *fiber.Ctx) error {
-	type ApartmentDetails struct {
-		model.Apartment
-		LandlordName     string   `json:"landlord_name"`
-		LandlordEmail    string   `json:"landlord_email"`
-		LandlordPhone    string   `json:"landlord_phone"`
-		LandlordAddress  string   `json:"landlord_address"`
-		LandlordValidID  string   `json:"landlord_valid_id"`
-		LandlordPhotoURL string   `json:"landlord_photo_url"`
-		LandlordUserType string   `json:"landlord_user_type"`
-		LandlordStatus   string   `json:"landlord_account_status"`
-		Images           []string `json:"images"`
-		Videos           []string `json:"videos"`
-		Amenities        []string `json:"amenities"`
-		HouseRules       []string `json:"house_rules"`
-		InquiriesCount   int64    `json:"inquiries_count"`
-	}
-
 	// Extract user claims from JWT
 	userClaims, ok := c.Locals("user").(jwt.MapClaims)
 	if !ok {
@@ -45,82 +34,37 @@ func FetchApartmentsByLandlord(c *fiber.Ctx) error {
 	}
 
 	var apartments []model.Apartment
-	if err := middleware.DBConn.Where("uid = ?", uid).Find(&apartments).Error; err != nil {
+	if err := middleware.DBConn.Where("uid = ?", uid).Order("id").Find(&apartments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Database error: Unable to fetch apartments",
 			"error":   err.Error(),
 		})
 	}
 
-	if len(apartments) == 0 {
+	// ?format=csv|jsonl downloads the listings for ImportApartments
+	exportFormat := ""
+	if c.Query("format") != "" {
+		var err error
+		if exportFormat, err = listingFormat(c, ""); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+
+	if len(apartments) == 0 && exportFormat == "" {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "No apartments found for this landlord",
 		})
 	}
 
-	// Fetch landlord details once (all apartments share same landlord UID)
-	var landlord model.User
-	if err := middleware.DBConn.Where("uid = ?", uid).First(&landlord).Error; err != nil {
+	results, err := repository.LoadApartmentDetails(middleware.DBConn, apartments)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Unable to fetch landlord details",
+			"message": "Unable to fetch apartment details",
 			"error":   err.Error(),
 		})
 	}
-
-	var results []ApartmentDetails
-
-	for _, apt := range apartments {
-		var images []model.ApartmentImage
-		middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&images)
-		var imageUrls []string
-		for _, img := range images {
-			imageUrls = append(imageUrls, img.ImageURL)
-		}
-
-		var videos []model.ApartmentVideo
-		middleware.DBConn.Where("apartment_id = ?", apt.ID).Find(&videos)
-		var videoUrls []string
-		for _, vid := range videos {
-			videoUrls = append(videoUrls, vid.VideoURL)
-		}
-
-		var amenities []model.Amenity
-		middleware.DBConn.
-			Joins("JOIN apartment_amenities ON amenities.id = apartment_amenities.amenity_id").
-			Where("apartment_amenities.apartment_id = ?", apt.ID).Find(&amenities)
-		var amenityNames []string
-		for _, a := range amenities {
-			amenityNames = append(amenityNames, a.Name)
-		}
-
-		var houseRules []model.HouseRule
-		middleware.DBConn.
-			Joins("JOIN apartment_house_rules ON house_rules.id = apartment_house_rules.house_rule_id").
-			Where("apartment_house_rules.apartment_id = ?", apt.ID).Find(&houseRules)
-		var ruleNames []string
-		for _, r := range houseRules {
-			ruleNames = append(ruleNames, r.Rule)
-		}
-
-		var inquiryCount int64
-		middleware.DBConn.Model(&model.Inquiry{}).Where("property_id = ?", apt.ID).Count(&inquiryCount)
-
-		results = append(results, ApartmentDetails{
-			Apartment:        apt,
-			LandlordName:     landlord.Fullname,
-			LandlordEmail:    landlord.Email,
-			LandlordPhone:    landlord.PhoneNumber,
-			LandlordAddress:  landlord.Address,
-			LandlordValidID:  landlord.ValidID,
-			LandlordPhotoURL: landlord.PhotoURL,
-			LandlordUserType: landlord.UserType,
-			LandlordStatus:   landlord.AccountStatus,
-			Images:           imageUrls,
-			Videos:           videoUrls,
-			Amenities:        amenityNames,
-			HouseRules:       ruleNames,
-			InquiriesCount:   inquiryCount,
-		})
+	if exportFormat != "" {
+		return sendListingExport(c, exportFormat, results)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -128,7 +72,9 @@ func FetchApartmentsByLandlord(c *fiber.Ctx) error {
 	})
 }
 
-// update apartment details
+// update apartment details. Changes to the details, amenities, house rules or
+// media of an approved listing are held as a revision until an admin
+// approves them.
 
 func UpdateApartment(c *fiber.Ctx) error {
 	type UpdateInput struct {
@@ -173,6 +119,32 @@ func UpdateApartment(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid input", "error": err.Error()})
 	}
 
+	// Upload new media first; like the other fields it replaces the
+	// listing's media once the changes apply
+	var imageURLs []string
+	for _, img := range input.ImageURLs {
+		url, err := config.UploadImage(img)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to upload image",
+				"error":   err.Error(),
+			})
+		}
+		imageURLs = append(imageURLs, url)
+	}
+
+	var videoURLs []string
+	for _, vid := range input.VideoURLs {
+		url, err := config.UploadVideo(vid)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to upload video",
+				"error":   err.Error(),
+			})
+		}
+		videoURLs = append(videoURLs, url)
+	}
+
 	// Start transaction
 	tx := middleware.DBConn.Begin()
 	defer func() {
@@ -181,179 +153,60 @@ func UpdateApartment(c *fiber.Ctx) error {
 		}
 	}()
 
-	// 1. Update property details
+	// Update property details, amenities, house rules and media. Edits to an
+	// approved listing wait for an admin as a revision instead.
+	changes := revision.Changes{Amenities: input.Amenities, HouseRules: input.HouseRules}
 	if input.PropertyName != "" {
-		apartment.PropertyName = input.PropertyName
+		changes.PropertyName = &input.PropertyName
 	}
 	if input.Address != "" {
-		apartment.Address = input.Address
+		changes.Address = &input.Address
 	}
 	if input.PropertyType != "" {
-		apartment.PropertyType = input.PropertyType
+		changes.PropertyType = &input.PropertyType
 	}
 	if input.RentPrice != 0 {
-		apartment.RentPrice = input.RentPrice
+		changes.RentPrice = &input.RentPrice
 	}
 	if input.LocationLink != "" {
-		apartment.LocationLink = input.LocationLink
+		changes.LocationLink = &input.LocationLink
 	}
 	if input.Landmarks != "" {
-		apartment.Landmarks = input.Landmarks
-	}
-	if input.Latitude != nil {
-		apartment.Latitude = *input.Latitude
-	}
-	if input.Longitude != nil {
-		apartment.Longitude = *input.Longitude
-	}
-
-	if err := tx.Save(&apartment).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to update apartment details",
-			"error":   err.Error(),
-		})
+		changes.Landmarks = &input.Landmarks
 	}
-
-	// 2. Handle media updates
-	var imageURLs []string
-	if len(input.ImageURLs) > 0 {
-		// First delete existing images if we're replacing them
-		if err := tx.Where("apartment_id = ?", apartment.ID).Delete(&model.ApartmentImage{}).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to clear existing images",
-				"error":   err.Error(),
-			})
-		}
-
-		// Upload and save new images
-		for _, img := range input.ImageURLs {
-			url, err := config.UploadImage(img)
-			if err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to upload image",
-					"error":   err.Error(),
-				})
-			}
-			imageURLs = append(imageURLs, url)
-			if err := tx.Create(&model.ApartmentImage{
-				ApartmentID: apartment.ID,
-				ImageURL:    url,
-			}).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to save image URL",
-					"error":   err.Error(),
-				})
-			}
-		}
+	changes.Latitude, changes.Longitude = input.Latitude, input.Longitude
+	if len(imageURLs) > 0 {
+		changes.Images = &imageURLs
 	}
-
-	var videoURLs []string
-	if len(input.VideoURLs) > 0 {
-		// First delete existing videos if we're replacing them
-		if err := tx.Where("apartment_id = ?", apartment.ID).Delete(&model.ApartmentVideo{}).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to clear existing videos",
-				"error":   err.Error(),
-			})
-		}
-
-		// Upload and save new videos
-		for _, vid := range input.VideoURLs {
-			url, err := config.UploadVideo(vid)
-			if err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to upload video",
-					"error":   err.Error(),
-				})
-			}
-			videoURLs = append(videoURLs, url)
-			if err := tx.Create(&model.ApartmentVideo{
-				ApartmentID: apartment.ID,
-				VideoURL:    url,
-			}).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to save video URL",
-					"error":   err.Error(),
-				})
-			}
-		}
+	if len(videoURLs) > 0 {
+		changes.Videos = &videoURLs
 	}
 
-	// 3. Update amenities if provided
-	if input.Amenities != nil {
-		// Clear existing amenities
-		if err := tx.Where("apartment_id = ?", apartment.ID).Delete(&model.ApartmentAmenity{}).Error; err != nil {
+	oldPrice := apartment.RentPrice
+	var priceDropped bool
+	var pending *model.ApartmentRevision
+	if apartment.Status == moderation.StatusApproved {
+		rev, dropped, err := revision.Submit(tx, &apartment, uid, changes)
+		if err != nil && !errors.Is(err, revision.ErrNoChanges) {
 			tx.Rollback()
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to clear existing amenities",
+				"message": "Failed to submit changes for review",
 				"error":   err.Error(),
 			})
 		}
-
-		// Add new amenities
-		for _, amenityName := range *input.Amenities {
-			var amenity model.Amenity
-			if err := tx.FirstOrCreate(&amenity, model.Amenity{Name: amenityName}).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to find or create amenity",
-					"error":   err.Error(),
-				})
-			}
-
-			if err := tx.Create(&model.ApartmentAmenity{
-				ApartmentID: apartment.ID,
-				AmenityID:   amenity.ID,
-			}).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to link amenity to apartment",
-					"error":   err.Error(),
-				})
-			}
+		if err == nil {
+			pending, priceDropped = &rev, dropped
 		}
-	}
-
-	// 4. Update house rules if provided
-	if input.HouseRules != nil {
-		// Clear existing house rules
-		if err := tx.Where("apartment_id = ?", apartment.ID).Delete(&model.ApartmentHouseRule{}).Error; err != nil {
+	} else {
+		dropped, _, err := revision.Apply(tx, &apartment, changes, uid)
+		if err != nil {
 			tx.Rollback()
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to clear existing house rules",
+				"message": "Failed to update apartment details",
 				"error":   err.Error(),
 			})
 		}
-
-		// Add new house rules
-		for _, rule := range *input.HouseRules {
-			var houseRule model.HouseRule
-			if err := tx.FirstOrCreate(&houseRule, model.HouseRule{Rule: rule}).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to find or create house rule",
-					"error":   err.Error(),
-				})
-			}
-
-			if err := tx.Create(&model.ApartmentHouseRule{
-				ApartmentID: apartment.ID,
-				HouseRuleID: houseRule.ID,
-			}).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Failed to link house rule to apartment",
-					"error":   err.Error(),
-				})
-			}
-		}
+		priceDropped = dropped
 	}
 
 	// Commit transaction
@@ -364,14 +217,31 @@ func UpdateApartment(c *fiber.Ctx) error {
 		})
 	}
 
+	if priceDropped {
+		go pricehistory.NotifyDrop(apartment.ID, oldPrice, apartment.RentPrice)
+	}
+
+	message := "Apartment updated successfully"
+	var submitted *revision.Detail
+	if pending != nil {
+		detail, err := revision.Describe(middleware.DBConn, *pending)
+		if err == nil {
+			submitted = &detail
+		}
+		if pending.Status == revision.StatusPending {
+			message = "Changes submitted for admin review; the listing stays as it is until they are approved"
+		}
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Apartment updated successfully",
+		"message": message,
 		"data": fiber.Map{
 			"apartment":   apartment,
 			"image_urls":  imageURLs,
 			"video_urls":  videoURLs,
 			"amenities":   input.Amenities,
 			"house_rules": input.HouseRules,
+			"revision":    submitted,
 		},
 	})
 }
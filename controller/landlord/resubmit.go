@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ResubmitApartment sends a rejected listing back to the review queue once
+// the landlord has edited it. An optional note tells the reviewer what
+// changed.
+func ResubmitApartment(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request format"})
+		}
+	}
+
+	var apartment model.Apartment
+	if err := middleware.DBConn.First(&apartment, "id = ? AND uid = ?", c.Params("id"), uid).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	actor := moderation.Actor{UID: uid, Role: moderation.RoleLandlord}
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		return moderation.Transition(tx, &apartment, moderation.StatusResubmitted, actor, "", strings.TrimSpace(req.Note), nil)
+	})
+	if errors.Is(err, moderation.ErrInvalidTransition) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Only rejected apartments can be resubmitted"})
+	}
+	if errors.Is(err, moderation.ErrStatusChanged) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to resubmit apartment",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Apartment resubmitted for review",
+		"apartment_id": apartment.ID,
+		"status":       apartment.Status,
+		"submitted_at": apartment.SubmittedAt,
+	})
+}
+
+// FetchApartmentModerationHistory shows the landlord every review decision
+// on one of their listings, including rejection reasons.
+func FetchApartmentModerationHistory(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+	}
+
+	var apartment model.Apartment
+	if err := middleware.DBConn.First(&apartment, "id = ? AND uid = ?", c.Params("id"), uid).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	events, err := moderation.History(middleware.DBConn, apartment.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch moderation history",
+			"error":   err.Error(),
+		})
+	}
+
+	// Reviewers stay anonymous to landlords
+	for i := range events {
+		if events[i].ActorRole == moderation.RoleAdmin {
+			events[i].ActorUID = ""
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"apartment_id": apartment.ID,
+		"status":       apartment.Status,
+		"history":      events,
+	})
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/revision"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FetchApartmentRevisions lists the landlord's edits to one of their
+// listings, newest first, with how pending ones differ from the live listing.
+func FetchApartmentRevisions(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+	}
+
+	var apartment model.Apartment
+	if err := middleware.DBConn.First(&apartment, "id = ? AND uid = ?", c.Params("id"), uid).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	var revisions []model.ApartmentRevision
+	if err := middleware.DBConn.Where("apartment_id = ?", apartment.ID).Order("created_at DESC, id DESC").Find(&revisions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch revisions",
+			"error":   err.Error(),
+		})
+	}
+
+	details := make([]revision.Detail, 0, len(revisions))
+	for _, r := range revisions {
+		detail, err := revision.Describe(middleware.DBConn, r)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to compare revision",
+				"error":   err.Error(),
+			})
+		}
+		// Reviewers stay anonymous to landlords
+		detail.ReviewedBy = ""
+		details = append(details, detail)
+	}
+
+	return c.JSON(fiber.Map{
+		"apartment_id": apartment.ID,
+		"revisions":    details,
+	})
+}
+
+// WithdrawApartmentRevision cancels the landlord's pending revision. The
+// listing keeps its current values.
+func WithdrawApartmentRevision(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid revision ID"})
+	}
+
+	withdrawn, err := revision.Withdraw(middleware.DBConn, uint(id), uid)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to withdraw revision",
+			"error":   err.Error(),
+		})
+	}
+	if !withdrawn {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "No pending revision with that ID"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Revision withdrawn"})
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// UnitRequest is the body of the unit endpoints. On update, omitted fields
+// keep their current value.
+type UnitRequest struct {
+	Label         *string  `json:"label"`
+	UnitType      *string  `json:"unit_type"`
+	RentPrice     *float64 `json:"rent_price"`
+	Capacity      *int     `json:"capacity"`
+	AllowedGender *string  `json:"allowed_gender"`
+	Availability  *string  `json:"availability"`
+}
+
+func isValidUnitType(t string) bool {
+	return t == "room" || t == "bedspace" || t == "studio"
+}
+
+// apply copies the set fields onto unit and checks the result.
+func (req UnitRequest) apply(unit *model.ApartmentUnit) string {
+	if req.Label != nil {
+		unit.Label = strings.TrimSpace(*req.Label)
+	}
+	if req.UnitType != nil {
+		unit.UnitType = strings.ToLower(strings.TrimSpace(*req.UnitType))
+	}
+	if req.RentPrice != nil {
+		unit.RentPrice = *req.RentPrice
+	}
+	if req.Capacity != nil {
+		unit.Capacity = *req.Capacity
+	}
+	if req.AllowedGender != nil {
+		unit.AllowedGender = strings.TrimSpace(*req.AllowedGender)
+	}
+	if req.Availability != nil {
+		unit.Availability = *req.Availability
+	}
+
+	switch {
+	case unit.Label == "":
+		return "label is required"
+	case !isValidUnitType(unit.UnitType):
+		return "unit_type must be room, bedspace or studio"
+	case unit.RentPrice <= 0:
+		return "rent_price must be greater than 0"
+	case unit.Capacity < 1:
+		return "capacity must be at least 1"
+	case !isValidAvailability(unit.Availability):
+		return "Availability must be either 'Available' or 'Not Available'"
+	}
+	return ""
+}
+
+// labelTaken reports whether another unit of the apartment uses the label.
+func labelTaken(unit model.ApartmentUnit) (bool, error) {
+	var count int64
+	err := middleware.DBConn.Model(&model.ApartmentUnit{}).
+		Where("apartment_id = ? AND label = ? AND id <> ?", unit.ApartmentID, unit.Label, unit.ID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ownedApartment loads the apartment in :id if the caller is its landlord.
+func ownedApartment(c *fiber.Ctx) (model.Apartment, error) {
+	var apartment model.Apartment
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return apartment, err
+	}
+	err = middleware.DBConn.First(&apartment, "id = ? AND uid = ?", c.Params("id"), uid).Error
+	return apartment, err
+}
+
+// FetchApartmentUnits lists the rooms and bedspaces of one of the landlord's
+// apartments.
+func FetchApartmentUnits(c *fiber.Ctx) error {
+	apartment, err := ownedApartment(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	var units []model.ApartmentUnit
+	if err := middleware.DBConn.Where("apartment_id = ?", apartment.ID).Order("label").Find(&units).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch units",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"apartment_id": apartment.ID,
+		"units":        units,
+	})
+}
+
+// CreateApartmentUnit adds a room or bedspace to an apartment. Once an
+// apartment has units, its listed price range and availability come from
+// them.
+func CreateApartmentUnit(c *fiber.Ctx) error {
+	apartment, err := ownedApartment(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	var req UnitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request format"})
+	}
+
+	unit := model.ApartmentUnit{
+		ApartmentID:  apartment.ID,
+		UnitType:     "room",
+		Capacity:     1,
+		Availability: "Available",
+	}
+	if msg := req.apply(&unit); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": msg})
+	}
+
+	if taken, err := labelTaken(unit); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check unit label",
+			"error":   err.Error(),
+		})
+	} else if taken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "This apartment already has a unit with that label"})
+	}
+
+	if err := middleware.DBConn.Create(&unit).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create unit",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Unit created",
+		"unit":    unit,
+	})
+}
+
+// UpdateApartmentUnit edits a unit's label, type, rent, capacity, gender
+// policy or availability.
+func UpdateApartmentUnit(c *fiber.Ctx) error {
+	apartment, err := ownedApartment(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	var unit model.ApartmentUnit
+	if err := middleware.DBConn.First(&unit, "id = ? AND apartment_id = ?", c.Params("unitId"), apartment.ID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Unit not found"})
+	}
+
+	var req UnitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request format"})
+	}
+	if msg := req.apply(&unit); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": msg})
+	}
+
+	if taken, err := labelTaken(unit); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check unit label",
+			"error":   err.Error(),
+		})
+	} else if taken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "This apartment already has a unit with that label"})
+	}
+
+	if err := middleware.DBConn.Save(&unit).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update unit",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Unit updated",
+		"unit":    unit,
+	})
+}
+
+// DeleteApartmentUnit removes a unit nobody is renting. Inquiries and past
+// agreements that named it fall back to the whole apartment.
+func DeleteApartmentUnit(c *fiber.Ctx) error {
+	apartment, err := ownedApartment(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Apartment not found or unauthorized"})
+	}
+
+	var unit model.ApartmentUnit
+	if err := middleware.DBConn.First(&unit, "id = ? AND apartment_id = ?", c.Params("unitId"), apartment.ID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Unit not found"})
+	}
+
+	var renting int64
+	if err := middleware.DBConn.Model(&model.RentalAgreement{}).
+		Where("unit_id = ? AND is_active = ?", unit.ID, true).
+		Count(&renting).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to check rental agreements",
+			"error":   err.Error(),
+		})
+	}
+	if renting > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "This unit has active rental agreements"})
+	}
+
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Inquiry{}).Where("unit_id = ?", unit.ID).Update("unit_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.RentalAgreement{}).Where("unit_id = ?", unit.ID).Update("unit_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&unit).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete unit",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Unit deleted",
+		"unit_id": unit.ID,
+	})
+}
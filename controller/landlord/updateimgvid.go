@@ -3,9 +3,10 @@ package controller
 import (
 	"fmt"
 
-	"github.com/Conding-Student/backend/config"
+	"github.com/Conding-Student/backend/availability"
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/savedsearch"
 
 	//"intern_template_v1/model/response"
 	"net/http"
@@ -18,8 +19,10 @@ import (
 )
 
 type UpdateMediaRequest struct {
-	ImageURLs []string `json:"image_urls"`
-	VideoURLs []string `json:"video_urls"`
+	ImageURLs     []string `json:"image_urls"`
+	VideoURLs     []string `json:"video_urls"`
+	ImageAssetIDs []uint   `json:"image_asset_ids"` // from /media/uploads
+	VideoAssetIDs []uint   `json:"video_asset_ids"`
 }
 
 func UpdateApartmentMedia(c *fiber.Ctx) error {
@@ -72,46 +75,11 @@ func UpdateApartmentMedia(c *fiber.Ctx) error {
 		})
 	}
 
-	// Upload new images
-	var imageURLs []string
-	for _, img := range req.ImageURLs {
-		url, err := config.UploadImage(img)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to upload image to Cloudinary",
-				"error":   err.Error(),
-			})
-		}
-		imageURLs = append(imageURLs, url)
-		if err := tx.Create(&model.ApartmentImage{ApartmentID: apartment.ID, ImageURL: url}).Error; err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to save image URL",
-				"error":   err.Error(),
-			})
-		}
-	}
-
-	// Upload new videos
-	var videoURLs []string
-	for _, vid := range req.VideoURLs {
-		url, err := config.UploadVideo(vid)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to upload video to Cloudinary",
-				"error":   err.Error(),
-			})
-		}
-		videoURLs = append(videoURLs, url)
-		if err := tx.Create(&model.ApartmentVideo{ApartmentID: apartment.ID, VideoURL: url}).Error; err != nil {
-			tx.Rollback()
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Failed to save video URL",
-				"error":   err.Error(),
-			})
-		}
+	// Attach uploaded assets and upload new URLs
+	imageURLs, videoURLs, err := attachMedia(tx, uid, apartment.ID, req)
+	if err != nil {
+		tx.Rollback()
+		return assetError(c, err, "Failed to upload media to Cloudinary")
 	}
 
 	// Commit transaction
@@ -210,14 +178,17 @@ func UpdateApartmentAvailability(c *fiber.Ctx) error {
 	}
 
 	// Expiration Logic
+	var openUntil time.Time
 	switch req.Availability {
 	case "Available":
 		if apartment.ExpiresAt != nil && apartment.ExpiresAt.After(time.Now()) {
 			fmt.Printf("[BUSINESS] Keeping existing expiration: %v\n", apartment.ExpiresAt)
 			updates["expires_at"] = apartment.ExpiresAt
+			openUntil = *apartment.ExpiresAt
 		} else {
 			newExpiration := time.Now().Add(14 * 24 * time.Hour)
 			updates["expires_at"] = newExpiration
+			openUntil = newExpiration
 			fmt.Printf("[DEBUG] New expiration set: %v\n", newExpiration)
 		}
 	case "Not Available":
@@ -225,11 +196,22 @@ func UpdateApartmentAvailability(c *fiber.Ctx) error {
 		fmt.Println("[DEBUG] Clearing expiration time")
 	}
 
-	// Database Operation
-	if err := middleware.DBConn.
-		Model(&model.Apartment{}).
-		Where("id = ? AND status = 'Approved'", apartmentID). // Additional safety check
-		Updates(updates).Error; err != nil {
+	// Database Operation. The shortcut is written to the calendar too, so
+	// "Available" opens today until the expiration and "Not Available"
+	// closes today onwards, keeping later windows.
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Model(&model.Apartment{}).
+			Where("id = ? AND status = 'Approved'", apartmentID). // Additional safety check
+			Updates(updates).Error; err != nil {
+			return err
+		}
+		if req.Availability == "Available" {
+			return availability.OpenNow(tx, apartment.ID, openUntil)
+		}
+		return availability.CloseNow(tx, apartment.ID)
+	})
+	if err != nil {
 
 		fmt.Println("[DATABASE] Update error:", err.Error())
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
@@ -238,6 +220,11 @@ func UpdateApartmentAvailability(c *fiber.Ctx) error {
 		})
 	}
 
+	// Relisting alerts tenants whose saved searches match
+	if req.Availability == "Available" && apartment.Availability != "Available" {
+		go savedsearch.NotifyListing(apartment.ID)
+	}
+
 	fmt.Println("[SUCCESS] Availability updated for apartment ID:", apartmentID)
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"message":    "Property availability updated",
@@ -265,9 +252,10 @@ func ManageApartmentExpirations() {
 		fmt.Printf("[%s] Starting expiration availability cycle\n", currentTime.Format(time.RFC3339))
 		startTime := currentTime
 
-		// Update expired apartments
+		// Update expired apartments; those with a calendar follow it instead
 		result := middleware.DBConn.Model(&model.Apartment{}).
 			Where("expires_at < ? AND availability = ?", currentTime, "Available").
+			Where("NOT EXISTS (SELECT 1 FROM availability_windows WHERE availability_windows.apartment_id = apartments.id)").
 			Updates(map[string]interface{}{
 				"availability": "Not Available",
 				"expires_at":   gorm.Expr("NULL"),
@@ -287,6 +275,39 @@ func ManageApartmentExpirations() {
 			}
 		}
 
+		// Open and close listings and units whose calendar changed today
+		opened, err := availability.Sync(middleware.DBConn, 0)
+		if err != nil {
+			fmt.Printf("[%s] Error syncing availability calendars: %v\n",
+				currentTime.Format(time.RFC3339), err)
+		}
+		for _, id := range opened {
+			go savedsearch.NotifyListing(id)
+		}
+		if len(opened) > 0 {
+			fmt.Printf("[%s] Calendar opened %d apartments\n",
+				currentTime.Format(time.RFC3339), len(opened))
+		}
+
+		// Expire paid promotions that have run out
+		featured := middleware.DBConn.Model(&model.Apartment{}).
+			Where("featured_until < ?", currentTime).
+			Update("featured_until", gorm.Expr("NULL"))
+		if featured.Error != nil {
+			fmt.Printf("[%s] Error expiring featured apartments: %v\n",
+				currentTime.Format(time.RFC3339), featured.Error)
+		} else if featured.RowsAffected > 0 {
+			fmt.Printf("[%s] Expired promotion of %d featured apartments\n",
+				currentTime.Format(time.RFC3339), featured.RowsAffected)
+		}
+
+		if err := middleware.DBConn.Model(&model.ApartmentPromotion{}).
+			Where("status = ? AND ends_at < ?", "active", currentTime).
+			Update("status", "expired").Error; err != nil {
+			fmt.Printf("[%s] Error expiring promotions: %v\n",
+				currentTime.Format(time.RFC3339), err)
+		}
+
 		// Always log cycle duration
 		duration := time.Since(startTime)
 		fmt.Printf("[%s] Cycle duration: %s\n",
@@ -2,12 +2,12 @@ package controller
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"runtime/debug"
 
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -15,272 +15,320 @@ import (
 	"time"
 
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/pagination"
+	"github.com/Conding-Student/backend/payment"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// PayMongoService exposes the payment endpoints. Gateway calls go through
+// Provider, so the same handlers work against PayMongo or the offline fake.
 type PayMongoService struct {
-	DB        *gorm.DB
-	PublicKey string
-	SecretKey string
-}
-
-type SourceRequest struct {
-	Data struct {
-		Attributes struct {
-			Amount   int    `json:"amount"`
-			Currency string `json:"currency"`
-			Type     string `json:"type"`
-			Redirect struct {
-				Success string `json:"success"`
-				Failed  string `json:"failed"`
-			} `json:"redirect"`
-		} `json:"attributes"`
-	} `json:"data"`
-}
-
-type SourceResponse struct {
-	Data struct {
-		ID         string `json:"id"`
-		Attributes struct {
-			Redirect struct {
-				CheckoutURL string `json:"checkout_url"`
-			} `json:"redirect"`
-			Status string `json:"status"`
-		} `json:"attributes"`
-	} `json:"data"`
+	DB       *gorm.DB
+	Provider payment.PaymentProvider
+	Config   payment.Config
 }
 
 type WebhookPayload struct {
 	Data struct {
+		ID         string `json:"id"` // event ID, unique per event across retries
 		Attributes struct {
-			Type string `json:"type"`
-			Data struct {
-				ID         string `json:"id"`
+			Type     string `json:"type"`
+			Livemode bool   `json:"livemode"`
+			Data     struct {
+				ID         string `json:"id"` // source ID, or payment ID for payment.* events
 				Attributes struct {
 					Amount int    `json:"amount"`
 					Status string `json:"status"`
+					Source *struct {
+						ID string `json:"id"`
+					} `json:"source"` // payment.* events for source-based payments
+					PaymentIntentID string `json:"payment_intent_id"` // payment.* events for card / Maya
+					FailedMessage   string `json:"failed_message"`
+					Refunds         []struct {
+						ID         string `json:"id"`
+						Attributes struct {
+							Amount int    `json:"amount"`
+							Status string `json:"status"`
+						} `json:"attributes"`
+					} `json:"refunds"` // payment.refunded events
 				} `json:"attributes"`
 			} `json:"data"`
 		} `json:"attributes"`
 	} `json:"data"`
 }
 
-// CreateSource creates a PayMongo GCash source and saves transaction
-func (s *PayMongoService) CreateSource(c *fiber.Ctx) error {
-	type Request struct {
-	UserID     string  `json:"user_id"`
-	BaseAmount float64 `json:"base_amount"`
-	Availment  string  `json:"availment"` // 👈 e.g. "Ad Post", "Deposit", etc.
+// CheckoutInput describes a payment to collect through StartCheckout.
+type CheckoutInput struct {
+	UserID        string
+	BaseAmount    float64
+	Availment     string // e.g. "Ad Post", "Rent Invoice"
+	PaymentMethod string // gcash, grab_pay, paymaya or card
+	ApartmentID   *uint  // apartment the payment is for, when there is one
+	InvoiceID     *uint  // rent invoice the payment settles, when there is one
 }
 
+// PaidHook runs in the same database transaction that marks a Transaction
+// paid. Returning an error rolls the whole update back, so the webhook is
+// retried later.
+type PaidHook func(tx *gorm.DB, txn *model.Transaction) error
 
-	var req Request
-	if err := c.BodyParser(&req); err != nil {
-		log.Printf("Body parsing error: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
-	}
+var paidHooks []PaidHook
 
-	// Validate input
-	if req.UserID == "" || req.BaseAmount <= 0 {
-		log.Printf("Invalid input: user_id=%s, base_amount=%.2f", req.UserID, req.BaseAmount)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "UserID and positive BaseAmount required"})
-	}
+// RegisterPaidHook lets other subsystems (invoices, promotions, the ledger)
+// react when a payment they started is settled.
+func RegisterPaidHook(hook PaidHook) {
+	paidHooks = append(paidHooks, hook)
+}
 
-	// Calculate 0.2% interest
-	interest := req.BaseAmount * 0.002
-	totalAmount := req.BaseAmount + interest
-	amountInCentavos := int(totalAmount * 100) // Convert to centavos
+// StartCheckout adds the configured fee to the base amount, opens a checkout
+// with the provider for the given payment method and records the pending
+// transaction. Other flows that need to collect a payment (invoices,
+// promotions) go through here as well.
+func (s *PayMongoService) StartCheckout(ctx context.Context, in CheckoutInput) (*model.Transaction, *payment.Checkout, error) {
+	method, baseAmount, availment := in.PaymentMethod, in.BaseAmount, in.Availment
+	if method == "" {
+		method = payment.MethodGCash
+	}
+	if _, ok := payment.MethodKind(method); !ok {
+		return nil, nil, fmt.Errorf("unsupported payment method %q", method)
+	}
 
-	// Create PayMongo source
-	sourceReq := SourceRequest{}
-	sourceReq.Data.Attributes.Amount = amountInCentavos
-	sourceReq.Data.Attributes.Currency = "PHP"
-	sourceReq.Data.Attributes.Type = "gcash"
-	sourceReq.Data.Attributes.Redirect.Success = "https://bd1a-103-72-190-240.ngrok-free.app/success"
-	sourceReq.Data.Attributes.Redirect.Failed = "https://bd1a-103-72-190-240.ngrok-free.app/failed"
+	fee, totalAmount := s.Config.ApplyFee(baseAmount)
 
-	body, err := json.Marshal(sourceReq)
+	checkout, err := s.Provider.CreateCheckout(ctx, payment.CheckoutRequest{
+		Amount:      payment.ToCentavos(totalAmount),
+		Currency:    s.Config.Currency,
+		Method:      method,
+		Description: availment,
+	})
 	if err != nil {
-		log.Printf("Failed to marshal source request: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create source request"})
+		return nil, nil, err
 	}
 
-	client := &http.Client{}
-	paymongoReq, err := http.NewRequest("POST", "https://api.paymongo.com/v1/sources", bytes.NewBuffer(body))
-	if err != nil {
-		log.Printf("Failed to create PayMongo request: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create PayMongo request"})
+	if checkout.Status != "pending" && checkout.Status != "awaiting_next_action" {
+		return nil, nil, fmt.Errorf("unexpected checkout status %q", checkout.Status)
 	}
-	paymongoReq.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(s.PublicKey+":")))
-	paymongoReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(paymongoReq)
-	if err != nil {
-		log.Printf("PayMongo API error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "PayMongo API error"})
+	txn := model.Transaction{
+		UserID:         in.UserID,
+		ApartmentID:    in.ApartmentID,
+		InvoiceID:      in.InvoiceID,
+		BaseAmount:     baseAmount,
+		InterestAmount: fee,
+		TotalAmount:    totalAmount,
+		PaymentMethod:  method,
+		Status:         checkout.Status,
+		Availment:      availment,
+	}
+	if checkout.Kind == payment.KindPaymentIntent {
+		txn.PayMongoPaymentIntentID = checkout.ID
+	} else {
+		txn.PayMongoSourceID = checkout.ID
+	}
+	if err := s.DB.Create(&txn).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to save transaction: %v", err)
 	}
-	defer resp.Body.Close()
 
-	// Read and log response body
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read PayMongo response: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read PayMongo response"})
+	return &txn, checkout, nil
+}
+
+// CreateSource starts a checkout for the requested payment method (GCash by
+// default) and saves the pending transaction.
+func (s *PayMongoService) CreateSource(c *fiber.Ctx) error {
+	type Request struct {
+		UserID        string  `json:"user_id"` // optional, must be the caller's uid
+		BaseAmount    float64 `json:"base_amount"`
+		Availment     string  `json:"availment"`      // 👈 e.g. "Ad Post", "Deposit", etc.
+		PaymentMethod string  `json:"payment_method"` // gcash, grab_pay, paymaya or card
+		ApartmentID   *uint   `json:"apartment_id"`   // optional, ties the payment to a listing
 	}
-	log.Printf("PayMongo response: status=%d, body=%s", resp.StatusCode, string(respBody))
 
-	if resp.StatusCode != http.StatusOK {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "PayMongo source creation failed",
-			"details": string(respBody),
-		})
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		log.Printf("Body parsing error: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	var sourceResp SourceResponse
-	if err := json.Unmarshal(respBody, &sourceResp); err != nil {
-		log.Printf("Failed to parse PayMongo response: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse PayMongo response"})
+	// The payer is always the logged-in user; user_id is only accepted if it agrees
+	userClaims, ok := c.Locals("user").(jwt.MapClaims)
+	uid, _ := userClaims["uid"].(string)
+	if !ok || uid == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if req.UserID != "" && req.UserID != uid {
+		log.Printf("User %s tried to open a checkout for %s", uid, req.UserID)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "user_id does not match the logged-in user"})
 	}
+	req.UserID = uid
 
-	// Verify source status
-	if sourceResp.Data.Attributes.Status != "pending" {
-		log.Printf("Unexpected source status: %s", sourceResp.Data.Attributes.Status)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Invalid source status",
-			"details": string(respBody),
-		})
+	// Validate input
+	if req.BaseAmount <= 0 {
+		log.Printf("Invalid input: user_id=%s, base_amount=%.2f", req.UserID, req.BaseAmount)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Positive BaseAmount required"})
 	}
 
-	// Save transaction to PostgreSQL
-txn := model.Transaction{
-	UserID:           req.UserID,
-	BaseAmount:       req.BaseAmount,
-	InterestAmount:   interest,
-	TotalAmount:      totalAmount,
-	PayMongoSourceID: sourceResp.Data.ID,
-	Status:           "pending",
-	Availment:        req.Availment, // 👈 add this
-}
+	// Payments for a listing are credited to its landlord, so it must be live
+	if req.ApartmentID != nil {
+		var approved int64
+		if err := s.DB.Model(&model.Apartment{}).
+			Where("id = ? AND status = ?", *req.ApartmentID, "Approved").
+			Count(&approved).Error; err != nil {
+			log.Printf("Failed to look up apartment %d: %v", *req.ApartmentID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+		if approved == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Apartment not found or not approved"})
+		}
+	}
 
-	if err := s.DB.Create(&txn).Error; err != nil {
-		log.Printf("Database error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = payment.MethodGCash
+	}
+	if _, ok := payment.MethodKind(req.PaymentMethod); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Unsupported payment_method",
+			"allowed": []string{payment.MethodGCash, payment.MethodGrabPay, payment.MethodMaya, payment.MethodCard},
+		})
 	}
 
-return c.JSON(fiber.Map{
-	"checkout_url": sourceResp.Data.Attributes.Redirect.CheckoutURL,
-	"source_id":    sourceResp.Data.ID,
-	"availment":    txn.Availment,
-})
+	txn, checkout, err := s.StartCheckout(c.UserContext(), CheckoutInput{
+		UserID:        req.UserID,
+		BaseAmount:    req.BaseAmount,
+		Availment:     req.Availment,
+		PaymentMethod: req.PaymentMethod,
+		ApartmentID:   req.ApartmentID,
+	})
+	if err != nil {
+		log.Printf("Checkout creation failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Payment source creation failed",
+			"details": err.Error(),
+		})
+	}
 
+	// source_id is the reference for /api/transaction/:source_id for every method
+	resp := fiber.Map{
+		"checkout_url":   checkout.CheckoutURL,
+		"source_id":      checkout.ID,
+		"payment_method": txn.PaymentMethod,
+		"status":         txn.Status,
+		"availment":      txn.Availment,
+	}
+	if checkout.Kind == payment.KindPaymentIntent {
+		// Card payments are completed client-side by attaching a payment method
+		resp["payment_intent_id"] = checkout.ID
+		resp["client_key"] = checkout.ClientKey
+	}
+	return c.JSON(resp)
 }
 
 // HandleWebhook processes PayMongo webhooks
 func (s *PayMongoService) HandleWebhook(c *fiber.Ctx) error {
 	// 1. Log raw request
-body := c.Body()
-    log.Printf("📩 Webhook received: headers=%v, body=%s", c.GetReqHeaders(), string(body))
-
-    signature := c.Get("Paymongo-Signature")
-    log.Printf("🔍 Paymongo-Signature: %s", signature)
-    if signature == "" {
-        log.Println("⚠️ Missing Paymongo-Signature header")
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing signature"})
-    }
+	body := c.Body()
+	log.Printf("📩 Webhook received: body=%s", string(body))
 
-log.Printf("✅ Bypassing signature validation for debugging")
+	signature := c.Get("Paymongo-Signature")
+	if signature == "" {
+		log.Println("⚠️ Missing Paymongo-Signature header")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing signature"})
+	}
 
-var webhook WebhookPayload
-    if err := c.BodyParser(&webhook); err != nil {
-        log.Printf("❌ Invalid webhook payload: %v", err)
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook"})
-    }
+	var webhook WebhookPayload
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		log.Printf("❌ Invalid webhook payload: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook"})
+	}
 
-if webhook.Data.Attributes.Type != "source.chargeable" {
-        log.Printf("ℹ️ Ignoring non-chargeable webhook event: %s", webhook.Data.Attributes.Type)
-        return c.SendStatus(fiber.StatusOK)
-    }
+	// 2. Verify the signature before trusting anything in the payload
+	if err := payment.VerifyWebhookSignature(s.Config.WebhookSecret, body, signature, webhook.Data.Attributes.Livemode, time.Now()); err != nil {
+		log.Printf("❌ Webhook signature rejected: %v", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid signature"})
+	}
 
-	// 5. Extract source ID and amount
-sourceID := webhook.Data.Attributes.Data.ID
-    amount := webhook.Data.Attributes.Data.Attributes.Amount
-    log.Printf("🔍 Processing webhook for source: %s, amount: %d", sourceID, amount)
+	handlers := map[string]func(*fiber.Ctx, WebhookPayload) error{
+		"source.chargeable": s.handleSourceChargeable,
+		"payment.paid":      s.handlePaymentPaid,
+		"payment.failed":    s.handlePaymentFailed,
 
-    var txn model.Transaction
-    if err := s.DB.Where("pay_mongo_source_id = ?", sourceID).First(&txn).Error; err != nil {
-        log.Printf("❌ Transaction not found for source %s: %v", sourceID, err)
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Transaction not found"})
-    }
+		"payment.refunded":       s.handleRefundEvent,
+		"payment.refund.updated": s.handleRefundEvent,
+	}
+	handler, ok := handlers[webhook.Data.Attributes.Type]
+	if !ok {
+		log.Printf("ℹ️ Ignoring unhandled webhook event: %s", webhook.Data.Attributes.Type)
+		return c.SendStatus(fiber.StatusOK)
+	}
 
-	// 7. Fetch source status from PayMongo API
-	sourceReq, err := http.NewRequest("GET", fmt.Sprintf("https://api.paymongo.com/v1/sources/%s", sourceID), nil)
-	if err != nil {
-		log.Printf("❌ Failed to create source check request: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal error"})
+	// 3. Claim the event ID; a duplicate delivery finds it already claimed
+	eventID := webhook.Data.ID
+	if eventID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing event ID"})
+	}
+	event := model.WebhookEvent{EventID: eventID, EventType: webhook.Data.Attributes.Type}
+	result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if result.Error != nil {
+		log.Printf("❌ Failed to record webhook event %s: %v", eventID, result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB error"})
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("ℹ️ Webhook event %s already processed, skipping", eventID)
+		return c.SendStatus(fiber.StatusOK)
 	}
-	sourceReq.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(s.SecretKey+":")))
 
-	resp, err := http.DefaultClient.Do(sourceReq)
-	if err != nil {
-		log.Printf("❌ Failed to call PayMongo API: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "PayMongo API error"})
+	// 4. Release the claim if processing fails so PayMongo's retry can run it
+	if err := handler(c, webhook); err != nil || c.Response().StatusCode() != fiber.StatusOK {
+		if delErr := s.DB.Where("event_id = ?", eventID).Delete(&model.WebhookEvent{}).Error; delErr != nil {
+			log.Printf("❌ Failed to release webhook event %s: %v", eventID, delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// handleSourceChargeable charges a chargeable source once and marks its
+// transaction as paid.
+func (s *PayMongoService) handleSourceChargeable(c *fiber.Ctx, webhook WebhookPayload) error {
+	// 5. Extract source ID and amount
+	sourceID := webhook.Data.Attributes.Data.ID
+	amount := webhook.Data.Attributes.Data.Attributes.Amount
+	log.Printf("🔍 Processing webhook for source: %s, amount: %d", sourceID, amount)
+
+	var txn model.Transaction
+	if err := s.DB.Where("pay_mongo_source_id = ?", sourceID).First(&txn).Error; err != nil {
+		log.Printf("❌ Transaction not found for source %s: %v", sourceID, err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Transaction not found"})
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	// 6. A transaction that is already paid must never be charged again
+	if isSettledStatus(txn.Status) {
+		log.Printf("ℹ️ Transaction for source %s already paid, skipping", sourceID)
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	// 7. Charge the source (or pick up the payment if it was already charged)
+	paymentID, err := s.Provider.Capture(c.UserContext(), sourceID, int64(amount))
 	if err != nil {
-		log.Printf("❌ Failed to read response body: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Read error"})
-	}
-	log.Printf("✅ Source response: status=%d, body=%s", resp.StatusCode, string(respBody))
-
-	var sourceResp SourceResponse
-	if err := json.Unmarshal(respBody, &sourceResp); err != nil {
-		log.Printf("❌ Failed to parse source response: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Parse error"})
-	}
-
-	// 8. Determine action based on source status
-	var paymentID string
-	switch sourceResp.Data.Attributes.Status {
-	case "paid":
-		log.Printf("✅ Source %s already paid, retrieving payment ID", sourceID)
-		paymentID, err = s.getPaymentIDForSource(sourceID)
-		if err != nil {
-			log.Printf("❌ Failed to get payment ID: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to retrieve payment",
-				"details": err.Error(),
-			})
-		}
-	case "chargeable":
-		log.Printf("💳 Source %s is chargeable, creating payment", sourceID)
-		paymentID, err = s.createPayment(sourceID, amount)
-		if err != nil {
-			log.Printf("❌ Failed to create payment: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Payment creation failed",
+		if errors.Is(err, payment.ErrNotChargeable) {
+			log.Printf("⚠️ %v", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid source status",
 				"details": err.Error(),
 			})
 		}
-	default:
-		log.Printf("⚠️ Source %s has invalid status: %s", sourceID, sourceResp.Data.Attributes.Status)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   "Invalid source status",
-			"details": sourceResp.Data.Attributes.Status,
+		log.Printf("❌ Failed to capture payment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Payment creation failed",
+			"details": err.Error(),
 		})
 	}
 
-	// 9. Update transaction in database
-	if err := s.DB.Model(&model.Transaction{}).
-		Where("pay_mongo_source_id = ?", sourceID).
-		Updates(map[string]interface{}{
-			"pay_mongo_payment_id": paymentID,
-			"status":               "paid",
-			"updated_at":           time.Now(),
-		}).Error; err != nil {
+	// 8. Update transaction in database
+	if err := s.markTransactionPaid(&txn, paymentID); err != nil {
 		log.Printf("❌ Failed to update transaction: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB update failed"})
 	}
@@ -289,108 +337,174 @@ sourceID := webhook.Data.Attributes.Data.ID
 	return c.SendStatus(fiber.StatusOK)
 }
 
-// Helper function to create a payment
-func (s *PayMongoService) createPayment(sourceID string, amount int) (string, error) {
-	paymentReq := map[string]interface{}{
-		"data": map[string]interface{}{
-			"attributes": map[string]interface{}{
-				"amount":      amount,
-				"source":      map[string]string{"id": sourceID, "type": "source"},
-				"currency":    "PHP",
-				"description": "Purchase with 0.2% interest",
-			},
-		},
-	}
+// handlePaymentPaid settles the transaction behind a payment.paid event. For
+// card and Maya this is the only signal; for sources it confirms the payment
+// handleSourceChargeable already created.
+func (s *PayMongoService) handlePaymentPaid(c *fiber.Ctx, webhook WebhookPayload) error {
+	paymentID := webhook.Data.Attributes.Data.ID
 
-	body, err := json.Marshal(paymentReq)
+	txn, found, err := s.findTransactionForPayment(webhook)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal payment request: %v", err)
+		log.Printf("❌ Failed to look up transaction for payment %s: %v", paymentID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB error"})
 	}
-
-	client := &http.Client{}
-	paymongoReq, err := http.NewRequest("POST", "https://api.paymongo.com/v1/payments", bytes.NewBuffer(body))
-	if err != nil {
-		return "", fmt.Errorf("failed to create PayMongo payment request: %v", err)
+	if !found {
+		log.Printf("ℹ️ No transaction for payment %s, ignoring", paymentID)
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if isSettledStatus(txn.Status) {
+		return c.SendStatus(fiber.StatusOK)
 	}
-	paymongoReq.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(s.SecretKey+":")))
-	paymongoReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(paymongoReq)
-	if err != nil {
-		return "", fmt.Errorf("PayMongo payment API error: %v", err)
+	if err := s.markTransactionPaid(txn, paymentID); err != nil {
+		log.Printf("❌ Failed to update transaction: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB update failed"})
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	log.Printf("✅ Transaction %d paid via %s: payment_id=%s", txn.ID, txn.PaymentMethod, paymentID)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// handlePaymentFailed records a failed card / e-wallet attempt. A transaction
+// that is already paid is left alone.
+func (s *PayMongoService) handlePaymentFailed(c *fiber.Ctx, webhook WebhookPayload) error {
+	paymentID := webhook.Data.Attributes.Data.ID
+
+	txn, found, err := s.findTransactionForPayment(webhook)
 	if err != nil {
-		return "", fmt.Errorf("failed to read PayMongo payment response: %v", err)
+		log.Printf("❌ Failed to look up transaction for payment %s: %v", paymentID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB error"})
 	}
-	log.Printf("PayMongo payment response: status=%d, body=%s", resp.StatusCode, string(respBody))
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("PayMongo payment creation failed: status=%d, body=%s", resp.StatusCode, string(respBody))
+	if !found || isSettledStatus(txn.Status) {
+		return c.SendStatus(fiber.StatusOK)
 	}
 
-	var paymentResp struct {
-		Data struct {
-			ID string `json:"id"`
-		} `json:"data"`
+	// A late payment.failed must not overwrite a payment that settled meanwhile
+	result := s.DB.Model(&model.Transaction{}).
+		Where("id = ? AND status IN ?", txn.ID, UnsettledStatuses).
+		Updates(map[string]interface{}{
+			"status":     "failed",
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		log.Printf("❌ Failed to update transaction: %v", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB update failed"})
 	}
-	if err := json.Unmarshal(respBody, &paymentResp); err != nil {
-		return "", fmt.Errorf("failed to parse payment response: %v", err)
+	if result.RowsAffected == 0 {
+		return c.SendStatus(fiber.StatusOK)
 	}
 
-	return paymentResp.Data.ID, nil
+	log.Printf("⚠️ Transaction %d payment failed: %s", txn.ID, webhook.Data.Attributes.Data.Attributes.FailedMessage)
+	return c.SendStatus(fiber.StatusOK)
 }
 
-// Helper function to retrieve payment ID for a paid source
-func (s *PayMongoService) getPaymentIDForSource(sourceID string) (string, error) {
-	// Query payments associated with the source
-	client := &http.Client{}
-	paymentsReq, err := http.NewRequest("GET", "https://api.paymongo.com/v1/payments?source_id="+sourceID, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create payments request: %v", err)
+// findTransactionForPayment resolves the transaction a payment.* event refers
+// to, through its payment intent or its source.
+func (s *PayMongoService) findTransactionForPayment(webhook WebhookPayload) (*model.Transaction, bool, error) {
+	attrs := webhook.Data.Attributes.Data.Attributes
+
+	query := s.DB.Model(&model.Transaction{})
+	switch {
+	case attrs.PaymentIntentID != "":
+		query = query.Where("pay_mongo_payment_intent_id = ?", attrs.PaymentIntentID)
+	case attrs.Source != nil && attrs.Source.ID != "":
+		query = query.Where("pay_mongo_source_id = ?", attrs.Source.ID)
+	default:
+		return nil, false, nil
 	}
-	paymentsReq.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(s.SecretKey+":")))
 
-	resp, err := client.Do(paymentsReq)
-	if err != nil {
-		return "", fmt.Errorf("failed to retrieve payments: %v", err)
+	var txn model.Transaction
+	if err := query.First(&txn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
 	}
-	defer resp.Body.Close()
+	return &txn, true, nil
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read payments response: %v", err)
-	}
-	log.Printf("Payments response: status=%d, body=%s", resp.StatusCode, string(respBody))
+// markTransactionPaid stores the captured payment ID, flips the transaction
+// to paid and runs the registered paid hooks, all in one DB transaction.
+// The update only matches an unsettled row, so when two deliveries race only
+// the first one runs the hooks; the other is a no-op.
+func (s *PayMongoService) markTransactionPaid(txn *model.Transaction, paymentID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Transaction{}).
+			Where("id = ? AND status NOT IN ?", txn.ID, settledStatuses).
+			Updates(map[string]interface{}{
+				"pay_mongo_payment_id": paymentID,
+				"status":               "paid",
+				"updated_at":           time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			log.Printf("ℹ️ Transaction %d was already settled; skipping paid hooks", txn.ID)
+			return nil
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to retrieve payments: status=%d, body=%s", resp.StatusCode, string(respBody))
+		txn.PayMongoPaymentID = paymentID
+		txn.Status = "paid"
+		for _, hook := range paidHooks {
+			if err := hook(tx, txn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HandleFakeCheckout stands in for the provider's checkout page when
+// PAYMENT_PROVIDER=fake. It authorizes (or with ?outcome=failed, declines)
+// the checkout, delivers the signed webhook PayMongo would send to this API
+// and then redirects like PayMongo would.
+func (s *PayMongoService) HandleFakeCheckout(c *fiber.Ctx) error {
+	fake, ok := s.Provider.(*payment.FakeProvider)
+	if !ok {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	sourceID := c.Params("id")
+	failed := c.Query("outcome") == "failed"
+	redirectURL := s.Config.SuccessURL
+	if failed {
+		redirectURL = s.Config.FailedURL
+		if err := fake.Decline(sourceID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	} else if err := fake.Authorize(sourceID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	var paymentsResp struct {
-		Data []struct {
-			ID         string `json:"id"`
-			Attributes struct {
-				Source struct {
-					ID string `json:"id"`
-				} `json:"source"`
-				Status string `json:"status"`
-			} `json:"attributes"`
-		} `json:"data"`
+	body, err := fake.Event(sourceID)
+	if err != nil {
+		// Failed sources produce no event in PayMongo either; they just expire
+		if failed {
+			s.DB.Model(&model.Transaction{}).
+				Where("pay_mongo_source_id = ? AND status IN ?", sourceID, UnsettledStatuses).
+				Update("status", "failed")
+			return c.Redirect(redirectURL + "?id=" + url.QueryEscape(sourceID))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
-	if err := json.Unmarshal(respBody, &paymentsResp); err != nil {
-		return "", fmt.Errorf("failed to parse payments response: %v", err)
+
+	req, err := http.NewRequest(http.MethodPost, s.Config.CallbackBaseURL+"/api/webhook", bytes.NewReader(body))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Paymongo-Signature", payment.SignWebhook(s.Config.WebhookSecret, body, false, time.Now()))
 
-	for _, payment := range paymentsResp.Data {
-		if payment.Attributes.Source.ID == sourceID && payment.Attributes.Status == "paid" {
-			return payment.ID, nil
-		}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("❌ Fake webhook delivery failed: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Webhook delivery failed"})
 	}
+	resp.Body.Close()
+	log.Printf("Fake webhook delivered for %s: status=%d", sourceID, resp.StatusCode)
 
-	return "", fmt.Errorf("no paid payment found for source %s", sourceID)
+	return c.Redirect(redirectURL + "?id=" + url.QueryEscape(sourceID))
 }
 
 func (s *PayMongoService) GetTransaction(c *fiber.Ctx) error {
@@ -400,31 +514,56 @@ func (s *PayMongoService) GetTransaction(c *fiber.Ctx) error {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Source ID required"})
     }
 
+    // Card / Maya transactions are referenced by their payment intent ID
     var txn model.Transaction
-    if err := s.DB.Where("pay_mongo_source_id = ?", sourceID).First(&txn).Error; err != nil {
+    if err := s.DB.Where("pay_mongo_source_id = ? OR pay_mongo_payment_intent_id = ?", sourceID, sourceID).First(&txn).Error; err != nil {
         log.Printf("Transaction not found for source %s: %v", sourceID, err)
         return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Transaction not found"})
     }
 
+    // Only the payer and admins may see a transaction
+    userClaims, _ := c.Locals("user").(jwt.MapClaims)
+    uid, _ := userClaims["uid"].(string)
+    role, _ := userClaims["role"].(string)
+    if txn.UserID != uid && role != "Admin" {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Transaction not found"})
+    }
+
     return c.JSON(fiber.Map{
-        "user_id":             txn.UserID,
-        "base_amount":         txn.BaseAmount,
-        "interest_amount":     txn.InterestAmount,
-        "total_amount":        txn.TotalAmount,
-        "pay_mongo_source_id": txn.PayMongoSourceID,
-        "pay_mongo_payment_id": txn.PayMongoPaymentID,
-        "status":              txn.Status,
-        "created_at":          txn.CreatedAt.Format(time.RFC3339),
-        "updated_at":          txn.UpdatedAt.Format(time.RFC3339),
+        "user_id":                     txn.UserID,
+        "base_amount":                 txn.BaseAmount,
+        "interest_amount":             txn.InterestAmount,
+        "total_amount":                txn.TotalAmount,
+        "payment_method":              txn.PaymentMethod,
+        "pay_mongo_source_id":         txn.PayMongoSourceID,
+        "pay_mongo_payment_intent_id": txn.PayMongoPaymentIntentID,
+        "pay_mongo_payment_id":        txn.PayMongoPaymentID,
+        "refunded_amount":             txn.RefundedAmount,
+        "net_amount":                  txn.TotalAmount - txn.RefundedAmount,
+        "status":                      txn.Status,
+        "created_at":                  txn.CreatedAt.Format(time.RFC3339),
+        "updated_at":                  txn.UpdatedAt.Format(time.RFC3339),
     })
 }
-// Add to PayMongoService methods
+// GetTransactions lists the caller's transactions. Only admins may pass
+// ?user_id= for someone else.
 func (s *PayMongoService) GetTransactions(c *fiber.Ctx) error {
-	userID := c.Query("user_id")
+	userClaims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	uid, _ := userClaims["uid"].(string)
+	role, _ := userClaims["role"].(string)
+
+	userID := c.Query("user_id", uid)
 	if userID == "" {
 		log.Printf("Missing user_id parameter")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User ID required"})
 	}
+	if userID != uid && role != "Admin" {
+		log.Printf("User %s tried to read transactions of %s", uid, userID)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden"})
+	}
 
 	var transactions []model.Transaction
 	if err := s.DB.Where("user_id = ?", userID).Find(&transactions).Error; err != nil {
@@ -460,8 +599,8 @@ func (s *PayMongoService) HandleSuccessRedirect(c *fiber.Ctx) error {
     }
     
     // Use the app scheme for deep linking
-    appRedirectURL := fmt.Sprintf("rentxpert://paymentsuccess?id=%s", url.QueryEscape(sourceID))
-    webRedirectURL := fmt.Sprintf("https://bd1a-103-72-190-240.ngrok-free.app/success?id=%s", url.QueryEscape(sourceID))
+    appRedirectURL := fmt.Sprintf("%s://paymentsuccess?id=%s", s.Config.AppScheme, url.QueryEscape(sourceID))
+    webRedirectURL := fmt.Sprintf("%s?id=%s", s.Config.WebSuccessURL, url.QueryEscape(sourceID))
     
     log.Printf("Redirecting to app: %s or web: %s", appRedirectURL, webRedirectURL)
     
@@ -506,8 +645,8 @@ func (s *PayMongoService) HandleFailedRedirect(c *fiber.Ctx) error {
     }
     
     // Use the same pattern as success redirect
-    appRedirectURL := fmt.Sprintf("rentxpert://paymentfailed?id=%s", url.QueryEscape(sourceID))
-    webRedirectURL := fmt.Sprintf("https://bd1a-103-72-190-240.ngrok-free.app/failed?id=%s", url.QueryEscape(sourceID))
+    appRedirectURL := fmt.Sprintf("%s://paymentfailed?id=%s", s.Config.AppScheme, url.QueryEscape(sourceID))
+    webRedirectURL := fmt.Sprintf("%s?id=%s", s.Config.WebFailedURL, url.QueryEscape(sourceID))
     
     log.Printf("Redirecting to app: %s or web: %s", appRedirectURL, webRedirectURL)
     
@@ -535,11 +674,28 @@ func (s *PayMongoService) HandleFailedRedirect(c *fiber.Ctx) error {
 
 
 func (s *PayMongoService) GetAllTransactions(c *fiber.Ctx) error {
+    page, err := pagination.Parse(c, pagination.Sorts{
+        Fields: map[string]pagination.Field{
+            "created_at": {Column: "transactions.created_at", Type: pagination.TypeTime, Desc: true},
+            "amount":     {Column: "transactions.total_amount", Type: pagination.TypeNumber, Desc: true},
+        },
+        Default: "created_at",
+        ID:      pagination.Field{Column: "transactions.id", Type: pagination.TypeBigint},
+    })
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+    }
+
     // Initialize slice to hold transactions
-    var transactions []model.Transaction
+    type transactionRow struct {
+        model.Transaction
+        pagination.Key
+    }
+    var transactions []transactionRow
     
-    // Query all transactions ordered by creation date (newest first)
-    if err := s.DB.Order("created_at DESC").Find(&transactions).Error; err != nil {
+    // Query one page of transactions, newest first unless ?sort= says otherwise
+    query := s.DB.Model(&model.Transaction{})
+    if err := page.Apply(page.Select(query, "transactions.*")).Find(&transactions).Error; err != nil {
         // Enhanced error logging
         log.Printf("Database error fetching transactions: %v\nStack: %s", 
             err, 
@@ -551,8 +707,26 @@ func (s *PayMongoService) GetAllTransactions(c *fiber.Ctx) error {
         })
     }
 
-    // Log successful query
-    log.Printf("Successfully retrieved %d transactions", len(transactions))
+    transactions, meta := pagination.Finish(page, transactions, func(r transactionRow) pagination.Key { return r.Key })
+
+    // Totals cover every transaction, not just this page
+    var totals struct {
+        TotalCount    int64
+        TotalRefunded float64
+        TotalNet      float64
+    }
+    if err := s.DB.Model(&model.Transaction{}).
+        Select("COUNT(*) AS total_count, "+
+            "COALESCE(SUM(refunded_amount) FILTER (WHERE status IN ?), 0) AS total_refunded, "+
+            "COALESCE(SUM(total_amount - refunded_amount) FILTER (WHERE status IN ?), 0) AS total_net",
+            settledStatuses, settledStatuses).
+        Scan(&totals).Error; err != nil {
+        log.Printf("Database error totalling transactions: %v", err)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Could not retrieve transactions",
+            "details": "Database operation failed",
+        })
+    }
     
     // Format the response data
     type TransactionResponse struct {
@@ -561,6 +735,9 @@ func (s *PayMongoService) GetAllTransactions(c *fiber.Ctx) error {
         BaseAmount        float64   `json:"base_amount"`
         InterestAmount    float64   `json:"interest_amount"`
         TotalAmount       float64   `json:"total_amount"`
+        RefundedAmount    float64   `json:"refunded_amount"`
+        NetAmount         float64   `json:"net_amount"` // total_amount minus succeeded refunds
+        PaymentMethod     string    `json:"payment_method"`
         Status            string    `json:"status"`
         PayMongoSourceID  string    `json:"paymongo_source_id"`
         PayMongoPaymentID string    `json:"paymongo_payment_id,omitempty"`
@@ -569,14 +746,17 @@ func (s *PayMongoService) GetAllTransactions(c *fiber.Ctx) error {
     }
 
     // Convert to response format
-    var response []TransactionResponse
+    response := make([]TransactionResponse, 0, len(transactions))
     for _, txn := range transactions {
         response = append(response, TransactionResponse{
-
+            ID:                fmt.Sprint(txn.ID), // needed by the admin refund endpoint
             UserID:            txn.UserID,
             BaseAmount:        txn.BaseAmount,
             InterestAmount:    txn.InterestAmount,
             TotalAmount:       txn.TotalAmount,
+            RefundedAmount:    txn.RefundedAmount,
+            NetAmount:         txn.TotalAmount - txn.RefundedAmount,
+            PaymentMethod:     txn.PaymentMethod,
             Status:            txn.Status,
             PayMongoSourceID:  txn.PayMongoSourceID,
             PayMongoPaymentID: txn.PayMongoPaymentID,
@@ -589,30 +769,12 @@ func (s *PayMongoService) GetAllTransactions(c *fiber.Ctx) error {
     return c.JSON(fiber.Map{
         "success": true,
         "data":    response,
+        "pagination": meta,
         "meta": fiber.Map{
-            "total_count": len(response),
-            "timestamp":   time.Now().UTC(),
+            "total_count":    totals.TotalCount,
+            "total_refunded": totals.TotalRefunded,
+            "total_net":      totals.TotalNet, // collected minus refunds, paid transactions only
+            "timestamp":      time.Now().UTC(),
         },
     })
 }
-// func isValidSignature(secret, payload, receivedSignature string) bool {
-//     log.Printf("🔍 Validating signature: secret=%s, payload=%s, received=%s", secret, payload, receivedSignature)
-//     parts := strings.Split(receivedSignature, ",")
-//     var timestamp, signature string
-//     for _, part := range parts {
-//         if strings.HasPrefix(part, "t=") {
-//             timestamp = strings.TrimPrefix(part, "t=")
-//         } else if strings.HasPrefix(part, "te=") {
-//             signature = strings.TrimPrefix(part, "te=")
-//         }
-//     }
-//     if timestamp == "" || signature == "" {
-//         log.Printf("❌ Invalid signature format: %s", receivedSignature)
-//         return false
-//     }
-//     mac := hmac.New(sha256.New, []byte(secret))
-//     mac.Write([]byte(timestamp + "." + payload))
-//     expected := fmt.Sprintf("%x", mac.Sum(nil)) // Remove sha256= prefix
-//     log.Printf("🔍 Computed signature: %s, received signature: %s", expected, signature)
-//     return hmac.Equal([]byte(expected), []byte(signature))
-// }
\ No newline at end of file
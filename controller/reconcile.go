@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UnsettledStatuses are the statuses a transaction can be stuck in while
+// waiting for a webhook.
+var UnsettledStatuses = []string{"pending", "awaiting_next_action", "processing"}
+
+// envMinutes reads a duration in minutes from the environment.
+func envMinutes(key string, fallback int) time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv(key)); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return time.Duration(fallback) * time.Minute
+}
+
+// reconcileStaleAfter is how old an unsettled transaction must be before the
+// reconciler asks the provider about it; reconcileExpireAfter is when one the
+// customer never completed is given up on.
+func reconcileStaleAfter() time.Duration  { return envMinutes("RECONCILE_STALE_MINUTES", 15) }
+func reconcileExpireAfter() time.Duration { return envMinutes("RECONCILE_EXPIRE_MINUTES", 24*60) }
+
+// reconcileMaxErrors is how many times the reconciler retries a transaction
+// that keeps failing before flagging it for an admin.
+func reconcileMaxErrors() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("RECONCILE_MAX_ERRORS"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+// ReconcilePending polls the provider for every stale unsettled transaction
+// and settles it the way the missed webhook would have. Each transaction it
+// resolves or flags gets a ReconciliationLog entry.
+func (s *PayMongoService) ReconcilePending(ctx context.Context, now time.Time) (int, error) {
+	var txns []model.Transaction
+	// Flagged transactions wait for an admin instead of being flagged every cycle
+	if err := s.DB.Where("status IN ? AND created_at < ?", UnsettledStatuses, now.Add(-reconcileStaleAfter())).
+		Where("NOT EXISTS (SELECT 1 FROM reconciliation_logs WHERE reconciliation_logs.transaction_id = transactions.id AND reconciliation_logs.resolution = ?)", "flagged").
+		Order("created_at ASC").
+		Limit(100).
+		Find(&txns).Error; err != nil {
+		return 0, fmt.Errorf("failed to load pending transactions: %v", err)
+	}
+
+	touched := 0
+	for i := range txns {
+		entry := s.reconcileTransaction(ctx, &txns[i], now)
+		if entry == nil {
+			continue
+		}
+		if entry.Resolution == "error" {
+			s.flagAfterRepeatedErrors(entry)
+		}
+		if err := s.DB.Create(entry).Error; err != nil {
+			log.Printf("❌ Failed to record reconciliation of transaction %d: %v", txns[i].ID, err)
+		}
+		touched++
+	}
+	return touched, nil
+}
+
+// flagAfterRepeatedErrors turns an error entry into a flagged one once the
+// transaction has failed reconcileMaxErrors times, so the reconciler stops
+// retrying it every cycle and an admin looks at it instead.
+func (s *PayMongoService) flagAfterRepeatedErrors(entry *model.ReconciliationLog) {
+	var previous int64
+	if err := s.DB.Model(&model.ReconciliationLog{}).
+		Where("transaction_id = ? AND resolution = ?", entry.TransactionID, "error").
+		Count(&previous).Error; err != nil {
+		log.Printf("❌ Failed to count reconciliation errors of transaction %d: %v", entry.TransactionID, err)
+		return
+	}
+	if maxErrors := reconcileMaxErrors(); previous+1 >= maxErrors {
+		entry.Resolution = "flagged"
+		entry.Discrepancy = fmt.Sprintf("gave up after %d errors, last: %s", maxErrors, entry.Discrepancy)
+		log.Printf("⚠️ Reconciler flagged transaction %d after %d errors", entry.TransactionID, maxErrors)
+	}
+}
+
+// reconcileTransaction resolves one transaction. It returns nil when the
+// checkout is legitimately still in progress and nothing was changed.
+func (s *PayMongoService) reconcileTransaction(ctx context.Context, txn *model.Transaction, now time.Time) *model.ReconciliationLog {
+	checkoutID := txn.PayMongoSourceID
+	if txn.PayMongoPaymentIntentID != "" {
+		checkoutID = txn.PayMongoPaymentIntentID
+	}
+	entry := &model.ReconciliationLog{
+		TransactionID: txn.ID,
+		CheckoutID:    checkoutID,
+		LocalStatus:   txn.Status,
+	}
+	if checkoutID == "" {
+		entry.Resolution = "flagged"
+		entry.Discrepancy = "transaction has no source or payment intent ID"
+		return entry
+	}
+
+	status, err := s.Provider.FetchStatus(ctx, checkoutID)
+	if err != nil {
+		log.Printf("❌ Reconciler could not fetch %s for transaction %d: %v", checkoutID, txn.ID, err)
+		entry.Resolution = "error"
+		entry.Discrepancy = err.Error()
+		return entry
+	}
+	entry.ProviderStatus = status.Status
+
+	expected := payment.ToCentavos(txn.TotalAmount)
+	if status.Amount != 0 && status.Amount != expected {
+		entry.Resolution = "flagged"
+		entry.Discrepancy = fmt.Sprintf("amount mismatch: local %d, provider %d centavos", expected, status.Amount)
+		return entry
+	}
+
+	// A webhook may have settled it while we were asking
+	if err := s.DB.First(txn, txn.ID).Error; err == nil && isSettledStatus(txn.Status) {
+		return nil
+	}
+
+	switch status.Status {
+	case "chargeable", "paid":
+		// Capture charges a chargeable source, or returns the existing payment
+		paymentID, err := s.Provider.Capture(ctx, checkoutID, status.Amount)
+		if err != nil {
+			entry.Resolution = "error"
+			entry.Discrepancy = err.Error()
+			return entry
+		}
+		if err := s.markTransactionPaid(txn, paymentID); err != nil {
+			entry.Resolution = "error"
+			entry.Discrepancy = fmt.Sprintf("provider reports %s but the update failed: %v", status.Status, err)
+			return entry
+		}
+		entry.Resolution = "paid"
+		log.Printf("✅ Reconciler settled transaction %d (%s was %s)", txn.ID, checkoutID, status.Status)
+		return entry
+
+	case "failed", "cancelled", "expired":
+		resolution := "failed"
+		if status.Status == "expired" {
+			resolution = "expired"
+		}
+		if err := s.setTransactionStatus(txn, resolution); err != nil {
+			entry.Resolution = "error"
+			entry.Discrepancy = err.Error()
+			return entry
+		}
+		entry.Resolution = resolution
+		return entry
+	}
+
+	// Still pending at the provider: give up once the customer clearly abandoned it
+	if now.Sub(txn.CreatedAt) < reconcileExpireAfter() {
+		return nil
+	}
+	if err := s.setTransactionStatus(txn, "expired"); err != nil {
+		entry.Resolution = "error"
+		entry.Discrepancy = err.Error()
+		return entry
+	}
+	entry.Resolution = "expired"
+	return entry
+}
+
+// setTransactionStatus moves an unsettled transaction to a final status,
+// unless a webhook settled it in the meantime.
+func (s *PayMongoService) setTransactionStatus(txn *model.Transaction, status string) error {
+	return s.DB.Model(&model.Transaction{}).
+		Where("id = ? AND status IN ?", txn.ID, UnsettledStatuses).
+		Updates(map[string]interface{}{"status": status, "updated_at": time.Now()}).Error
+}
+
+// RunReconciler periodically reconciles stale pending transactions in the
+// background.
+func (s *PayMongoService) RunReconciler() {
+	ticker := time.NewTicker(envMinutes("RECONCILE_INTERVAL_MINUTES", 10))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		currentTime := time.Now()
+		touched, err := s.ReconcilePending(context.Background(), currentTime)
+		if err != nil {
+			fmt.Printf("[%s] Error reconciling transactions: %v\n", currentTime.Format(time.RFC3339), err)
+		} else if touched > 0 {
+			fmt.Printf("[%s] Reconciled %d pending transactions\n", currentTime.Format(time.RFC3339), touched)
+		}
+	}
+}
+
+// ReconcileNow runs the reconciler immediately on an admin's request.
+func (s *PayMongoService) ReconcileNow(c *fiber.Ctx) error {
+	touched, err := s.ReconcilePending(c.UserContext(), time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Reconciliation complete", "reconciled": touched})
+}
+
+// GetReconciliationLogs lists what the reconciler did, newest first. Filter
+// with ?resolution=, ?transaction_id= or ?discrepancies=true.
+func (s *PayMongoService) GetReconciliationLogs(c *fiber.Ctx) error {
+	query := s.DB.Order("created_at DESC")
+	if resolution := c.Query("resolution"); resolution != "" {
+		query = query.Where("resolution = ?", resolution)
+	}
+	if transactionID := c.Query("transaction_id"); transactionID != "" {
+		query = query.Where("transaction_id = ?", transactionID)
+	}
+	if c.QueryBool("discrepancies") {
+		query = query.Where("discrepancy <> ''")
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	var logs []model.ReconciliationLog
+	if err := query.Limit(limit).Find(&logs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(fiber.Map{"logs": logs})
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+	"github.com/Conding-Student/backend/testdb"
+)
+
+// unreachableProvider fails every status lookup, like a provider outage.
+type unreachableProvider struct {
+	payment.PaymentProvider
+	calls int
+}
+
+func (p *unreachableProvider) FetchStatus(ctx context.Context, checkoutID string) (*payment.CheckoutStatus, error) {
+	p.calls++
+	return nil, errors.New("connection refused")
+}
+
+func TestReconcilePendingFlagsAfterRepeatedErrors(t *testing.T) {
+	db := testdb.Open(t)
+	t.Setenv("RECONCILE_MAX_ERRORS", "3")
+
+	txn := model.Transaction{
+		UserID: "tenant-1", BaseAmount: 100, TotalAmount: 100, PayMongoSourceID: "src_unreachable",
+		PaymentMethod: payment.MethodGCash, Status: "pending", Availment: "Deposit",
+	}
+	if err := db.Create(&txn).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &unreachableProvider{}
+	svc := &PayMongoService{DB: db, Provider: provider}
+	now := time.Now().Add(time.Hour)
+	for range 5 {
+		if _, err := svc.ReconcilePending(context.Background(), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if provider.calls != 3 {
+		t.Errorf("provider asked %d times, want 3", provider.calls)
+	}
+	var resolutions []string
+	db.Model(&model.ReconciliationLog{}).Where("transaction_id = ?", txn.ID).Order("id").Pluck("resolution", &resolutions)
+	if want := []string{"error", "error", "flagged"}; !slices.Equal(resolutions, want) {
+		t.Errorf("got resolutions %v, want %v", resolutions, want)
+	}
+}
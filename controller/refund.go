@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Conding-Student/backend/ledger"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Refund reasons PayMongo accepts.
+var refundReasons = map[string]bool{
+	"duplicate":             true,
+	"fraudulent":            true,
+	"requested_by_customer": true,
+	"others":                true,
+}
+
+// settledStatuses are the statuses of a transaction that has been paid.
+var settledStatuses = []string{"paid", "refund_pending", "partially_refunded", "refunded"}
+
+// isSettledStatus reports whether a transaction has already been paid, so a
+// late or replayed payment event must not touch it again.
+func isSettledStatus(status string) bool {
+	return slices.Contains(settledStatuses, status)
+}
+
+// RefundedHook runs in the same database transaction that marks a
+// Transaction fully refunded. Returning an error rolls the update back.
+type RefundedHook func(tx *gorm.DB, txn *model.Transaction) error
+
+var refundedHooks []RefundedHook
+
+// RegisterRefundedHook lets other subsystems (promotions) undo what a payment
+// bought once it has been refunded in full.
+func RegisterRefundedHook(hook RefundedHook) {
+	refundedHooks = append(refundedHooks, hook)
+}
+
+// RefundTransaction lets an admin refund all or part of a paid transaction.
+// Omitting amount refunds whatever has not been refunded yet.
+func (s *PayMongoService) RefundTransaction(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid transaction ID"})
+	}
+
+	var req struct {
+		Amount float64 `json:"amount"`
+		Reason string  `json:"reason"`
+		Notes  string  `json:"notes"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if req.Reason == "" {
+		req.Reason = "requested_by_customer"
+	}
+	if !refundReasons[req.Reason] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid reason",
+			"allowed": []string{"duplicate", "fraudulent", "requested_by_customer", "others"},
+		})
+	}
+	if req.Amount < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Amount must be positive"})
+	}
+
+	adminUID := ""
+	if claims, ok := c.Locals("user").(jwt.MapClaims); ok {
+		adminUID, _ = claims["uid"].(string)
+	}
+
+	var txn model.Transaction
+	if err := s.DB.First(&txn, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Transaction not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	switch txn.Status {
+	case "paid", "partially_refunded":
+	case "refund_pending":
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A refund for this transaction is still pending"})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Transaction with status %q cannot be refunded", txn.Status),
+		})
+	}
+	if txn.PayMongoPaymentID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Transaction has no captured payment"})
+	}
+
+	refundable := math.Round((txn.TotalAmount-txn.RefundedAmount)*100) / 100
+	if req.Amount == 0 {
+		req.Amount = refundable
+	}
+	if req.Amount <= 0 || req.Amount > refundable {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":      "Refund amount exceeds refundable balance",
+			"refundable": refundable,
+		})
+	}
+
+	// Move to refund_pending first so a concurrent request cannot refund the same balance
+	result := s.DB.Model(&model.Transaction{}).
+		Where("id = ? AND status = ?", txn.ID, txn.Status).
+		Updates(map[string]interface{}{"status": "refund_pending", "updated_at": time.Now()})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Transaction changed, please retry"})
+	}
+
+	// Record the refund before calling the provider so money sent back is
+	// never without a row, even if saving the provider's answer fails
+	refund := model.Refund{
+		TransactionID: txn.ID,
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		Notes:         req.Notes,
+		Status:        "requested",
+		RequestedBy:   adminUID,
+	}
+	if err := s.DB.Create(&refund).Error; err != nil {
+		log.Printf("❌ Failed to save refund for transaction %d: %v", txn.ID, err)
+		s.DB.Model(&model.Transaction{}).Where("id = ?", txn.ID).Update("status", txn.Status)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	providerRefund, err := s.Provider.Refund(c.UserContext(), txn.PayMongoPaymentID, payment.ToCentavos(req.Amount), req.Reason)
+	if err != nil {
+		log.Printf("❌ Refund failed for transaction %d: %v", txn.ID, err)
+		s.DB.Model(&refund).Update("status", "failed")
+		s.DB.Model(&model.Transaction{}).Where("id = ?", txn.ID).Update("status", txn.Status)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":   "Refund request failed",
+			"details": err.Error(),
+		})
+	}
+
+	if err := s.DB.Model(&refund).Updates(map[string]interface{}{
+		"pay_mongo_refund_id": providerRefund.ID,
+		"status":              providerRefund.Status,
+	}).Error; err != nil {
+		// The row stays requested, which keeps the transaction refund_pending
+		// until the provider's refund is matched to it by hand
+		log.Printf("❌ Refund %s (%s) issued for transaction %d but not saved on refund %d: %v",
+			providerRefund.ID, providerRefund.Status, txn.ID, refund.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":              "Refund issued but not saved",
+			"paymongo_refund_id": providerRefund.ID,
+		})
+	}
+	refund.PayMongoRefundID = &providerRefund.ID
+	refund.Status = providerRefund.Status
+
+	// Some refunds settle synchronously; otherwise the refund webhook finishes it
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.syncTransactionRefunds(tx, txn.ID)
+	}); err != nil {
+		log.Printf("❌ Failed to update transaction %d after refund: %v", txn.ID, err)
+	}
+	s.DB.First(&txn, txn.ID)
+
+	log.Printf("✅ Refund %s of %.2f issued for transaction %d (%s)", providerRefund.ID, refund.Amount, txn.ID, providerRefund.Status)
+	return c.JSON(fiber.Map{
+		"message":            "Refund requested",
+		"refund":             refund,
+		"transaction_status": txn.Status,
+		"refunded_amount":    txn.RefundedAmount,
+		"net_amount":         txn.TotalAmount - txn.RefundedAmount,
+	})
+}
+
+// GetTransactionRefunds lists the refunds issued against a transaction.
+func (s *PayMongoService) GetTransactionRefunds(c *fiber.Ctx) error {
+	var refunds []model.Refund
+	if err := s.DB.Where("transaction_id = ?", c.Params("id")).Order("created_at DESC").Find(&refunds).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(fiber.Map{"refunds": refunds})
+}
+
+// handleRefundEvent applies payment.refund.updated (a single refund) and
+// payment.refunded (a payment listing its refunds) events.
+func (s *PayMongoService) handleRefundEvent(c *fiber.Ctx, webhook WebhookPayload) error {
+	resource := webhook.Data.Attributes.Data
+
+	type refundUpdate struct{ id, status string }
+	var updates []refundUpdate
+	if webhook.Data.Attributes.Type == "payment.refund.updated" {
+		updates = append(updates, refundUpdate{resource.ID, resource.Attributes.Status})
+	} else {
+		for _, r := range resource.Attributes.Refunds {
+			updates = append(updates, refundUpdate{r.ID, r.Attributes.Status})
+		}
+	}
+
+	for _, u := range updates {
+		var refund model.Refund
+		if err := s.DB.Where("pay_mongo_refund_id = ?", u.id).First(&refund).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("ℹ️ Refund %s not issued through this API, ignoring", u.id)
+				continue
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB error"})
+		}
+
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&refund).Update("status", u.status).Error; err != nil {
+				return err
+			}
+			return s.syncTransactionRefunds(tx, refund.TransactionID)
+		})
+		if err != nil {
+			log.Printf("❌ Failed to apply refund %s: %v", u.id, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB update failed"})
+		}
+		log.Printf("✅ Refund %s is now %s", u.id, u.status)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// syncTransactionRefunds recomputes refunded_amount and the refund status of
+// a transaction from its refund rows.
+func (s *PayMongoService) syncTransactionRefunds(db *gorm.DB, transactionID uint) error {
+	var txn model.Transaction
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&txn, transactionID).Error; err != nil {
+		return err
+	}
+
+	var totals struct {
+		Succeeded float64
+		Pending   int64
+	}
+	if err := db.Model(&model.Refund{}).
+		Select("COALESCE(SUM(CASE WHEN status = 'succeeded' THEN amount ELSE 0 END), 0) AS succeeded, "+
+			"COUNT(*) FILTER (WHERE status IN ('requested', 'pending')) AS pending").
+		Where("transaction_id = ?", transactionID).
+		Scan(&totals).Error; err != nil {
+		return err
+	}
+
+	// Post every succeeded refund to the ledger; already posted ones are skipped
+	var succeeded []model.Refund
+	if err := db.Where("transaction_id = ? AND status = ?", transactionID, "succeeded").Find(&succeeded).Error; err != nil {
+		return err
+	}
+	for i := range succeeded {
+		if err := ledger.PostRefund(db, &txn, &succeeded[i]); err != nil {
+			return err
+		}
+	}
+
+	status := "paid"
+	switch {
+	case totals.Pending > 0:
+		status = "refund_pending"
+	case totals.Succeeded >= txn.TotalAmount:
+		status = "refunded"
+	case totals.Succeeded > 0:
+		status = "partially_refunded"
+	}
+
+	previousStatus := txn.Status
+	if err := db.Model(&txn).Updates(map[string]interface{}{
+		"refunded_amount": totals.Succeeded,
+		"status":          status,
+		"updated_at":      time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+
+	if status != "refunded" || previousStatus == "refunded" {
+		return nil
+	}
+	txn.RefundedAmount = totals.Succeeded
+	txn.Status = status
+	for _, hook := range refundedHooks {
+		if err := hook(db, &txn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
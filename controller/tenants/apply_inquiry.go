@@ -4,6 +4,7 @@ package controller
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Conding-Student/backend/middleware"
@@ -17,6 +18,8 @@ type CreateInquiryRequest struct {
 	PropertyID     uint   `json:"property_id" validate:"required"`
 	Message        string `json:"message" validate:"required,min=10"`
 	PreferredVisit string `json:"preferred_visit,omitempty"` // Optional ISO8601
+	UnitID         *uint  `json:"unit_id,omitempty"`         // Optional room or bedspace
+	TenantGender   string `json:"tenant_gender,omitempty"`   // Required for a Male or Female only unit
 }
 
 func CreateInquiry(c *fiber.Ctx) error {
@@ -37,6 +40,40 @@ func CreateInquiry(c *fiber.Ctx) error {
 		})
 	}
 
+	// The stated gender is only kept for a unit that asks for it
+	stated := strings.TrimSpace(req.TenantGender)
+	req.TenantGender = ""
+
+	// The unit, if any, must belong to the property and still be free
+	if req.UnitID != nil {
+		var unit model.ApartmentUnit
+		if err := middleware.DBConn.First(&unit, "id = ? AND apartment_id = ?", *req.UnitID, req.PropertyID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unit not found in this property",
+			})
+		}
+		if unit.Availability != "Available" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Unit is not available",
+			})
+		}
+
+		// A Male or Female only unit needs a tenant of that gender
+		if restrictsGender(unit.AllowedGender) {
+			if stated == "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "tenant_gender is required for this " + unit.AllowedGender + " only unit",
+				})
+			}
+			if !strings.EqualFold(stated, unit.AllowedGender) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "This unit is for " + unit.AllowedGender + " tenants only",
+				})
+			}
+			req.TenantGender = unit.AllowedGender
+		}
+	}
+
 	// 3. Duplicate Check
 	if exists, err := checkDuplicateInquiry(tenantUID, req.PropertyID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -60,11 +97,18 @@ func CreateInquiry(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"id":          inquiry.ID,
 			"property_id": inquiry.PropertyID,
+			"unit_id":     inquiry.UnitID,
 			"expires_at":  inquiry.ExpiresAt.Format(time.RFC3339),
 		},
 	})
 }
 
+// restrictsGender reports whether a gender policy admits only one gender;
+// other policies such as Any or Mixed admit everyone.
+func restrictsGender(policy string) bool {
+	return strings.EqualFold(policy, "Male") || strings.EqualFold(policy, "Female")
+}
+
 // Function to extract the UID from the JWT token
 func GetUIDFromToken(c *fiber.Ctx) (string, error) {
 	userClaims, ok := c.Locals("user").(jwt.MapClaims)
@@ -106,6 +150,8 @@ func createInquiryRecord(tenantUID string, req CreateInquiryRequest) (*model.Inq
 	inquiry := &model.Inquiry{
 		TenantUID:      tenantUID,
 		PropertyID:     req.PropertyID,
+		UnitID:         req.UnitID,
+		TenantGender:   req.TenantGender,
 		InitialMessage: req.Message,
 		PreferredVisit: visitTime,
 		CreatedAt:      time.Now(),
@@ -0,0 +1,293 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxRecommendationSeeds      = 30
+	maxRecommendationCandidates = 300
+	recommendationNearbyKm      = 10.0
+)
+
+// recommendationSeed is an apartment the tenant showed interest in. Stronger
+// signals carry more weight: inquiring beats saving or rating, which beat
+// viewing.
+type recommendationSeed struct {
+	ApartmentID uint
+	Weight      float64
+	Verb        string // inquired about, saved, rated, viewed
+	At          time.Time
+}
+
+// Recommendation is a suggested apartment and why it was picked.
+type Recommendation struct {
+	model.ApartmentDetails
+	Score              float64  `json:"score"`
+	Reason             string   `json:"reason"`
+	BecauseApartmentID *uint    `json:"because_apartment_id"`
+	Matched            []string `json:"matched"`
+}
+
+// loadRecommendationSeeds collects the apartments uid inquired about, saved,
+// rated 4 stars or more and viewed, keeping the strongest signal for each.
+// It also returns every apartment uid has inquired on, which are never
+// recommended.
+func loadRecommendationSeeds(uid string) ([]recommendationSeed, map[uint]bool, error) {
+	type signalRow struct {
+		ApartmentID uint
+		Kind        string
+		At          time.Time
+	}
+	var rows []signalRow
+	sources := []struct {
+		kind  string
+		query interface{}
+		sql   string
+		where string
+		args  []interface{}
+	}{
+		{"inquiry", &model.Inquiry{}, "property_id AS apartment_id, created_at AS at", "tenant_uid = ?", []interface{}{uid}},
+		{"wishlist", &model.Wishlist{}, "apartment_id, created_at AS at", "uid = ?", []interface{}{uid}},
+		{"rating", &model.Rating{}, "apartment_id, created_at AS at", "tenant_id = ? AND rating >= ?", []interface{}{uid, 4}},
+		// Views only keep an expiry, which moves with each new view
+		{"viewed", &model.RecentlyViewed{}, "apartment_id, expires_at AS at", "uid = ? AND expires_at > NOW()", []interface{}{uid}},
+	}
+	for _, source := range sources {
+		var found []signalRow
+		if err := middleware.DBConn.Model(source.query).Select(source.sql).Where(source.where, source.args...).Scan(&found).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, row := range found {
+			row.Kind = source.kind
+			rows = append(rows, row)
+		}
+	}
+
+	signals := map[string]recommendationSeed{
+		"inquiry":  {Weight: 3, Verb: "inquired about"},
+		"wishlist": {Weight: 2, Verb: "saved"},
+		"rating":   {Weight: 2, Verb: "rated"},
+		"viewed":   {Weight: 1, Verb: "viewed"},
+	}
+
+	inquired := map[uint]bool{}
+	strongest := map[uint]recommendationSeed{}
+	for _, row := range rows {
+		if row.Kind == "inquiry" {
+			inquired[row.ApartmentID] = true
+		}
+		seed := signals[row.Kind]
+		seed.ApartmentID, seed.At = row.ApartmentID, row.At
+		current, ok := strongest[row.ApartmentID]
+		if !ok || seed.Weight > current.Weight || (seed.Weight == current.Weight && seed.At.After(current.At)) {
+			strongest[row.ApartmentID] = seed
+		}
+	}
+
+	seeds := make([]recommendationSeed, 0, len(strongest))
+	for _, seed := range strongest {
+		seeds = append(seeds, seed)
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		if seeds[i].Weight != seeds[j].Weight {
+			return seeds[i].Weight > seeds[j].Weight
+		}
+		return seeds[i].At.After(seeds[j].At)
+	})
+	if len(seeds) > maxRecommendationSeeds {
+		seeds = seeds[:maxRecommendationSeeds]
+	}
+	return seeds, inquired, nil
+}
+
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(d float64) float64 { return d * math.Pi / 180 }
+	dLat, dLng := toRad(lat2-lat1), toRad(lng2-lng1)
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * 6371 * math.Asin(math.Sqrt(a))
+}
+
+func hasCoordinates(a model.Apartment) bool {
+	return a.Latitude != 0 || a.Longitude != 0
+}
+
+// similarity scores candidate against seed between 0 and 1 on price band,
+// property type, amenities, gender policy and distance, and names what
+// matched.
+func similarity(seed, candidate model.ApartmentDetails) (float64, []string) {
+	var score float64
+	var matched []string
+
+	if seed.RentPrice > 0 {
+		if closeness := 1 - math.Abs(candidate.RentPrice-seed.RentPrice)/seed.RentPrice; closeness > 0 {
+			score += 0.3 * closeness
+			if closeness >= 0.8 {
+				matched = append(matched, "similar price")
+			}
+		}
+	}
+
+	if strings.EqualFold(seed.PropertyType, candidate.PropertyType) {
+		score += 0.2
+		matched = append(matched, "same property type")
+	}
+
+	if len(seed.Amenities) > 0 && len(candidate.Amenities) > 0 {
+		have := map[string]bool{}
+		for _, a := range candidate.Amenities {
+			have[strings.ToLower(strings.TrimSpace(a))] = true
+		}
+		shared := 0
+		for _, a := range seed.Amenities {
+			if have[strings.ToLower(strings.TrimSpace(a))] {
+				shared++
+			}
+		}
+		union := len(seed.Amenities) + len(candidate.Amenities) - shared
+		score += 0.2 * float64(shared) / float64(union)
+		if shared > 0 {
+			matched = append(matched, fmt.Sprintf("%d shared amenities", shared))
+		}
+	}
+
+	if strings.EqualFold(seed.Allowed_Gender, candidate.Allowed_Gender) {
+		score += 0.1
+		matched = append(matched, "same gender policy")
+	}
+
+	if hasCoordinates(seed.Apartment) && hasCoordinates(candidate.Apartment) {
+		km := haversineKm(seed.Latitude, seed.Longitude, candidate.Latitude, candidate.Longitude)
+		if km < recommendationNearbyKm {
+			score += 0.2 * (1 - km/recommendationNearbyKm)
+			matched = append(matched, fmt.Sprintf("%.1f km away", km))
+		}
+	}
+	return score, matched
+}
+
+// FetchRecommendations suggests approved, available apartments that are
+// similar to the ones the tenant viewed, saved, rated highly or inquired
+// about. Each comes with the apartment it was matched to. Apartments the
+// tenant already inquired on are left out. Tenants without any history get
+// the most popular listings. ?limit= defaults to 10, max 50.
+func FetchRecommendations(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	seeds, inquired, err := loadRecommendationSeeds(uid)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load browsing history"})
+	}
+
+	seedIDs := make([]uint, len(seeds))
+	exclude := []uint{0}
+	for i, seed := range seeds {
+		seedIDs[i] = seed.ApartmentID
+		exclude = append(exclude, seed.ApartmentID)
+	}
+	for id := range inquired {
+		exclude = append(exclude, id)
+	}
+
+	var seedApartments []model.Apartment
+	if len(seedIDs) > 0 {
+		if err := middleware.DBConn.Where("id IN ?", seedIDs).Find(&seedApartments).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load browsing history"})
+		}
+	}
+	seedDetails, err := repository.LoadApartmentDetails(middleware.DBConn, seedApartments)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load browsing history"})
+	}
+
+	// Narrow candidates to the price range and property types the tenant
+	// looked at before scoring them one by one
+	query := middleware.DBConn.
+		Where("status = ? AND availability = ?", "Approved", "Available").
+		Where("id NOT IN ?", exclude)
+	if len(seedDetails) > 0 {
+		minPrice, maxPrice := math.Inf(1), 0.0
+		var types []string
+		for _, d := range seedDetails {
+			minPrice = math.Min(minPrice, d.RentPrice)
+			maxPrice = math.Max(maxPrice, d.RentPrice)
+			types = append(types, d.PropertyType)
+		}
+		query = query.Where("(rent_price BETWEEN ? AND ? OR property_type IN ?)", minPrice*0.5, maxPrice*1.5, types)
+	}
+
+	var candidates []model.Apartment
+	if err := query.
+		Order("(SELECT COUNT(*) FROM inquiries WHERE inquiries.property_id = apartments.id) DESC").
+		Order("created_at DESC").
+		Limit(maxRecommendationCandidates).
+		Find(&candidates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load apartments"})
+	}
+	candidateDetails, err := repository.LoadApartmentDetails(middleware.DBConn, candidates)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load apartments"})
+	}
+
+	weightByID := map[uint]recommendationSeed{}
+	for _, seed := range seeds {
+		weightByID[seed.ApartmentID] = seed
+	}
+
+	recommendations := make([]Recommendation, 0, len(candidateDetails))
+	for _, candidate := range candidateDetails {
+		rec := Recommendation{ApartmentDetails: candidate, Matched: []string{}}
+		if len(seedDetails) == 0 {
+			rec.Reason = "Popular with other tenants"
+			recommendations = append(recommendations, rec)
+			continue
+		}
+
+		// Explain with the seed that contributes most
+		for _, seed := range seedDetails {
+			signal := weightByID[seed.ID]
+			sim, matched := similarity(seed, candidate)
+			if score := sim * signal.Weight; score > rec.Score {
+				seedID := seed.ID
+				rec.Score = score
+				rec.BecauseApartmentID = &seedID
+				rec.Reason = fmt.Sprintf("Because you %s %s", signal.Verb, seed.PropertyName)
+				rec.Matched = matched
+			}
+		}
+		if rec.Score > 0 {
+			rec.Score = math.Round(rec.Score*1000) / 1000
+			recommendations = append(recommendations, rec)
+		}
+	}
+
+	// Candidates come most popular first, so ties keep that order
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"recommendations": recommendations,
+	})
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/repository"
+	"github.com/Conding-Student/backend/savedsearch"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	maxSavedSearches     = 20
+	maxSavedSearchRadius = 100.0
+)
+
+// savedSearchRequest is the body for creating or editing a saved search.
+// Filters takes the same keys and values as /all/filter-apartments. Omitted
+// fields are left as they are when editing.
+type savedSearchRequest struct {
+	Name      *string           `json:"name"`
+	Filters   map[string]string `json:"filters"`
+	Latitude  *float64          `json:"latitude"`
+	Longitude *float64          `json:"longitude"`
+	RadiusKm  *float64          `json:"radius_km"`
+	Frequency *string           `json:"frequency"`
+	Paused    *bool             `json:"paused"`
+}
+
+type savedSearchResponse struct {
+	model.SavedSearch
+	Filters map[string]string `json:"filters"`
+}
+
+func toSavedSearchResponse(s model.SavedSearch) savedSearchResponse {
+	filters := map[string]string{}
+	values, _ := url.ParseQuery(s.Filters)
+	for key := range values {
+		filters[key] = values.Get(key)
+	}
+	return savedSearchResponse{SavedSearch: s, Filters: filters}
+}
+
+// applyTo validates the request and copies it onto s.
+func (req savedSearchRequest) applyTo(s *model.SavedSearch) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 100 {
+			return errors.New("name is required and must be at most 100 characters")
+		}
+		s.Name = name
+	}
+
+	if req.Filters != nil {
+		values := url.Values{}
+		for _, key := range repository.ListingFilterKeys {
+			if value := strings.TrimSpace(req.Filters[key]); value != "" {
+				values.Set(key, value)
+			}
+		}
+		s.Filters = values.Encode()
+	}
+
+	if req.Latitude != nil || req.Longitude != nil || req.RadiusKm != nil {
+		if req.Latitude == nil || req.Longitude == nil || req.RadiusKm == nil {
+			return errors.New("latitude, longitude and radius_km must be given together")
+		}
+		if *req.Latitude < -90 || *req.Latitude > 90 || *req.Longitude < -180 || *req.Longitude > 180 {
+			return errors.New("latitude or longitude is out of range")
+		}
+		if *req.RadiusKm <= 0 || *req.RadiusKm > maxSavedSearchRadius {
+			return errors.New("radius_km must be more than 0 and at most 100")
+		}
+		s.Latitude, s.Longitude, s.RadiusKm = req.Latitude, req.Longitude, req.RadiusKm
+	}
+
+	if req.Frequency != nil {
+		if *req.Frequency != savedsearch.FrequencyInstant && *req.Frequency != savedsearch.FrequencyDaily {
+			return errors.New("frequency must be instant or daily")
+		}
+		s.Frequency = *req.Frequency
+	}
+
+	if req.Paused != nil {
+		s.Paused = *req.Paused
+	}
+	return nil
+}
+
+// findOwnSavedSearch loads the saved search in :id if it belongs to the caller.
+func findOwnSavedSearch(c *fiber.Ctx) (model.SavedSearch, error) {
+	var search model.SavedSearch
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return search, err
+	}
+	err = middleware.DBConn.Where("id = ? AND tenant_uid = ?", c.Params("id"), uid).First(&search).Error
+	return search, err
+}
+
+func savedSearchLookupError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Saved search not found"})
+	}
+	if errors.Is(err, fiber.ErrUnauthorized) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+}
+
+// CreateSavedSearch saves the caller's current filters, and optionally a
+// radius around a point, under a name. New matching listings are pushed
+// instantly or in a daily digest depending on frequency.
+func CreateSavedSearch(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req savedSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Name == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required"})
+	}
+
+	search := model.SavedSearch{TenantUID: uid, Frequency: savedsearch.FrequencyInstant}
+	if err := req.applyTo(&search); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var count int64
+	if err := middleware.DBConn.Model(&model.SavedSearch{}).Where("tenant_uid = ?", uid).Count(&count).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if count >= maxSavedSearches {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "You can save at most 20 searches"})
+	}
+
+	if err := middleware.DBConn.Create(&search).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save search"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":      "Search saved",
+		"saved_search": toSavedSearchResponse(search),
+	})
+}
+
+// FetchSavedSearches lists the caller's saved searches, newest first.
+func FetchSavedSearches(c *fiber.Ctx) error {
+	uid, err := GetUIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var searches []model.SavedSearch
+	if err := middleware.DBConn.Where("tenant_uid = ?", uid).Order("created_at DESC").Find(&searches).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	results := make([]savedSearchResponse, len(searches))
+	for i, s := range searches {
+		results[i] = toSavedSearchResponse(s)
+	}
+	return c.JSON(fiber.Map{"saved_searches": results})
+}
+
+// UpdateSavedSearch edits the name, filters, radius, frequency or paused
+// state of one of the caller's saved searches.
+func UpdateSavedSearch(c *fiber.Ctx) error {
+	search, err := findOwnSavedSearch(c)
+	if err != nil {
+		return savedSearchLookupError(c, err)
+	}
+
+	var req savedSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.applyTo(&search); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := middleware.DBConn.Save(&search).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update saved search"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Saved search updated",
+		"saved_search": toSavedSearchResponse(search),
+	})
+}
+
+func setSavedSearchPaused(c *fiber.Ctx, paused bool) error {
+	search, err := findOwnSavedSearch(c)
+	if err != nil {
+		return savedSearchLookupError(c, err)
+	}
+
+	if err := middleware.DBConn.Model(&search).Update("paused", paused).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update saved search"})
+	}
+
+	message := "Alerts resumed"
+	if paused {
+		message = "Alerts paused"
+	}
+	return c.JSON(fiber.Map{
+		"message":      message,
+		"saved_search": toSavedSearchResponse(search),
+	})
+}
+
+// PauseSavedSearch stops alerts for a saved search until it is resumed.
+func PauseSavedSearch(c *fiber.Ctx) error {
+	return setSavedSearchPaused(c, true)
+}
+
+// ResumeSavedSearch turns alerts for a paused saved search back on.
+func ResumeSavedSearch(c *fiber.Ctx) error {
+	return setSavedSearchPaused(c, false)
+}
+
+// DeleteSavedSearch removes one of the caller's saved searches and its
+// match history.
+func DeleteSavedSearch(c *fiber.Ctx) error {
+	search, err := findOwnSavedSearch(c)
+	if err != nil {
+		return savedSearchLookupError(c, err)
+	}
+
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("saved_search_id = ?", search.ID).Delete(&model.SavedSearchMatch{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&search).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete saved search"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Saved search deleted"})
+}
@@ -20,6 +20,7 @@ func ConfirmRental(c *fiber.Ctx) error {
 		ApartmentID uint   `json:"apartment_id"`
 		IsRenting   bool   `json:"is_renting"`
 		TenantID    string `json:"tenant_id,omitempty"` // For landlord confirmations
+		UnitID      *uint  `json:"unit_id,omitempty"`   // Room or bedspace being rented
 	}
 
 	var req request
@@ -52,6 +53,15 @@ func ConfirmRental(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.UnitID != nil {
+		var unit model.ApartmentUnit
+		if err := middleware.DBConn.First(&unit, "id = ? AND apartment_id = ?", *req.UnitID, req.ApartmentID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unit not found in this apartment",
+			})
+		}
+	}
+
 	// Tenant confirmation flow
 	if userType == "Tenant" {
 		// Each tenant has their own agreement record
@@ -65,6 +75,7 @@ func ConfirmRental(c *fiber.Ctx) error {
 				ApartmentID:       req.ApartmentID,
 				TenantID:          uid,
 				LandlordID:        apartment.UserID,
+				UnitID:            req.UnitID,
 				TenantConfirmed:   req.IsRenting,
 				LandlordConfirmed: false, // Landlord needs to confirm separately
 				StartDate:         time.Now(),
@@ -82,6 +93,9 @@ func ConfirmRental(c *fiber.Ctx) error {
 		} else {
 			// Update existing tenant agreement
 			agreement.TenantConfirmed = req.IsRenting
+			if req.UnitID != nil {
+				agreement.UnitID = req.UnitID
+			}
 			if err := middleware.DBConn.Save(&agreement).Error; err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"error": "Failed to update rental agreement",
@@ -113,6 +127,7 @@ func ConfirmRental(c *fiber.Ctx) error {
 				ApartmentID:       req.ApartmentID,
 				TenantID:          req.TenantID,
 				LandlordID:        uid,
+				UnitID:            req.UnitID,
 				TenantConfirmed:   false, // Tenant needs to confirm separately
 				LandlordConfirmed: req.IsRenting,
 				StartDate:         time.Now(),
@@ -130,6 +145,9 @@ func ConfirmRental(c *fiber.Ctx) error {
 		} else {
 			// Update existing agreement with landlord confirmation
 			agreement.LandlordConfirmed = req.IsRenting
+			if req.UnitID != nil {
+				agreement.UnitID = req.UnitID
+			}
 			if err := middleware.DBConn.Save(&agreement).Error; err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"error": "Failed to update rental agreement",
@@ -142,15 +160,45 @@ func ConfirmRental(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := markUnitIfFull(agreement.UnitID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update unit availability",
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Rental confirmation updated successfully",
 		"data": fiber.Map{
 			"tenant_confirmed":   agreement.TenantConfirmed,
 			"landlord_confirmed": agreement.LandlordConfirmed,
+			"unit_id":            agreement.UnitID,
 		},
 	})
 }
 
+// markUnitIfFull takes a unit off the market once its confirmed, active
+// agreements reach its capacity.
+func markUnitIfFull(unitID *uint) error {
+	if unitID == nil {
+		return nil
+	}
+	var unit model.ApartmentUnit
+	if err := middleware.DBConn.First(&unit, *unitID).Error; err != nil {
+		return err
+	}
+
+	var occupied int64
+	if err := middleware.DBConn.Model(&model.RentalAgreement{}).
+		Where("unit_id = ? AND is_active = ? AND tenant_confirmed = ? AND landlord_confirmed = ?", unit.ID, true, true, true).
+		Count(&occupied).Error; err != nil {
+		return err
+	}
+	if occupied < int64(unit.Capacity) || unit.Availability != "Available" {
+		return nil
+	}
+	return middleware.DBConn.Model(&unit).Update("availability", "Not Available").Error
+}
+
 // SubmitRating lets a tenant submit or update their rating for an apartment
 func SubmitRating(c *fiber.Ctx) error {
 	type request struct {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/Conding-Student/backend/middleware"
 	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/repository"
 
 	"gorm.io/gorm"
 
@@ -116,93 +117,16 @@ func FetchRecentlyViewed(c *fiber.Ctx) error {
 	}
 
 	// Step 3: Fetch additional details
-	var apartmentDetails []fiber.Map
-	for _, apartment := range apartments {
-		// Amenities
-		var amenities []model.Amenity
-		if err := middleware.DBConn.
-			Joins("JOIN apartment_amenities aa ON aa.amenity_id = amenities.id").
-			Where("aa.apartment_id = ?", apartment.ID).
-			Find(&amenities).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to fetch amenities",
-				"error":   err.Error(),
-			})
-		}
-
-		// House Rules
-		var houseRules []model.HouseRule
-		if err := middleware.DBConn.
-			Joins("JOIN apartment_house_rules ahr ON ahr.house_rule_id = house_rules.id").
-			Where("ahr.apartment_id = ?", apartment.ID).
-			Find(&houseRules).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to fetch house rules",
-				"error":   err.Error(),
-			})
-		}
-
-		// Images
-		var images []model.ApartmentImage
-		if err := middleware.DBConn.
-			Where("apartment_id = ?", apartment.ID).
-			Find(&images).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to fetch images",
-				"error":   err.Error(),
-			})
-		}
-		var landlord model.User
-		if err := middleware.DBConn.Where("uid = ?", apartment.Uid).First(&landlord).Error; err != nil {
-			continue
-		}
-		// Inquiry count
-		var inquiryCount int64
-		if err := middleware.DBConn.
-			Model(&model.Inquiry{}).
-			Where("property_id = ?", apartment.ID).
-			Count(&inquiryCount).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to count inquiries",
-				"error":   err.Error(),
-			})
-		}
-
-		apartmentDetails = append(apartmentDetails, fiber.Map{
-			"apartment_id":  apartment.ID,
-			"property_name": apartment.PropertyName,
-			"property_type": apartment.PropertyType,
-			"rent_price":    apartment.RentPrice,
-			"location_link": apartment.LocationLink,
-			"landmarks":     apartment.Landmarks,
-			"images": func() []fiber.Map {
-				var imageDetails []fiber.Map
-				for _, image := range images {
-					imageDetails = append(imageDetails, fiber.Map{
-						"image_url": image.ImageURL,
-					})
-				}
-				return imageDetails
-			}(),
-			"amenities": func() []string {
-				var names []string
-				for _, amenity := range amenities {
-					names = append(names, amenity.Name)
-				}
-				return names
-			}(),
-			"house_rules": func() []string {
-				var rules []string
-				for _, rule := range houseRules {
-					rules = append(rules, rule.Rule)
-				}
-				return rules
-			}(),
-			"inquiries_count":    inquiryCount,
-			"landlord_name":      landlord.Fullname,
-			"landlord_phone":     landlord.PhoneNumber,
-			"landlord_photo_url": landlord.PhotoURL})
-
+	details, err := repository.LoadApartmentDetails(middleware.DBConn, apartments)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database error: Unable to fetch apartment details",
+			"error":   err.Error(),
+		})
+	}
+	apartmentDetails := make([]fiber.Map, 0, len(details))
+	for _, d := range details {
+		apartmentDetails = append(apartmentDetails, savedApartmentSummary(d))
 	}
 
 	return c.JSON(fiber.Map{
@@ -347,88 +271,16 @@ func FetchwishlistForTenant(c *fiber.Ctx) error {
 	}
 
 	// Step 3: Fetch the associated details like amenities, house rules, images, and inquiries
-	var apartmentDetails []fiber.Map
-	for _, apartment := range apartments {
-		// Fetch amenities associated with the apartment
-		var amenities []model.Amenity
-		if err := middleware.DBConn.
-			Joins("JOIN apartment_amenities aa ON aa.amenity_id = amenities.id").
-			Where("aa.apartment_id = ?", apartment.ID).
-			Find(&amenities).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to fetch amenities",
-				"error":   err.Error(),
-			})
-		}
-		var landlord model.User
-		if err := middleware.DBConn.Where("uid = ?", apartment.Uid).First(&landlord).Error; err != nil {
-			continue
-		}
-		// Fetch house rules associated with the apartment
-		var houseRules []model.HouseRule
-		if err := middleware.DBConn.
-			Joins("JOIN apartment_house_rules ahr ON ahr.house_rule_id = house_rules.id").
-			Where("ahr.apartment_id = ?", apartment.ID).
-			Find(&houseRules).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to fetch house rules",
-				"error":   err.Error(),
-			})
-		}
-
-		// Fetch images for the apartment
-		var images []model.ApartmentImage
-		if err := middleware.DBConn.Where("apartment_id = ?", apartment.ID).Find(&images).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to fetch images",
-				"error":   err.Error(),
-			})
-		}
-
-		// Fetch the number of inquiries for the apartment
-		var inquiryCount int64
-		if err := middleware.DBConn.Model(&model.Inquiry{}).Where("property_id = ?", apartment.ID).Count(&inquiryCount).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Database error: Unable to count inquiries",
-				"error":   err.Error(),
-			})
-		}
-
-		// Prepare the apartment details with amenities, house rules, images, and inquiries
-		apartmentDetails = append(apartmentDetails, fiber.Map{
-			"apartment_id":  apartment.ID,
-			"property_name": apartment.PropertyName,
-			"property_type": apartment.PropertyType,
-			"rent_price":    apartment.RentPrice,
-			"location_link": apartment.LocationLink,
-			"landmarks":     apartment.Landmarks,
-			"images": func() []fiber.Map {
-				var imageDetails []fiber.Map
-				for _, image := range images {
-					imageDetails = append(imageDetails, fiber.Map{
-						"image_url": image.ImageURL, // Exclude apartment_id
-					})
-				}
-				return imageDetails
-			}(),
-			"amenities": func() []string {
-				var amenityNames []string
-				for _, amenity := range amenities {
-					amenityNames = append(amenityNames, amenity.Name)
-				}
-				return amenityNames
-			}(),
-			"house_rules": func() []string {
-				var ruleNames []string
-				for _, rule := range houseRules {
-					ruleNames = append(ruleNames, rule.Rule)
-				}
-				return ruleNames
-			}(),
-			"inquiries_count":    inquiryCount,
-			"landlord_name":      landlord.Fullname,
-			"landlord_phone":     landlord.PhoneNumber,
-			"landlord_photo_url": landlord.PhotoURL})
+	details, err := repository.LoadApartmentDetails(middleware.DBConn, apartments)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database error: Unable to fetch apartment details",
+			"error":   err.Error(),
+		})
+	}
+	apartmentDetails := make([]fiber.Map, 0, len(details))
+	for _, d := range details {
+		apartmentDetails = append(apartmentDetails, savedApartmentSummary(d))
 	}
 
 	// 🎉 Success Response with wishlist apartments and their details
@@ -437,3 +289,28 @@ func FetchwishlistForTenant(c *fiber.Ctx) error {
 		"apartments": apartmentDetails, // The approved apartment details with all info
 	})
 }
+
+// savedApartmentSummary is the compact apartment shape the wishlist and
+// recently viewed screens read.
+func savedApartmentSummary(d model.ApartmentDetails) fiber.Map {
+	images := make([]fiber.Map, 0, len(d.Images))
+	for _, url := range d.Images {
+		images = append(images, fiber.Map{"image_url": url})
+	}
+	return fiber.Map{
+		"apartment_id":       d.ID,
+		"property_name":      d.PropertyName,
+		"property_type":      d.PropertyType,
+		"rent_price":         d.RentPrice,
+		"location_link":      d.LocationLink,
+		"landmarks":          d.Landmarks,
+		"images":             images,
+		"amenities":          d.Amenities,
+		"house_rules":        d.HouseRules,
+		"inquiries_count":    d.InquiriesCount,
+		"is_featured":        d.IsFeatured,
+		"landlord_name":      d.LandlordName,
+		"landlord_phone":     d.LandlordPhone,
+		"landlord_photo_url": d.LandlordPhotoURL,
+	}
+}
@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/payment"
+	"github.com/Conding-Student/backend/testdb"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const webhookSecret = "whsk_test_webhook"
+
+// payMongoStub stands in for the PayMongo API: sources start chargeable and
+// POST /v1/payments captures them.
+type payMongoStub struct {
+	mu       sync.Mutex
+	amount   int64
+	sources  map[string]string // source ID -> status
+	payments int
+}
+
+func newPayMongoStub(t *testing.T, amount int64) *httptest.Server {
+	stub := &payMongoStub{amount: amount, sources: map[string]string{}}
+	server := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *payMongoStub) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/sources/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/sources/")
+		status, ok := s.sources[id]
+		if !ok {
+			status = "chargeable"
+		}
+		fmt.Fprintf(w, `{"data":{"id":%q,"attributes":{"amount":%d,"status":%q}}}`, id, s.amount, status)
+
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/payments":
+		var req struct {
+			Data struct {
+				Attributes struct {
+					Source struct {
+						ID string `json:"id"`
+					} `json:"source"`
+				} `json:"attributes"`
+			} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		s.payments++
+		s.sources[req.Data.Attributes.Source.ID] = "paid"
+		fmt.Fprintf(w, `{"data":{"id":"pay_stub_%d","attributes":{"status":"paid"}}}`, s.payments)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/payments":
+		sourceID := r.URL.Query().Get("source_id")
+		fmt.Fprintf(w, `{"data":[{"id":"pay_stub_1","attributes":{"source":{"id":%q},"status":"paid"}}]}`, sourceID)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// countingProvider counts Capture calls made through the PaymentProvider.
+type countingProvider struct {
+	payment.PaymentProvider
+	captures atomic.Int32
+}
+
+func (p *countingProvider) Capture(ctx context.Context, checkoutID string, amount int64) (string, error) {
+	p.captures.Add(1)
+	return p.PaymentProvider.Capture(ctx, checkoutID, amount)
+}
+
+// webhookFixture is a source.chargeable event recorded from PayMongo, shared
+// with the payment package's signature tests.
+func webhookFixture(t *testing.T) (body []byte, sourceID string) {
+	t.Helper()
+	body, err := os.ReadFile("../payment/testdata/source_chargeable.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event WebhookPayload
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	return body, event.Data.Attributes.Data.ID
+}
+
+func TestHandleWebhook(t *testing.T) {
+	body, sourceID := webhookFixture(t)
+
+	setup := func(t *testing.T) (*fiber.App, *countingProvider, *gorm.DB, *model.Transaction) {
+		db := testdb.Open(t)
+		server := newPayMongoStub(t, 501000)
+
+		cfg := payment.Config{BaseURL: server.URL, SecretKey: "sk_test", WebhookSecret: webhookSecret, Currency: "PHP"}
+		provider := &countingProvider{PaymentProvider: payment.NewPayMongoProvider(cfg)}
+		svc := &PayMongoService{DB: db, Provider: provider, Config: cfg}
+		app := fiber.New()
+		app.Post("/api/webhook", svc.HandleWebhook)
+
+		txn := &model.Transaction{
+			UserID: "tenant-1", BaseAmount: 5000, InterestAmount: 10, TotalAmount: 5010,
+			PayMongoSourceID: sourceID, PaymentMethod: payment.MethodGCash, Status: "pending", Availment: "Deposit",
+		}
+		if err := db.Create(txn).Error; err != nil {
+			t.Fatal(err)
+		}
+		return app, provider, db, txn
+	}
+
+	deliver := func(t *testing.T, app *fiber.App, signature string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/webhook", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Paymongo-Signature", signature)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		app, provider, db, txn := setup(t)
+		if got := deliver(t, app, payment.SignWebhook(webhookSecret, body, false, time.Now())); got != http.StatusOK {
+			t.Fatalf("got %d, want 200", got)
+		}
+		if n := provider.captures.Load(); n != 1 {
+			t.Errorf("Capture called %d times, want 1", n)
+		}
+		assertTransaction(t, db, txn.ID, "paid", "pay_stub_1")
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		app, provider, db, txn := setup(t)
+		if got := deliver(t, app, payment.SignWebhook("whsk_attacker", body, false, time.Now())); got != http.StatusUnauthorized {
+			t.Fatalf("got %d, want 401", got)
+		}
+		if n := provider.captures.Load(); n != 0 {
+			t.Errorf("Capture called %d times, want 0", n)
+		}
+		assertTransaction(t, db, txn.ID, "pending", "")
+	})
+
+	t.Run("timestamp outside tolerance", func(t *testing.T) {
+		app, provider, db, txn := setup(t)
+		stale := time.Now().Add(-payment.WebhookTolerance - time.Minute)
+		if got := deliver(t, app, payment.SignWebhook(webhookSecret, body, false, stale)); got != http.StatusUnauthorized {
+			t.Fatalf("got %d, want 401", got)
+		}
+		if n := provider.captures.Load(); n != 0 {
+			t.Errorf("Capture called %d times, want 0", n)
+		}
+		assertTransaction(t, db, txn.ID, "pending", "")
+	})
+
+	t.Run("duplicate event ID", func(t *testing.T) {
+		app, provider, db, txn := setup(t)
+		if got := deliver(t, app, payment.SignWebhook(webhookSecret, body, false, time.Now())); got != http.StatusOK {
+			t.Fatalf("first delivery: got %d, want 200", got)
+		}
+
+		// Put the transaction back to pending so only the event ID claim can
+		// stop the redelivery from capturing and marking it paid again; a
+		// second markTransactionPaid would flip it to paid
+		if err := db.Model(txn).Updates(map[string]interface{}{"status": "pending", "pay_mongo_payment_id": ""}).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if got := deliver(t, app, payment.SignWebhook(webhookSecret, body, false, time.Now())); got != http.StatusOK {
+			t.Fatalf("redelivery: got %d, want 200", got)
+		}
+		if n := provider.captures.Load(); n != 1 {
+			t.Errorf("Capture called %d times, want 1", n)
+		}
+		assertTransaction(t, db, txn.ID, "pending", "")
+
+		var events int64
+		db.Model(&model.WebhookEvent{}).Count(&events)
+		if events != 1 {
+			t.Errorf("recorded %d webhook events, want 1", events)
+		}
+	})
+}
+
+func assertTransaction(t *testing.T, db *gorm.DB, id uint, wantStatus, wantPaymentID string) {
+	t.Helper()
+	var txn model.Transaction
+	if err := db.First(&txn, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if txn.Status != wantStatus || txn.PayMongoPaymentID != wantPaymentID {
+		t.Errorf("transaction is %s with payment %q, want %s with payment %q",
+			txn.Status, txn.PayMongoPaymentID, wantStatus, wantPaymentID)
+	}
+}
+
+func TestMarkTransactionPaidRunsHooksOnce(t *testing.T) {
+	db := testdb.Open(t)
+	svc := &PayMongoService{DB: db}
+
+	var runs int
+	saved := paidHooks
+	paidHooks = []PaidHook{func(tx *gorm.DB, txn *model.Transaction) error {
+		runs++
+		return nil
+	}}
+	t.Cleanup(func() { paidHooks = saved })
+
+	txn := &model.Transaction{UserID: "tenant-1", BaseAmount: 5000, TotalAmount: 5000, Status: "pending", Availment: "Deposit"}
+	if err := db.Create(txn).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Two deliveries that both read the row while it was still pending
+	first, second := *txn, *txn
+	if err := svc.markTransactionPaid(&first, "pay_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.markTransactionPaid(&second, "pay_2"); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("paid hooks ran %d times, want 1", runs)
+	}
+	assertTransaction(t, db, txn.ID, "paid", "pay_1")
+}
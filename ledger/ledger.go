@@ -0,0 +1,177 @@
+// Package ledger posts double-entry journals for money moving through the
+// platform, so each landlord's payable balance can be reported and
+// reconciled against payouts.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Conding-Student/backend/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AccountPlatformCash       = "platform_cash"
+	AccountLandlordPayable    = "landlord_payable"
+	AccountPlatformFeeRevenue = "platform_fee_revenue"
+)
+
+// Line is a single debit or credit to post.
+type Line struct {
+	Account    string
+	LandlordID string
+	Debit      float64
+	Credit     float64
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Post writes a journal and its lines unless a journal with the same key
+// already exists. Lines must balance. It reports whether anything was posted.
+func Post(tx *gorm.DB, journal model.LedgerJournal, lines []Line) (bool, error) {
+	var debits, credits float64
+	for _, l := range lines {
+		debits += l.Debit
+		credits += l.Credit
+	}
+	if round2(debits) != round2(credits) {
+		return false, fmt.Errorf("unbalanced journal %s: debits %.2f, credits %.2f", journal.JournalKey, debits, credits)
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&journal)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil // already posted
+	}
+
+	for _, l := range lines {
+		if l.Debit == 0 && l.Credit == 0 {
+			continue
+		}
+		entry := model.LedgerEntry{
+			JournalID:  journal.ID,
+			Account:    l.Account,
+			LandlordID: l.LandlordID,
+			Debit:      round2(l.Debit),
+			Credit:     round2(l.Credit),
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// landlordFor returns the landlord owed money for a transaction: the owner of
+// its apartment, unless the landlord is the one paying (e.g. for a listing
+// promotion), in which case the whole amount is platform revenue.
+func landlordFor(tx *gorm.DB, txn *model.Transaction) (string, error) {
+	if txn.ApartmentID == nil {
+		return "", nil
+	}
+	var apartment model.Apartment
+	if err := tx.Select("id, uid").First(&apartment, *txn.ApartmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	if apartment.Uid == txn.UserID {
+		return "", nil
+	}
+	return apartment.Uid, nil
+}
+
+// PostPayment records a paid transaction: cash in, the base amount owed to the
+// apartment's landlord and the fee kept by the platform.
+func PostPayment(tx *gorm.DB, txn *model.Transaction) error {
+	landlordID, err := landlordFor(tx, txn)
+	if err != nil {
+		return err
+	}
+
+	lines := []Line{{Account: AccountPlatformCash, Debit: txn.TotalAmount}}
+	if landlordID != "" {
+		lines = append(lines,
+			Line{Account: AccountLandlordPayable, LandlordID: landlordID, Credit: txn.BaseAmount},
+			Line{Account: AccountPlatformFeeRevenue, Credit: txn.TotalAmount - txn.BaseAmount},
+		)
+	} else {
+		lines = append(lines, Line{Account: AccountPlatformFeeRevenue, Credit: txn.TotalAmount})
+	}
+
+	txnID := txn.ID
+	_, err = Post(tx, model.LedgerJournal{
+		JournalKey:    fmt.Sprintf("payment:%d", txn.ID),
+		Kind:          "payment",
+		LandlordID:    landlordID,
+		TransactionID: &txnID,
+		Memo:          txn.Availment,
+	}, lines)
+	return err
+}
+
+// PostRefund reverses a succeeded refund proportionally: the landlord gives
+// back their share of the refunded amount and the platform its fee share.
+func PostRefund(tx *gorm.DB, txn *model.Transaction, refund *model.Refund) error {
+	landlordID, err := landlordFor(tx, txn)
+	if err != nil {
+		return err
+	}
+
+	lines := []Line{{Account: AccountPlatformCash, Credit: refund.Amount}}
+	if landlordID != "" && txn.TotalAmount > 0 {
+		landlordShare := round2(refund.Amount * txn.BaseAmount / txn.TotalAmount)
+		lines = append(lines,
+			Line{Account: AccountLandlordPayable, LandlordID: landlordID, Debit: landlordShare},
+			Line{Account: AccountPlatformFeeRevenue, Debit: refund.Amount - landlordShare},
+		)
+	} else {
+		lines = append(lines, Line{Account: AccountPlatformFeeRevenue, Debit: refund.Amount})
+	}
+
+	txnID, refundID := txn.ID, refund.ID
+	_, err = Post(tx, model.LedgerJournal{
+		JournalKey:    fmt.Sprintf("refund:%d", refund.ID),
+		Kind:          "refund",
+		LandlordID:    landlordID,
+		TransactionID: &txnID,
+		RefundID:      &refundID,
+		Memo:          refund.Reason,
+	}, lines)
+	return err
+}
+
+// PostPayout moves a payout out of the landlord's payable balance.
+func PostPayout(tx *gorm.DB, payout *model.Payout) error {
+	payoutID := payout.ID
+	_, err := Post(tx, model.LedgerJournal{
+		JournalKey: fmt.Sprintf("payout:%d", payout.ID),
+		Kind:       "payout",
+		LandlordID: payout.LandlordID,
+		PayoutID:   &payoutID,
+		Memo:       payout.Reference,
+	}, []Line{
+		{Account: AccountLandlordPayable, LandlordID: payout.LandlordID, Debit: payout.Amount},
+		{Account: AccountPlatformCash, Credit: payout.Amount},
+	})
+	return err
+}
+
+// LandlordBalance is what the platform currently owes a landlord.
+func LandlordBalance(db *gorm.DB, landlordID string) (float64, error) {
+	var balance float64
+	err := db.Model(&model.LedgerEntry{}).
+		Select("COALESCE(SUM(credit - debit), 0)").
+		Where("account = ? AND landlord_id = ?", AccountLandlordPayable, landlordID).
+		Scan(&balance).Error
+	return round2(balance), err
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/Conding-Student/backend/config"
 	"github.com/Conding-Student/backend/controller"
 	authController "github.com/Conding-Student/backend/controller/auth" // alias local auth package as authController
 	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/payment"
 	"github.com/Conding-Student/backend/routes"
+	"github.com/Conding-Student/backend/service"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -28,6 +29,7 @@ func main() {
 	// Step 1: Initialize Firebase App
 	firebaseApp := config.InitializeFirebase()
 	fmt.Println("✅ Firebase Initialized:", firebaseApp)
+	config.InitFirestore()
 
 	// Step 2: Initialize Firebase Auth with context
 	firebaseAuthClient, err := firebaseApp.Auth(context.Background())
@@ -44,36 +46,41 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	// Initialize PayMongo service
-	service := &controller.PayMongoService{
-		DB:        middleware.DBConn, // Use the global DB connection
-		PublicKey: os.Getenv("PAYMONGO_PUBLIC_KEY"),
-		SecretKey: os.Getenv("PAYMONGO_SECRET_KEY"),
+	// Initialize the token service used by every login and AuthMiddleware
+	service.Tokens, err = service.NewTokenServiceFromEnv(middleware.DBConn)
+	if err != nil {
+		log.Fatalf("🔥 Error initializing token service: %v", err)
+	}
+
+	// Initialize payment service (PAYMENT_PROVIDER=fake runs fully offline)
+	paymentConfig := payment.ConfigFromEnv()
+	paymentService := &controller.PayMongoService{
+		DB:       middleware.DBConn, // Use the global DB connection
+		Provider: payment.NewProvider(paymentConfig),
+		Config:   paymentConfig,
 	}
+	log.Printf("💳 Payment provider: %s", paymentService.Provider.Name())
 
 	// Step 4: Create Fiber App
 	app := fiber.New(fiber.Config{
 		AppName: middleware.GetEnv("PROJ_NAME"),
+		// Bodies up to BodyLimit are buffered; larger ones are streamed.
+		// Only media uploads may be larger, and UploadMedia reads their
+		// multipart stream itself, so fasthttp must not pre-parse it.
+		BodyLimit:                    middleware.MaxBodyBytes,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
+	app.Use(middleware.LimitBody(middleware.MaxBodyBytes, "/media/uploads"))
 
-	app.Post("/api/create-source", service.CreateSource)
-	app.Post("/api/webhook", service.HandleWebhook)
-	app.Get("/api/transaction/:source_id", service.GetTransaction)
-	app.Get("/api/transactions", service.GetTransactions)
-	app.Get(("/api/get-all/transaction"), service.GetAllTransactions)
-
-	// PayMongo redirect routes
-app.Get("/success", service.HandleSuccessRedirect) // Add this
-    app.Get("/failed", service.HandleFailedRedirect)   // Optional for failed redirects
+	routes.PaymentRoutes(app, paymentService)
+	routes.BillingRoutes(app, paymentService)
 
 	// CORS CONFIG
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowHeaders: "Origin, Content-Type, Accept",
 	}))
-	// Example usage of the JWT secret key
-	jwtSecret := os.Getenv("JWT_SECRET")
-	log.Println("Loaded JWT Secret:", jwtSecret) // 🔍 Debugging: REMOVE in production
 
 	// Do not remove this endpoint
 	app.Get("/favicon.ico", func(c *fiber.Ctx) error {
@@ -0,0 +1,193 @@
+// Package media checks uploaded files against what each use allows, streams
+// them to Cloudinary and hands them out as single-use assets. The content
+// type is sniffed from the bytes, never taken from the client.
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register decoders for image.DecodeConfig
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Conding-Student/backend/config"
+	"github.com/Conding-Student/backend/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Purposes an asset can be uploaded for
+const (
+	PurposeListingImage   = "listing_image"
+	PurposeListingVideo   = "listing_video"
+	PurposeValidID        = "valid_id"
+	PurposeBusinessPermit = "business_permit"
+)
+
+const mb = 1 << 20
+
+// maxDimension guards against decompression bombs: tiny files that claim
+// enormous pixel sizes.
+const maxDimension = 10000
+
+type rule struct {
+	types     map[string]string // sniffed content type to Cloudinary resource type
+	maxBytes  int64
+	minWidth  int
+	minHeight int
+}
+
+var imageTypes = map[string]string{"image/jpeg": "image", "image/png": "image"}
+
+var rules = map[string]rule{
+	PurposeListingImage: {types: imageTypes, maxBytes: 10 * mb, minWidth: 400, minHeight: 300},
+	PurposeListingVideo: {
+		types:    map[string]string{"video/mp4": "video", "video/webm": "video", "video/quicktime": "video"},
+		maxBytes: 100 * mb,
+	},
+	// IDs must be legible photos; permits may also be scanned PDFs
+	PurposeValidID: {types: imageTypes, maxBytes: 10 * mb, minWidth: 600, minHeight: 400},
+	PurposeBusinessPermit: {
+		types:    map[string]string{"image/jpeg": "image", "image/png": "image", "application/pdf": "image"},
+		maxBytes: 10 * mb, minWidth: 600, minHeight: 400,
+	},
+}
+
+// MaxUploadBytes is the largest file any purpose accepts.
+const MaxUploadBytes = 100 * mb
+
+var (
+	ErrUnknownPurpose   = errors.New("purpose must be listing_image, listing_video, valid_id or business_permit")
+	ErrTooLarge         = errors.New("file is too large")
+	ErrUnsupportedType  = errors.New("file type is not allowed")
+	ErrBadDimensions    = errors.New("image dimensions are out of range")
+	ErrAssetUnavailable = errors.New("media asset not found, already used or uploaded for another purpose")
+)
+
+// Sniff detects the content type of f from its first bytes and rewinds it.
+func Sniff(f io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	// http.DetectContentType knows MP4 but not QuickTime's "qt  " brand
+	if len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")) && bytes.Equal(head[8:12], []byte("qt  ")) {
+		return "video/quicktime", nil
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return contentType, nil
+}
+
+// Inspect checks f against the rules for purpose and returns its sniffed
+// content type, the Cloudinary resource type to store it as and, for images,
+// its dimensions.
+func Inspect(purpose string, f io.ReadSeeker, size int64) (contentType, resourceType string, width, height int, err error) {
+	r, ok := rules[purpose]
+	if !ok {
+		return "", "", 0, 0, ErrUnknownPurpose
+	}
+	if size > r.maxBytes {
+		return "", "", 0, 0, fmt.Errorf("%w: %s files are limited to %d MB", ErrTooLarge, purpose, r.maxBytes/mb)
+	}
+
+	if contentType, err = Sniff(f); err != nil {
+		return "", "", 0, 0, err
+	}
+	if resourceType, ok = r.types[contentType]; !ok {
+		return "", "", 0, 0, fmt.Errorf("%w: %s is not accepted for %s", ErrUnsupportedType, contentType, purpose)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return contentType, resourceType, 0, 0, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return "", "", 0, 0, fmt.Errorf("%w: the image could not be read", ErrUnsupportedType)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", "", 0, 0, err
+	}
+	if cfg.Width < r.minWidth || cfg.Height < r.minHeight || cfg.Width > maxDimension || cfg.Height > maxDimension {
+		return "", "", 0, 0, fmt.Errorf("%w: %s images must be between %dx%d and %dx%d pixels, got %dx%d",
+			ErrBadDimensions, purpose, r.minWidth, r.minHeight, maxDimension, maxDimension, cfg.Width, cfg.Height)
+	}
+	return contentType, resourceType, cfg.Width, cfg.Height, nil
+}
+
+// Store checks f, streams it to Cloudinary and records it as an unattached
+// asset of ownerUID.
+func Store(ctx context.Context, db *gorm.DB, ownerUID, purpose string, f io.ReadSeeker, size int64) (model.MediaAsset, error) {
+	contentType, resourceType, width, height, err := Inspect(purpose, f, size)
+	if err != nil {
+		return model.MediaAsset{}, err
+	}
+
+	url, publicID, err := config.UploadStream(ctx, f, resourceType, "rentxpert/"+purpose)
+	if err != nil {
+		return model.MediaAsset{}, err
+	}
+
+	asset := model.MediaAsset{
+		OwnerUID:    ownerUID,
+		Purpose:     purpose,
+		ContentType: contentType,
+		SizeBytes:   size,
+		Width:       width,
+		Height:      height,
+		URL:         url,
+		PublicID:    publicID,
+	}
+	return asset, db.Create(&asset).Error
+}
+
+// Claim marks the given assets of ownerUID as used and returns them in the
+// order of ids. Every asset must exist, be unused and have been uploaded for
+// one of purposes. Call it in the transaction that stores the URLs.
+func Claim(tx *gorm.DB, ownerUID string, ids []uint, purposes ...string) ([]model.MediaAsset, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	unique := map[uint]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+	if len(unique) != len(ids) {
+		return nil, fmt.Errorf("%w: the same asset is listed twice", ErrAssetUnavailable)
+	}
+
+	var assets []model.MediaAsset
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND owner_uid = ? AND purpose IN ? AND attached_at IS NULL", ids, ownerUID, purposes).
+		Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	if len(assets) != len(ids) {
+		return nil, ErrAssetUnavailable
+	}
+	if err := tx.Model(&model.MediaAsset{}).Where("id IN ?", ids).Update("attached_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]model.MediaAsset, len(assets))
+	for _, a := range assets {
+		byID[a.ID] = a
+	}
+	ordered := make([]model.MediaAsset, len(ids))
+	for i, id := range ids {
+		ordered[i] = byID[id]
+	}
+	return ordered, nil
+}
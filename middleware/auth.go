@@ -3,8 +3,9 @@ package middleware
 import (
 	"log"
 
+	"github.com/Conding-Student/backend/service"
+
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthMiddleware verifies the JWT token and extracts user claims
@@ -29,26 +30,14 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
+	claims, err := service.Tokens.ParseAccessToken(tokenString)
+	if err != nil {
 		log.Println("[ERROR] Invalid token:", err)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"message": "Invalid or expired token",
 		})
 	}
 
-	// Extract user claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		log.Println("[ERROR] Invalid token claims")
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid token claims",
-		})
-	}
-
 	log.Println("[INFO] Token validated successfully, user claims:", claims)
 
 	// Store user details in Fiber Locals
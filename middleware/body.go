@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MaxBodyBytes is the largest request body the API buffers. Bodies past it
+// are streamed by fasthttp, so LimitBody must refuse them on every route
+// that does not read the stream itself.
+const MaxBodyBytes = 4 << 20
+
+// LimitBody refuses request bodies over maxBytes and bodies of unknown length
+// (chunked transfer encoding), except on the paths in streamed, which read
+// and bound the body stream themselves.
+func LimitBody(maxBytes int, streamed ...string) fiber.Handler {
+	exempt := make(map[string]bool, len(streamed))
+	for _, path := range streamed {
+		exempt[path] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		if exempt[c.Path()] {
+			return c.Next()
+		}
+
+		// The body is left unread, so the connection cannot be reused
+		switch length := c.Request().Header.ContentLength(); {
+		case length == -1:
+			c.Context().SetConnectionClose()
+			return c.Status(fiber.StatusLengthRequired).JSON(fiber.Map{
+				"message": "Chunked request bodies are not accepted; send a Content-Length",
+			})
+		case length > maxBytes:
+			c.Context().SetConnectionClose()
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"message": fmt.Sprintf("Request bodies are limited to %d MB", maxBytes>>20),
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLimitBody(t *testing.T) {
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true})
+	app.Use(LimitBody(16, "/media/uploads"))
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Post("/api/webhook", ok)
+	app.Post("/media/uploads", func(c *fiber.Ctx) error {
+		if _, err := io.Copy(io.Discard, c.Context().RequestBodyStream()); err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{name: "within the limit", path: "/api/webhook", body: `{"ok":true}`, want: http.StatusOK},
+		{name: "over the limit", path: "/api/webhook", body: strings.Repeat("a", 17), want: http.StatusRequestEntityTooLarge},
+		{name: "chunked", path: "/api/webhook", body: `{"ok":true}`, chunked: true, want: http.StatusLengthRequired},
+		{name: "upload over the limit", path: "/media/uploads", body: strings.Repeat("a", 17), want: http.StatusOK},
+		{name: "chunked upload", path: "/media/uploads", body: strings.Repeat("a", 17), chunked: true, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
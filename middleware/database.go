@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"log"
 
-	//"github.com/Conding-Student/backend/model" // Corrected models import
+	"github.com/Conding-Student/backend/model" // Corrected models import
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -27,8 +27,20 @@ func ConnectDB() bool {
 		return true
 	}
 
+	if err := Migrate(DBConn); err != nil {
+		log.Fatal("❌ Migration failed:", err)
+		return true
+	}
+
+	log.Println("✅ Database connected and migrations successful.")
+	return false
+}
+
+// Migrate creates the tables added since the original schema and brings the
+// older tables up to date. Every step is idempotent, so it runs on each start.
+func Migrate(db *gorm.DB) error {
 	// ✅ Run AutoMigrate for all models
-	err = DBConn.AutoMigrate(
+	err := db.AutoMigrate(
 	// &model.User{},
 	// &model.Admins{},
 	// &model.Apartment{},
@@ -46,24 +58,86 @@ func ConnectDB() bool {
 	// &model.Rating{},
 	// &model.Transaction{},
 	// &model.AdminToken{},
+	&model.RefreshToken{},
+	&model.WebhookEvent{},
+	&model.Refund{},
+	&model.Invoice{},
+	&model.LedgerJournal{},
+	&model.LedgerEntry{},
+	&model.Payout{},
+	&model.ApartmentPromotion{},
+	&model.ReconciliationLog{},
+	&model.SavedSearch{},
+	&model.SavedSearchMatch{},
+	&model.ApartmentPriceHistory{},
+	&model.ModerationEvent{},
+	&model.ApartmentRevision{},
+	&model.ApartmentUnit{},
+	&model.AvailabilityWindow{},
+	&model.MediaAsset{},
 	)
 
+	if err != nil {
+		return err
+	}
+
 	// ✅ Create unique index (outside AutoMigrate)
-	if err := DBConn.Exec(`
+	if err := db.Exec(`
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_apartment_tenant
 		ON rental_agreements (apartment_id, tenant_id)
 	`).Error; err != nil {
-		log.Fatal("❌ Failed to create unique index:", err)
-		return true
+		return fmt.Errorf("failed to create unique index: %v", err)
 	}
 
-	if err != nil {
-		log.Fatal("❌ Migration failed:", err)
-		return true
+	// ✅ Columns added to tables that predate AutoMigrate
+	migrations := []string{
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS payment_method varchar(20) NOT NULL DEFAULT 'gcash'`,
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS pay_mongo_payment_intent_id varchar(50)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_pay_mongo_payment_intent_id ON transactions (pay_mongo_payment_intent_id)`,
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS refunded_amount decimal(10,2) NOT NULL DEFAULT 0`,
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS apartment_id bigint`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_apartment_id ON transactions (apartment_id)`,
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS invoice_id bigint`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_invoice_id ON transactions (invoice_id)`,
+		`ALTER TABLE apartments ADD COLUMN IF NOT EXISTS featured_until timestamptz`,
+		`CREATE INDEX IF NOT EXISTS idx_apartments_featured_until ON apartments (featured_until)`,
+		// Listing search: full text over name, address, landmarks and type, plus
+		// trigram similarity for misspellings. Generated columns keep both in step
+		// with every insert and update of a listing.
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`ALTER TABLE apartments ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(property_name, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(address, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(landmarks, '')), 'C') ||
+			setweight(to_tsvector('simple', coalesce(property_type, '')), 'D')) STORED`,
+		`ALTER TABLE apartments ADD COLUMN IF NOT EXISTS search_document text GENERATED ALWAYS AS (
+			coalesce(property_name, '') || ' ' || coalesce(address, '') || ' ' ||
+			coalesce(landmarks, '') || ' ' || coalesce(property_type, '')) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_apartments_search_vector ON apartments USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_apartments_search_document ON apartments USING GIN (search_document gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_apartments_property_name_trgm ON apartments USING GIN (property_name gin_trgm_ops)`,
+		`ALTER TABLE apartments ADD COLUMN IF NOT EXISTS submitted_at timestamptz`,
+		`ALTER TABLE apartments ADD COLUMN IF NOT EXISTS review_claimed_by varchar(50)`,
+		`ALTER TABLE apartments ADD COLUMN IF NOT EXISTS review_claimed_at timestamptz`,
+		`UPDATE apartments SET submitted_at = created_at WHERE submitted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_apartments_review_queue ON apartments (submitted_at, id) WHERE status IN ('Pending', 'Resubmitted')`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_apartment_revisions_pending ON apartment_revisions (apartment_id) WHERE status = 'pending'`,
+		`ALTER TABLE inquiries ADD COLUMN IF NOT EXISTS unit_id bigint`,
+		`CREATE INDEX IF NOT EXISTS idx_inquiries_unit_id ON inquiries (unit_id)`,
+		`ALTER TABLE inquiries ADD COLUMN IF NOT EXISTS tenant_gender varchar(20)`,
+		`ALTER TABLE rental_agreements ADD COLUMN IF NOT EXISTS unit_id bigint`,
+		`CREATE INDEX IF NOT EXISTS idx_rental_agreements_unit_id ON rental_agreements (unit_id)`,
+		// Start the price history of listings created before it was recorded
+		`INSERT INTO apartment_price_histories (apartment_id, new_price, changed_by, changed_at)
+			SELECT a.id, a.rent_price, '', a.created_at FROM apartments a
+			WHERE NOT EXISTS (SELECT 1 FROM apartment_price_histories h WHERE h.apartment_id = a.id)`,
 	}
-
-	log.Println("✅ Database connected and migrations successful.")
-	return false
+	for _, migration := range migrations {
+		if err := db.Exec(migration).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // package middleware
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RequireRole only lets the request through when the "role" claim stored by
+// AuthMiddleware matches one of the given roles. It must be registered after
+// AuthMiddleware.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userClaims, ok := c.Locals("user").(jwt.MapClaims)
+		if !ok {
+			log.Println("[ERROR] RequireRole called without validated token claims")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Unauthorized: Missing JWT claims",
+			})
+		}
+
+		role, _ := userClaims["role"].(string)
+		for _, allowed := range roles {
+			if role == allowed {
+				return c.Next()
+			}
+		}
+
+		log.Printf("[ERROR] Role %q is not allowed to access %s %s", role, c.Method(), c.Path())
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: You do not have access to this resource",
+		})
+	}
+}
+
+// RequireSelf only lets the request through when the route parameter param
+// is the caller's own uid, so users cannot read each other's data by
+// swapping the uid in the URL. Admins may pass any uid. It must be
+// registered after AuthMiddleware.
+func RequireSelf(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userClaims, ok := c.Locals("user").(jwt.MapClaims)
+		if !ok {
+			log.Println("[ERROR] RequireSelf called without validated token claims")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Unauthorized: Missing JWT claims",
+			})
+		}
+
+		uid, _ := userClaims["uid"].(string)
+		role, _ := userClaims["role"].(string)
+		if role == "Admin" || (uid != "" && uid == c.Params(param)) {
+			return c.Next()
+		}
+
+		log.Printf("[ERROR] User %q is not allowed to access %s %s", uid, c.Method(), c.Path())
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: You do not have access to this resource",
+		})
+	}
+}
@@ -28,6 +28,20 @@ type AdminToken struct {
 	
 }
 
+// RefreshToken stores the SHA-256 hash of an issued refresh token. Tokens
+// rotated from the same login share a FamilyID so reuse can revoke them all.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	FamilyID  string     `gorm:"type:varchar(64);not null;index"`
+	UID       string     `gorm:"not null;index"`
+	Email     string     `gorm:"type:varchar(255)"`
+	Role      string     `gorm:"type:varchar(20);not null"`
+	TokenHash string     `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time `gorm:"null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
 type User struct {
 	ID            uint      `gorm:"primaryKey;autoIncrement"`
 	Uid           string    `json:"uid" gorm:"uniqueIndex"` // Unique user identifier
@@ -48,24 +62,33 @@ type User struct {
 }
 
 type Apartment struct {
-	ID             uint       `gorm:"primaryKey"`
-	Uid            string     `gorm:"not null"`                           // Landlord's UID
-	PropertyName   string     `gorm:"not null;index:idx_property_search"` // Included in search index
-	Address        string     `gorm:"not null;index:idx_property_search"` // Included in search index
-	PropertyType   string     `gorm:"not null"`
-	RentPrice      float64    `gorm:"not null"`
-	LocationLink   string     `gorm:"not null"`
-	Landmarks      string     `gorm:"not null"`
-	Message        string     `gorm:"not null;default:'Apartment Application'" json:"message"`
-	Status         string     `gorm:"not null;default:'Pending';index:idx_status_availability"`
-	Latitude       float64    `gorm:"null;index:idx_geo"`
-	Longitude      float64    `gorm:"null;index:idx_geo"`
-	Allowed_Gender string     `gorm:"not null"`
-	Availability   string     `gorm:"null;index:idx_status_availability;index:idx_availability_expires"`
-	UserID         string     `gorm:"not null"`
-	CreatedAt      time.Time  `json:"created_at"`
-	ExpiresAt      *time.Time `gorm:"null;index:idx_availability_expires"`
-	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	ID              uint       `gorm:"primaryKey"`
+	Uid             string     `gorm:"not null"`                           // Landlord's UID
+	PropertyName    string     `gorm:"not null;index:idx_property_search"` // Included in search index
+	Address         string     `gorm:"not null;index:idx_property_search"` // Included in search index
+	PropertyType    string     `gorm:"not null"`
+	RentPrice       float64    `gorm:"not null"`
+	LocationLink    string     `gorm:"not null"`
+	Landmarks       string     `gorm:"not null"`
+	Message         string     `gorm:"not null;default:'Apartment Application'" json:"message"`
+	Status          string     `gorm:"not null;default:'Pending';index:idx_status_availability"`
+	Latitude        float64    `gorm:"null;index:idx_geo"`
+	Longitude       float64    `gorm:"null;index:idx_geo"`
+	Allowed_Gender  string     `gorm:"not null"`
+	Availability    string     `gorm:"null;index:idx_status_availability;index:idx_availability_expires"`
+	UserID          string     `gorm:"not null"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       *time.Time `gorm:"null;index:idx_availability_expires"`
+	FeaturedUntil   *time.Time `gorm:"null;index" json:"featured_until"` // set while a paid promotion is active
+	SubmittedAt     *time.Time `gorm:"null" json:"submitted_at"`         // last time the listing was sent for review
+	ReviewClaimedBy *string    `gorm:"null" json:"review_claimed_by"`    // admin reviewing it, see moderation.Claim
+	ReviewClaimedAt *time.Time `gorm:"null" json:"review_claimed_at"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// IsFeatured reports whether a paid promotion is currently boosting the apartment.
+func (a Apartment) IsFeatured() bool {
+	return a.FeaturedUntil != nil && a.FeaturedUntil.After(time.Now())
 }
 
 type LandlordProfile struct {
@@ -82,6 +105,33 @@ type LandlordProfile struct {
 	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
+// ApartmentDetails is the canonical listing response: the apartment with its
+// landlord, media, amenities, house rules, inquiry count, the price range and
+// availability of its units and the next date it can be moved into. Build it
+// with repository.LoadApartmentDetails.
+type ApartmentDetails struct {
+	Apartment
+	LandlordName      string     `json:"landlord_name"`
+	LandlordEmail     string     `json:"landlord_email"`
+	LandlordPhone     string     `json:"landlord_phone"`
+	LandlordAddress   string     `json:"landlord_address"`
+	LandlordValidID   string     `json:"landlord_valid_id"`
+	LandlordPhotoURL  string     `json:"landlord_photo_url"`
+	LandlordUserType  string     `json:"landlord_user_type"`
+	LandlordStatus    string     `json:"landlord_account_status"`
+	Images            []string   `json:"images"`
+	Videos            []string   `json:"videos"`
+	Amenities         []string   `json:"amenities"`
+	HouseRules        []string   `json:"house_rules"`
+	InquiriesCount    int64      `json:"inquiries_count"`
+	IsFeatured        bool       `json:"is_featured"`
+	PriceMin          float64    `json:"price_min"`           // lowest unit rent, or RentPrice without units
+	PriceMax          float64    `json:"price_max"`           // highest unit rent, or RentPrice without units
+	UnitsTotal        int        `json:"units_total"`         // an apartment without units counts as one
+	UnitsAvailable    int        `json:"units_available"`     // units open to new tenants
+	NextAvailableDate *time.Time `json:"next_available_date"` // first open day on the calendar, nil when none
+}
+
 // Apartment images
 type ApartmentImage struct {
 	ID          uint      `gorm:"primaryKey"`
@@ -103,6 +153,8 @@ type Inquiry struct {
 	TenantUID      string `gorm:"not null"`
 	LandlordUID    string `gorm:"not null"`
 	PropertyID     uint   `gorm:"not null"`
+	UnitID         *uint  `gorm:"index"`                                           // set when the inquiry is about one unit
+	TenantGender   string `gorm:"type:varchar(20)" json:"tenant_gender,omitempty"` // stated for a Male or Female only unit
 	Status         string `gorm:"not null;default:'Active'" json:"status"`
 	InitialMessage string `gorm:"null"`
 	PreferredVisit *time.Time
@@ -175,6 +227,7 @@ type RentalAgreement struct {
 	ID                uint       `gorm:"primaryKey"`
 	ApartmentID       uint       `gorm:"not null"`
 	Apartment         Apartment  `gorm:"foreignKey:ApartmentID"`
+	UnitID            *uint      `gorm:"index"` // the unit rented, nil for a whole apartment
 	Status            string     `gorm:"null"`
 	TenantID          string     `gorm:"not null"` // Using UID to match your User model
 	Tenant            User       `gorm:"foreignKey:TenantID;references:Uid"`
@@ -206,16 +259,263 @@ type Rating struct {
 
 //Gcash Payment model
 type Transaction struct {
-	ID                uint      `gorm:"primaryKey"`
-	UserID            string    `gorm:"type:varchar(50);not null"`
-	BaseAmount        float64   `gorm:"type:decimal(10,2);not null"`
-	InterestAmount    float64   `gorm:"type:decimal(10,2);not null"`
-	TotalAmount       float64   `gorm:"type:decimal(10,2);not null"`
-	PayMongoSourceID  string    `gorm:"type:varchar(50)"`
-	PayMongoPaymentID string    `gorm:"type:varchar(50)"`
-	Status            string    `gorm:"type:varchar(20);not null"`
-	CreatedAt         time.Time `gorm:"autoCreateTime"`
-	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
-Availment string `gorm:"column:availment" json:"availment"`
-
-}
\ No newline at end of file
+	ID                      uint      `gorm:"primaryKey"`
+	UserID                  string    `gorm:"type:varchar(50);not null"`
+	ApartmentID             *uint     `gorm:"index"` // apartment the payment is for, if any
+	InvoiceID               *uint     `gorm:"index"` // rent invoice the payment settles, if any
+	BaseAmount              float64   `gorm:"type:decimal(10,2);not null"`
+	InterestAmount          float64   `gorm:"type:decimal(10,2);not null"`
+	TotalAmount             float64   `gorm:"type:decimal(10,2);not null"`
+	PayMongoSourceID        string    `gorm:"type:varchar(50)"`
+	PayMongoPaymentID       string    `gorm:"type:varchar(50)"`
+	PayMongoPaymentIntentID string    `gorm:"type:varchar(50);index"`                  // set for card / Maya payments
+	PaymentMethod           string    `gorm:"type:varchar(20);not null;default:gcash"` // gcash, grab_pay, paymaya, card
+	Status                  string    `gorm:"type:varchar(20);not null"`               // pending, awaiting_next_action, processing, paid, failed, expired, refund_pending, partially_refunded, refunded
+	RefundedAmount          float64   `gorm:"type:decimal(10,2);not null;default:0"`   // sum of succeeded refunds
+	CreatedAt               time.Time `gorm:"autoCreateTime"`
+	UpdatedAt               time.Time `gorm:"autoUpdateTime"`
+	Availment               string    `gorm:"column:availment" json:"availment"`
+}
+
+// Refund is a full or partial refund issued by an admin against a paid
+// Transaction. It is saved as requested before the provider is called, so
+// PayMongoRefundID stays nil until the provider accepts it; after that Status
+// follows the provider: pending, succeeded or failed.
+type Refund struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	TransactionID    uint      `gorm:"not null;index" json:"transaction_id"`
+	PayMongoRefundID *string   `gorm:"type:varchar(50);uniqueIndex" json:"paymongo_refund_id"`
+	Amount           float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Reason           string    `gorm:"type:varchar(50);not null" json:"reason"`
+	Notes            string    `gorm:"type:text" json:"notes"`
+	Status           string    `gorm:"type:varchar(20);not null" json:"status"`
+	RequestedBy      string    `gorm:"type:varchar(50);not null" json:"requested_by"` // admin UID
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Invoice is one month of rent billed against a confirmed RentalAgreement.
+// Status moves unpaid -> overdue (late fee added) -> paid; TransactionID
+// points at the latest checkout started for it, or the one that paid it.
+type Invoice struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	RentalAgreementID uint       `gorm:"not null;uniqueIndex:idx_invoice_agreement_period" json:"rental_agreement_id"`
+	ApartmentID       uint       `gorm:"not null;index" json:"apartment_id"`
+	TenantID          string     `gorm:"type:varchar(50);not null;index" json:"tenant_id"`
+	LandlordID        string     `gorm:"type:varchar(50);not null;index" json:"landlord_id"`
+	PeriodStart       time.Time  `gorm:"type:date;not null;uniqueIndex:idx_invoice_agreement_period" json:"period_start"`
+	PeriodEnd         time.Time  `gorm:"type:date;not null" json:"period_end"`
+	RentAmount        float64    `gorm:"type:decimal(10,2);not null" json:"rent_amount"`
+	LateFee           float64    `gorm:"type:decimal(10,2);not null;default:0" json:"late_fee"`
+	TotalDue          float64    `gorm:"type:decimal(10,2);not null" json:"total_due"`
+	DueDate           time.Time  `gorm:"not null;index" json:"due_date"`
+	Status            string     `gorm:"type:varchar(20);not null;default:'unpaid';index" json:"status"` // unpaid, overdue, paid
+	TransactionID     *uint      `gorm:"index" json:"transaction_id"`
+	PaidAt            *time.Time `json:"paid_at"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ReconciliationLog records what the pending-transaction reconciler found
+// for a transaction and how it resolved it. Discrepancy is set when local and
+// provider state disagree in a way that needs an admin to look at it.
+type ReconciliationLog struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	TransactionID  uint      `gorm:"not null;index" json:"transaction_id"`
+	CheckoutID     string    `gorm:"type:varchar(50)" json:"checkout_id"`
+	LocalStatus    string    `gorm:"type:varchar(20)" json:"local_status"`
+	ProviderStatus string    `gorm:"type:varchar(30)" json:"provider_status"`
+	Resolution     string    `gorm:"type:varchar(20);not null;index" json:"resolution"` // paid, failed, expired, flagged, error
+	Discrepancy    string    `gorm:"type:text" json:"discrepancy,omitempty"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// ApartmentPromotion is a featured-listing package bought by a landlord.
+// It stays pending until its Transaction is paid, is active until EndsAt and
+// then expires.
+type ApartmentPromotion struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ApartmentID   uint       `gorm:"not null;index" json:"apartment_id"`
+	LandlordID    string     `gorm:"type:varchar(50);not null;index" json:"landlord_id"`
+	Package       string     `gorm:"type:varchar(20);not null" json:"package"` // featured_7, featured_30
+	Days          int        `gorm:"not null" json:"days"`
+	Price         float64    `gorm:"type:decimal(10,2);not null" json:"price"`
+	TransactionID uint       `gorm:"not null;uniqueIndex" json:"transaction_id"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"` // pending, active, expired, cancelled
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `gorm:"index" json:"ends_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// LedgerJournal groups the balanced entries posted for one business event
+// (a payment, a refund or a payout). JournalKey makes posting idempotent.
+type LedgerJournal struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	JournalKey    string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"journal_key"`
+	Kind          string    `gorm:"type:varchar(20);not null;index" json:"kind"` // payment, refund, payout
+	LandlordID    string    `gorm:"type:varchar(50);index" json:"landlord_id"`
+	TransactionID *uint     `gorm:"index" json:"transaction_id"`
+	RefundID      *uint     `json:"refund_id"`
+	PayoutID      *uint     `json:"payout_id"`
+	Memo          string    `json:"memo"`
+	CreatedAt     time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// LedgerEntry is one debit or credit line of a LedgerJournal. Accounts are
+// platform_cash, platform_fee_revenue and landlord_payable (per LandlordID).
+type LedgerEntry struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	JournalID  uint      `gorm:"not null;index" json:"journal_id"`
+	Account    string    `gorm:"type:varchar(30);not null;index:idx_ledger_account_landlord" json:"account"`
+	LandlordID string    `gorm:"type:varchar(50);index:idx_ledger_account_landlord" json:"landlord_id,omitempty"`
+	Debit      float64   `gorm:"type:decimal(12,2);not null;default:0" json:"debit"`
+	Credit     float64   `gorm:"type:decimal(12,2);not null;default:0" json:"credit"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// Payout records money the platform sent to a landlord outside the system.
+type Payout struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	LandlordID string    `gorm:"type:varchar(50);not null;index" json:"landlord_id"`
+	Amount     float64   `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Method     string    `gorm:"type:varchar(30);not null" json:"method"` // e.g. bank_transfer, gcash
+	Reference  string    `gorm:"type:varchar(100)" json:"reference"`
+	Notes      string    `gorm:"type:text" json:"notes"`
+	RecordedBy string    `gorm:"type:varchar(50);not null" json:"recorded_by"` // admin UID
+	PaidAt     time.Time `gorm:"not null" json:"paid_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// WebhookEvent records PayMongo event IDs that have been processed so that
+// duplicate deliveries of the same event are acknowledged without re-running.
+type WebhookEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	EventID   string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	EventType string    `gorm:"type:varchar(50);not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// SavedSearch is a tenant's named listing search. Filters holds the
+// filter-apartments query parameters, URL-encoded. When Latitude, Longitude
+// and RadiusKm are set, only listings within the radius match.
+type SavedSearch struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	TenantUID    string     `gorm:"type:varchar(50);not null;index" json:"tenant_uid"`
+	Name         string     `gorm:"type:varchar(100);not null" json:"name"`
+	Filters      string     `gorm:"type:text;not null;default:''" json:"-"`
+	Latitude     *float64   `json:"latitude"`
+	Longitude    *float64   `json:"longitude"`
+	RadiusKm     *float64   `json:"radius_km"`
+	Frequency    string     `gorm:"type:varchar(10);not null;default:'instant'" json:"frequency"` // instant, daily
+	Paused       bool       `gorm:"not null;default:false;index" json:"paused"`
+	LastDigestAt *time.Time `json:"last_digest_at"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// SavedSearchMatch records a listing that matched a saved search, so a
+// listing alerts each search only once and the daily digest knows what is
+// new. NotifiedAt is set once the tenant has been told.
+type SavedSearchMatch struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	SavedSearchID uint       `gorm:"not null;uniqueIndex:idx_saved_search_match" json:"saved_search_id"`
+	ApartmentID   uint       `gorm:"not null;uniqueIndex:idx_saved_search_match" json:"apartment_id"`
+	NotifiedAt    *time.Time `gorm:"index" json:"notified_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ApartmentPriceHistory records each rent an apartment has been listed at.
+// OldPrice is nil for the rent a listing started with.
+type ApartmentPriceHistory struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ApartmentID uint      `gorm:"not null;index:idx_price_history_apartment" json:"apartment_id"`
+	OldPrice    *float64  `json:"old_price"`
+	NewPrice    float64   `gorm:"not null" json:"new_price"`
+	ChangedBy   string    `gorm:"type:varchar(50)" json:"changed_by"` // landlord or admin UID, empty when backfilled
+	ChangedAt   time.Time `gorm:"not null;index:idx_price_history_apartment" json:"changed_at"`
+}
+
+// ModerationEvent records one status change of a listing: who made it, when,
+// and for rejections a ReasonCode from moderation.ReasonCodes with an
+// optional Note. FromStatus is empty for a new listing. There is deliberately
+// no foreign key to apartments: the "Deleted" event must survive the delete.
+type ModerationEvent struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ApartmentID uint      `gorm:"not null;index" json:"apartment_id"`
+	FromStatus  string    `gorm:"type:varchar(20);not null;default:''" json:"from_status"`
+	ToStatus    string    `gorm:"type:varchar(20);not null" json:"to_status"`
+	ActorUID    string    `gorm:"type:varchar(50);not null" json:"actor_uid"`
+	ActorRole   string    `gorm:"type:varchar(20);not null" json:"actor_role"` // Admin, Landlord
+	ReasonCode  string    `gorm:"type:varchar(40)" json:"reason_code,omitempty"`
+	Note        string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// ApartmentRevision is a landlord's edit to an approved listing. Changes
+// holds the proposed values as JSON; the live listing stays as it is until an
+// admin approves the revision. A listing has at most one pending revision.
+type ApartmentRevision struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	ApartmentID  uint       `gorm:"not null;index" json:"apartment_id"`
+	LandlordUID  string     `gorm:"type:varchar(50);not null;index" json:"landlord_uid"`
+	Changes      string     `gorm:"type:jsonb;not null" json:"-"`
+	Status       string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"` // pending, approved, rejected, withdrawn
+	AutoApproved bool       `gorm:"not null;default:false" json:"auto_approved"`
+	ReviewedBy   string     `gorm:"type:varchar(50)" json:"reviewed_by,omitempty"`
+	ReviewNote   string     `gorm:"type:text" json:"review_note,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ApartmentUnit is a room, bedspace or other part of an apartment rented on
+// its own, with its own rent, capacity, gender policy and availability.
+// Apartments without units are rented whole at their RentPrice.
+type ApartmentUnit struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ApartmentID   uint      `gorm:"not null;uniqueIndex:idx_apartment_unit_label" json:"apartment_id"`
+	Label         string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_apartment_unit_label" json:"label"` // e.g. Room 3, Bed 2A
+	UnitType      string    `gorm:"type:varchar(20);not null;default:'room'" json:"unit_type"`                   // room, bedspace, studio
+	RentPrice     float64   `gorm:"type:decimal(10,2);not null" json:"rent_price"`
+	Capacity      int       `gorm:"not null;default:1" json:"capacity"`
+	AllowedGender string    `gorm:"type:varchar(20)" json:"allowed_gender"` // empty follows the apartment
+	Availability  string    `gorm:"type:varchar(20);not null;default:'Available';index" json:"availability"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	Apartment     Apartment `gorm:"foreignKey:ApartmentID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// AvailabilityWindow is a dated stretch of a listing's calendar, for the
+// whole apartment or one unit. "available" windows open dates to tenants and
+// "blocked" windows close them again, blocked winning where they overlap.
+// A nil EndDate runs indefinitely. Apartments without windows fall back to
+// their Availability column.
+type AvailabilityWindow struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ApartmentID uint       `gorm:"not null;index:idx_availability_window_dates" json:"apartment_id"`
+	UnitID      *uint      `gorm:"index" json:"unit_id"`
+	Kind        string     `gorm:"type:varchar(20);not null;default:'available'" json:"kind"` // available, blocked
+	StartDate   time.Time  `gorm:"type:date;not null;index:idx_availability_window_dates" json:"start_date"`
+	EndDate     *time.Time `gorm:"type:date" json:"end_date"`
+	Note        string     `gorm:"type:varchar(255)" json:"note,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	Apartment   Apartment  `gorm:"foreignKey:ApartmentID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// MediaAsset is a file uploaded through /media/uploads and stored in
+// Cloudinary. It belongs to the uploader and is usable once: AttachedAt is
+// set when a listing or landlord registration takes it.
+type MediaAsset struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	OwnerUID    string     `gorm:"type:varchar(50);not null;index" json:"owner_uid"`
+	Purpose     string     `gorm:"type:varchar(30);not null" json:"purpose"` // listing_image, listing_video, valid_id, business_permit
+	ContentType string     `gorm:"type:varchar(50);not null" json:"content_type"`
+	SizeBytes   int64      `gorm:"not null" json:"size_bytes"`
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	URL         string     `gorm:"type:text;not null" json:"url"`
+	PublicID    string     `gorm:"type:varchar(255)" json:"public_id"`
+	AttachedAt  *time.Time `json:"attached_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,190 @@
+// Package moderation moves listings through review (Pending or Resubmitted,
+// then Approved or Rejected) and records every status change as a
+// model.ModerationEvent. Admins claim a listing before reviewing it so two
+// admins don't decide on the same one.
+package moderation
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending     = "Pending"
+	StatusApproved    = "Approved"
+	StatusRejected    = "Rejected"
+	StatusResubmitted = "Resubmitted"
+	StatusDeleted     = "Deleted" // only recorded; the listing row is gone
+
+	RoleAdmin    = "Admin"
+	RoleLandlord = "Landlord"
+
+	// ClaimTTL is how long a claim holds. Older claims are treated as
+	// abandoned and can be taken over.
+	ClaimTTL = 30 * time.Minute
+)
+
+// InReview lists the statuses that wait in the review queue.
+var InReview = []string{StatusPending, StatusResubmitted}
+
+// ReasonCodes maps each rejection reason code to the text the landlord sees.
+var ReasonCodes = map[string]string{
+	"incomplete_details": "Listing details are incomplete",
+	"unclear_photos":     "Photos are missing, unclear or do not show the property",
+	"price_mismatch":     "Rent price looks wrong or does not match the description",
+	"wrong_location":     "Address or map location is wrong",
+	"prohibited_content": "Listing contains prohibited content",
+	"duplicate":          "Listing duplicates another one",
+	"other":              "Other",
+}
+
+// transitions lists, per target status, the statuses it can be reached from.
+var transitions = map[string][]string{
+	StatusApproved:    {StatusPending, StatusResubmitted, StatusRejected},
+	StatusRejected:    {StatusPending, StatusResubmitted, StatusApproved},
+	StatusResubmitted: {StatusRejected},
+}
+
+var (
+	ErrInvalidTransition = errors.New("listing cannot move to that status from its current one")
+	ErrReasonRequired    = errors.New("reason_code must be one of the rejection reasons")
+	ErrNoteRequired      = errors.New("a note is required when the reason is other")
+	ErrClaimedByOther    = errors.New("listing is being reviewed by another admin")
+	ErrStatusChanged     = errors.New("listing changed while it was being updated, reload and try again")
+)
+
+// Actor is who made a change.
+type Actor struct {
+	UID  string
+	Role string
+}
+
+// CanTransition reports whether a listing may move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, allowed := range transitions[to] {
+		if allowed == from {
+			return true
+		}
+	}
+	return false
+}
+
+// RejectionMessage is the text stored in Apartment.Message for a rejection.
+func RejectionMessage(reasonCode, note string) string {
+	if note == "" {
+		return ReasonCodes[reasonCode]
+	}
+	if reasonCode == "other" {
+		return note
+	}
+	return ReasonCodes[reasonCode] + ": " + note
+}
+
+// Record logs a status change without touching the apartment. Use it for
+// changes made elsewhere, such as creating or deleting a listing. Events
+// have no foreign key to apartments, so they outlive a deleted listing.
+func Record(tx *gorm.DB, apartmentID uint, from, to string, actor Actor, reasonCode, note string) error {
+	return tx.Create(&model.ModerationEvent{
+		ApartmentID: apartmentID,
+		FromStatus:  from,
+		ToStatus:    to,
+		ActorUID:    actor.UID,
+		ActorRole:   actor.Role,
+		ReasonCode:  reasonCode,
+		Note:        note,
+	}).Error
+}
+
+// Transition moves apt to status to, along with any extra column updates,
+// and records the event. Rejections need a reasonCode from ReasonCodes.
+// Admins cannot decide on a listing another admin has a live claim on. The
+// claim is released either way. apt is updated in place.
+func Transition(tx *gorm.DB, apt *model.Apartment, to string, actor Actor, reasonCode, note string, extra map[string]interface{}) error {
+	from := apt.Status
+	if !CanTransition(from, to) {
+		return ErrInvalidTransition
+	}
+
+	updates := map[string]interface{}{
+		"status":            to,
+		"review_claimed_by": nil,
+		"review_claimed_at": nil,
+	}
+	switch to {
+	case StatusRejected:
+		if _, ok := ReasonCodes[reasonCode]; !ok {
+			return ErrReasonRequired
+		}
+		if reasonCode == "other" && note == "" {
+			return ErrNoteRequired
+		}
+		updates["message"] = RejectionMessage(reasonCode, note)
+	case StatusResubmitted:
+		updates["submitted_at"] = time.Now()
+		reasonCode = ""
+	default:
+		reasonCode = ""
+	}
+	for column, value := range extra {
+		updates[column] = value
+	}
+
+	// Guard on the status read so concurrent decisions can't both win
+	query := tx.Model(&model.Apartment{}).Where("id = ? AND status = ?", apt.ID, from)
+	if actor.Role == RoleAdmin {
+		query = query.Where("(review_claimed_by IS NULL OR review_claimed_by = ? OR review_claimed_at < ?)",
+			actor.UID, time.Now().Add(-ClaimTTL))
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if actor.Role == RoleAdmin && apt.ReviewClaimedBy != nil && *apt.ReviewClaimedBy != actor.UID {
+			return ErrClaimedByOther
+		}
+		return ErrStatusChanged
+	}
+
+	if err := Record(tx, apt.ID, from, to, actor, reasonCode, note); err != nil {
+		return err
+	}
+	return tx.First(apt, apt.ID).Error
+}
+
+// Claim reserves a listing in the review queue for adminUID. It succeeds if
+// the listing is unclaimed, already claimed by adminUID or its claim has
+// expired, and refreshes the claim time.
+func Claim(db *gorm.DB, apartmentID uint, adminUID string) (bool, error) {
+	now := time.Now()
+	result := db.Model(&model.Apartment{}).
+		Where("id = ? AND status IN ?", apartmentID, InReview).
+		Where("(review_claimed_by IS NULL OR review_claimed_by = ? OR review_claimed_at < ?)", adminUID, now.Add(-ClaimTTL)).
+		Updates(map[string]interface{}{
+			"review_claimed_by": adminUID,
+			"review_claimed_at": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Unclaim releases adminUID's claim on a listing.
+func Unclaim(db *gorm.DB, apartmentID uint, adminUID string) (bool, error) {
+	result := db.Model(&model.Apartment{}).
+		Where("id = ? AND review_claimed_by = ?", apartmentID, adminUID).
+		Updates(map[string]interface{}{
+			"review_claimed_by": nil,
+			"review_claimed_at": nil,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// History returns the status changes of a listing, oldest first.
+func History(db *gorm.DB, apartmentID uint) ([]model.ModerationEvent, error) {
+	events := []model.ModerationEvent{}
+	err := db.Where("apartment_id = ?", apartmentID).Order("created_at, id").Find(&events).Error
+	return events, err
+}
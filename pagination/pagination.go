@@ -0,0 +1,277 @@
+// Package pagination implements the cursor contract shared by the list
+// endpoints: ?limit= (default 20, max 100), ?sort= from a per-endpoint
+// whitelist, ?order=asc|desc and an opaque ?cursor= taken from the
+// previous page's next_cursor.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Column types a sort value can be compared as.
+const (
+	TypeNumber = "numeric"
+	TypeBigint = "bigint"
+	TypeTime   = "timestamptz"
+	TypeText   = "text"
+)
+
+var ErrInvalidCursor = errors.New("cursor is invalid or does not match the requested sort")
+
+// Field is one sortable column. Column is an SQL expression and may take Args.
+type Field struct {
+	Column string
+	Args   []interface{}
+	Type   string
+	Desc   bool // direction used when ?order= is omitted
+}
+
+// Sorts is the whitelist of ?sort= values an endpoint accepts.
+type Sorts struct {
+	Fields  map[string]Field
+	Default string
+	ID      Field  // unique tie-breaker, e.g. apartments.id
+	Pinned  string // optional boolean SQL expression; matching rows always come first
+}
+
+// Key holds the sort values of a row, as added by Params.Select. Embed it in
+// the struct the page is scanned into.
+type Key struct {
+	SortValue  string `gorm:"column:sort_value" json:"-"`
+	SortID     string `gorm:"column:sort_id" json:"-"`
+	SortPinned bool   `gorm:"column:sort_pinned" json:"-"`
+}
+
+// Page describes the returned page and how to fetch the next one.
+type Page struct {
+	Limit      int     `json:"limit"`
+	Sort       string  `json:"sort"`
+	Order      string  `json:"order"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type cursor struct {
+	Sort   string `json:"s"`
+	Order  string `json:"o"`
+	Value  string `json:"v"`
+	ID     string `json:"i"`
+	Pinned bool   `json:"p,omitempty"`
+}
+
+// Params is a parsed page request.
+type Params struct {
+	Limit int
+	Sort  string
+	Order string
+	sorts Sorts
+	field Field
+	after *cursor
+}
+
+// Parse reads ?limit=, ?sort=, ?order= and ?cursor= against sorts.
+func Parse(c *fiber.Ctx, sorts Sorts) (Params, error) {
+	p := Params{Limit: c.QueryInt("limit", DefaultLimit), Sort: c.Query("sort", sorts.Default), sorts: sorts}
+	if p.Limit < 1 {
+		p.Limit = DefaultLimit
+	}
+	if p.Limit > MaxLimit {
+		p.Limit = MaxLimit
+	}
+
+	field, ok := sorts.Fields[p.Sort]
+	if !ok {
+		allowed := make([]string, 0, len(sorts.Fields))
+		for name := range sorts.Fields {
+			allowed = append(allowed, name)
+		}
+		sort.Strings(allowed)
+		return p, fmt.Errorf("sort must be one of: %s", strings.Join(allowed, ", "))
+	}
+	p.field = field
+
+	switch order := strings.ToLower(c.Query("order")); order {
+	case "":
+		p.Order = "asc"
+		if field.Desc {
+			p.Order = "desc"
+		}
+	case "asc", "desc":
+		p.Order = order
+	default:
+		return p, errors.New("order must be asc or desc")
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		after, err := decode(raw)
+		if err != nil || after.Sort != p.Sort || after.Order != p.Order ||
+			!validValue(field.Type, after.Value) || !validValue(sorts.ID.Type, after.ID) {
+			return p, ErrInvalidCursor
+		}
+		p.after = after
+	}
+	return p, nil
+}
+
+// Desc reports whether the page is sorted in descending order.
+func (p Params) Desc() bool {
+	return p.Order == "desc"
+}
+
+// After returns the sort value and id of the last row of the previous page,
+// for callers that page a store other than Postgres.
+func (p Params) After() (value, id string, ok bool) {
+	if p.after == nil {
+		return "", "", false
+	}
+	return p.after.Value, p.after.ID, true
+}
+
+// Select selects columns, which take args, plus the sort values Key is
+// scanned from.
+func (p Params) Select(db *gorm.DB, columns string, args ...interface{}) *gorm.DB {
+	columns += ", " + valueSQL(p.field) + " AS sort_value, " + valueSQL(p.sorts.ID) + " AS sort_id"
+	if p.sorts.Pinned != "" {
+		columns += ", (" + p.sorts.Pinned + ") AS sort_pinned"
+	}
+	args = append(append(append([]interface{}{}, args...), p.field.Args...), p.sorts.ID.Args...)
+	return db.Select(columns, args...)
+}
+
+// Apply orders the query, skips past the cursor and limits it to one row
+// more than the page so Finish can tell whether another page exists.
+func (p Params) Apply(db *gorm.DB) *gorm.DB {
+	dir, op := "ASC", ">"
+	if p.Desc() {
+		dir, op = "DESC", "<"
+	}
+
+	var columns, orders, placeholders []string
+	var args, vars []interface{}
+	if p.sorts.Pinned != "" {
+		columns = append(columns, p.pinnedSQL())
+		orders = append(orders, p.pinnedSQL()+" "+dir)
+		if p.after != nil {
+			placeholders = append(placeholders, "?")
+			vars = append(vars, p.pinnedRank(p.after.Pinned))
+		}
+	}
+	for _, f := range []Field{p.field, p.sorts.ID} {
+		columns = append(columns, columnSQL(f))
+		orders = append(orders, columnSQL(f)+" "+dir)
+		args = append(args, f.Args...)
+		placeholders = append(placeholders, "CAST(? AS "+f.Type+")")
+	}
+
+	if p.after != nil {
+		vars = append(vars, p.after.Value, p.after.ID)
+		db = db.Where("("+strings.Join(columns, ", ")+") "+op+" ("+strings.Join(placeholders, ", ")+")", append(args, vars...)...)
+	}
+	return db.
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(orders, ", "), Vars: args, WithoutParentheses: true}}).
+		Limit(p.Limit + 1)
+}
+
+// Finish trims rows fetched with Apply to the page size and builds the page
+// description, including next_cursor when more rows follow.
+func Finish[T any](p Params, rows []T, key func(T) Key) ([]T, Page) {
+	page := Page{Limit: p.Limit, Sort: p.Sort, Order: p.Order}
+	if len(rows) <= p.Limit {
+		return rows, page
+	}
+	rows = rows[:p.Limit]
+	last := key(rows[len(rows)-1])
+	next := encode(cursor{Sort: p.Sort, Order: p.Order, Value: last.SortValue, ID: last.SortID, Pinned: last.SortPinned})
+	page.NextCursor = &next
+	return rows, page
+}
+
+// pinnedSQL ranks pinned rows so they come first in either direction.
+func (p Params) pinnedSQL() string {
+	if p.Desc() {
+		return "(CASE WHEN (" + p.sorts.Pinned + ") THEN 1 ELSE 0 END)"
+	}
+	return "(CASE WHEN (" + p.sorts.Pinned + ") THEN 0 ELSE 1 END)"
+}
+
+func (p Params) pinnedRank(pinned bool) int {
+	if pinned == p.Desc() {
+		return 1
+	}
+	return 0
+}
+
+// nullValues stand in for NULL sort values, which would otherwise make the
+// cursor comparison unknown and drop those rows from every later page.
+var nullValues = map[string]string{
+	TypeNumber: "0",
+	TypeBigint: "0",
+	TypeTime:   "'epoch'::timestamptz",
+	TypeText:   "''",
+}
+
+// columnSQL is the sort column with NULLs replaced by its type's null value.
+func columnSQL(f Field) string {
+	null, ok := nullValues[f.Type]
+	if !ok {
+		return f.Column
+	}
+	return "COALESCE((" + f.Column + "), " + null + ")"
+}
+
+// valueSQL renders a sort column as text that casts back to the same value.
+// Timestamps are written in UTC RFC 3339 so they do not depend on the
+// session time zone.
+func valueSQL(f Field) string {
+	if f.Type == TypeTime {
+		return `to_char(` + columnSQL(f) + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`
+	}
+	return "CAST(" + columnSQL(f) + " AS text)"
+}
+
+func validValue(typ, value string) bool {
+	switch typ {
+	case TypeNumber:
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	case TypeBigint:
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case TypeTime:
+		_, err := time.Parse(time.RFC3339Nano, value)
+		return err == nil
+	}
+	return true
+}
+
+func encode(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decode(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
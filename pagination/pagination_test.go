@@ -0,0 +1,208 @@
+package pagination
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var testSorts = Sorts{
+	Fields: map[string]Field{
+		"created_at": {Column: "items.created_at", Type: TypeTime, Desc: true},
+		"price":      {Column: "items.price", Type: TypeNumber},
+		"score":      {Column: "items.score * ?", Args: []interface{}{10}, Type: TypeBigint, Desc: true},
+	},
+	Default: "created_at",
+	ID:      Field{Column: "items.id", Type: TypeBigint},
+	Pinned:  "items.featured",
+}
+
+// errAny marks a test case that expects some error, whatever it is.
+var errAny = errors.New("any error")
+
+// parse runs Parse on a request for /items?query.
+func parse(t *testing.T, query string) (Params, error) {
+	t.Helper()
+	var (
+		params Params
+		err    error
+	)
+	app := fiber.New()
+	app.Get("/items", func(c *fiber.Ctx) error {
+		params, err = Parse(c, testSorts)
+		return nil
+	})
+	resp, testErr := app.Test(httptest.NewRequest("GET", "/items?"+query, nil))
+	if testErr != nil {
+		t.Fatal(testErr)
+	}
+	resp.Body.Close()
+	return params, err
+}
+
+// dryRun returns a GORM handle that builds Postgres SQL without connecting.
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []cursor{
+		{Sort: "price", Order: "asc", Value: "4999.5", ID: "12"},
+		{Sort: "created_at", Order: "desc", Value: "2025-01-02T03:04:05.000006Z", ID: "7", Pinned: true},
+		{Sort: "score", Order: "desc", Value: "0", ID: "1"},
+	}
+	for _, want := range tests {
+		got, err := decode(encode(want))
+		if err != nil {
+			t.Fatalf("decode(encode(%+v)): %v", want, err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("round trip gave %+v, want %+v", *got, want)
+		}
+	}
+
+	for _, raw := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := decode(raw); err == nil {
+			t.Errorf("decode(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantSort  string
+		wantOrder string
+		wantErr   error // nil, ErrInvalidCursor or errAny
+	}{
+		{name: "defaults", query: "", wantLimit: DefaultLimit, wantSort: "created_at", wantOrder: "desc"},
+		{name: "ascending field", query: "sort=price", wantLimit: DefaultLimit, wantSort: "price", wantOrder: "asc"},
+		{name: "explicit order", query: "sort=price&order=DESC", wantLimit: DefaultLimit, wantSort: "price", wantOrder: "desc"},
+		{name: "limit clamped", query: "limit=1000", wantLimit: MaxLimit, wantSort: "created_at", wantOrder: "desc"},
+		{name: "limit below one", query: "limit=0", wantLimit: DefaultLimit, wantSort: "created_at", wantOrder: "desc"},
+		{name: "unknown sort", query: "sort=name", wantErr: errAny},
+		{name: "bad order", query: "order=up", wantErr: errAny},
+		{name: "cursor", query: "sort=price&cursor=" + encode(cursor{Sort: "price", Order: "asc", Value: "10", ID: "3"}),
+			wantLimit: DefaultLimit, wantSort: "price", wantOrder: "asc"},
+		{name: "cursor for another sort", query: "sort=price&cursor=" + encode(cursor{Sort: "score", Order: "asc", Value: "10", ID: "3"}),
+			wantErr: ErrInvalidCursor},
+		{name: "cursor for another order", query: "sort=price&order=desc&cursor=" + encode(cursor{Sort: "price", Order: "asc", Value: "10", ID: "3"}),
+			wantErr: ErrInvalidCursor},
+		{name: "cursor value of the wrong type", query: "cursor=" + encode(cursor{Sort: "created_at", Order: "desc", Value: "yesterday", ID: "3"}),
+			wantErr: ErrInvalidCursor},
+		{name: "cursor id of the wrong type", query: "sort=price&cursor=" + encode(cursor{Sort: "price", Order: "asc", Value: "10", ID: "x"}),
+			wantErr: ErrInvalidCursor},
+		{name: "garbage cursor", query: "cursor=%25%25", wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parse(t, tt.query)
+			switch {
+			case tt.wantErr == errAny:
+				if err == nil {
+					t.Fatal("got no error, want one")
+				}
+				return
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Limit != tt.wantLimit || p.Sort != tt.wantSort || p.Order != tt.wantOrder {
+				t.Errorf("got limit %d, sort %s, order %s; want %d, %s, %s",
+					p.Limit, p.Sort, p.Order, tt.wantLimit, tt.wantSort, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestGeneratedSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string // fragments the statement must contain, in order
+		vars  []interface{}
+	}{
+		{
+			name:  "first page",
+			query: "sort=price",
+			want: []string{
+				"CAST(COALESCE((items.price), 0) AS text) AS sort_value",
+				"CAST(COALESCE((items.id), 0) AS text) AS sort_id",
+				"(items.featured) AS sort_pinned",
+				"ORDER BY (CASE WHEN (items.featured) THEN 0 ELSE 1 END) ASC, COALESCE((items.price), 0) ASC, COALESCE((items.id), 0) ASC",
+				"LIMIT $1",
+			},
+			vars: []interface{}{DefaultLimit + 1},
+		},
+		{
+			name:  "timestamps in UTC",
+			query: "",
+			want: []string{
+				`to_char(COALESCE((items.created_at), 'epoch'::timestamptz) AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"') AS sort_value`,
+				"ORDER BY (CASE WHEN (items.featured) THEN 1 ELSE 0 END) DESC, COALESCE((items.created_at), 'epoch'::timestamptz) DESC",
+			},
+			vars: []interface{}{DefaultLimit + 1},
+		},
+		{
+			name:  "after a cursor",
+			query: "sort=price&limit=5&cursor=" + encode(cursor{Sort: "price", Order: "asc", Value: "10.5", ID: "3", Pinned: true}),
+			want: []string{
+				"WHERE ((CASE WHEN (items.featured) THEN 0 ELSE 1 END), COALESCE((items.price), 0), COALESCE((items.id), 0)) > ($1, CAST($2 AS numeric), CAST($3 AS bigint))",
+				"LIMIT $4",
+			},
+			vars: []interface{}{0, "10.5", "3", 6},
+		},
+		{
+			name:  "field args",
+			query: "sort=score&order=asc&cursor=" + encode(cursor{Sort: "score", Order: "asc", Value: "40", ID: "9"}),
+			want: []string{
+				"CAST(COALESCE((items.score * $1), 0) AS text) AS sort_value",
+				"WHERE ((CASE WHEN (items.featured) THEN 0 ELSE 1 END), COALESCE((items.score * $2), 0), COALESCE((items.id), 0)) > ($3, CAST($4 AS bigint), CAST($5 AS bigint))",
+				"ORDER BY (CASE WHEN (items.featured) THEN 0 ELSE 1 END) ASC, COALESCE((items.score * $6), 0) ASC",
+			},
+			vars: []interface{}{10, 10, 1, "40", "9", 10, DefaultLimit + 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parse(t, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rows []map[string]interface{}
+			stmt := p.Apply(p.Select(dryRun(t).Table("items"), "items.*")).Find(&rows).Statement
+			sql := stmt.SQL.String()
+
+			rest := sql
+			for _, fragment := range tt.want {
+				i := strings.Index(rest, fragment)
+				if i < 0 {
+					t.Fatalf("SQL is missing %q after the earlier fragments:\n%s", fragment, sql)
+				}
+				rest = rest[i+len(fragment):]
+			}
+			if !reflect.DeepEqual(stmt.Vars, tt.vars) {
+				t.Errorf("got vars %#v, want %#v", stmt.Vars, tt.vars)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds everything that used to be hardcoded in PayMongoService.
+type Config struct {
+	Provider      string // "paymongo" (default) or "fake" for offline development
+	BaseURL       string // PayMongo API base URL
+	PublicKey     string
+	SecretKey     string
+	WebhookSecret string
+
+	CallbackBaseURL string // public URL of this API, used to build redirect URLs
+	SuccessURL      string // where the provider sends the customer after paying
+	FailedURL       string
+	WebSuccessURL   string // browser fallback when the app deep link does not open
+	WebFailedURL    string
+	AppScheme       string // deep link scheme of the mobile app
+
+	Currency string
+	FeeRate  float64 // fraction added on top of the base amount, e.g. 0.002
+}
+
+// ConfigFromEnv builds a Config from PAYMENT_* and PAYMONGO_* variables.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Provider:        envOr("PAYMENT_PROVIDER", "paymongo"),
+		BaseURL:         strings.TrimRight(envOr("PAYMONGO_BASE_URL", "https://api.paymongo.com"), "/"),
+		PublicKey:       os.Getenv("PAYMONGO_PUBLIC_KEY"),
+		SecretKey:       os.Getenv("PAYMONGO_SECRET_KEY"),
+		WebhookSecret:   os.Getenv("PAYMONGO_WEBHOOK_SECRET"),
+		CallbackBaseURL: strings.TrimRight(envOr("PAYMENT_CALLBACK_BASE_URL", "http://localhost:8080"), "/"),
+		AppScheme:       envOr("PAYMENT_APP_SCHEME", "rentxpert"),
+		Currency:        envOr("PAYMENT_CURRENCY", "PHP"),
+		FeeRate:         0.002,
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("PAYMENT_FEE_RATE"), 64); err == nil && rate >= 0 {
+		cfg.FeeRate = rate
+	}
+
+	// The fake provider signs its own webhooks, so it needs no real secret
+	if cfg.Provider == "fake" && cfg.WebhookSecret == "" {
+		cfg.WebhookSecret = "whsk_fake_local"
+	}
+
+	cfg.SuccessURL = envOr("PAYMENT_SUCCESS_URL", cfg.CallbackBaseURL+"/success")
+	cfg.FailedURL = envOr("PAYMENT_FAILED_URL", cfg.CallbackBaseURL+"/failed")
+	cfg.WebSuccessURL = envOr("PAYMENT_WEB_SUCCESS_URL", cfg.SuccessURL)
+	cfg.WebFailedURL = envOr("PAYMENT_WEB_FAILED_URL", cfg.FailedURL)
+	return cfg
+}
+
+// ApplyFee returns the fee charged on top of base and the resulting total.
+func (c Config) ApplyFee(base float64) (fee, total float64) {
+	fee = math.Round(base*c.FeeRate*100) / 100
+	return fee, base + fee
+}
+
+// ToCentavos converts a peso amount to the integer centavos providers expect.
+func ToCentavos(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromCentavos converts provider centavos back to pesos.
+func FromCentavos(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,235 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeProvider is an in-memory PaymentProvider for tests and offline
+// development. Its checkout URL points at this API's /fake-checkout/:id
+// route, which authorizes the checkout, delivers the signed webhook PayMongo
+// would send (source.chargeable, payment.paid or payment.failed) and then
+// follows the normal success redirect.
+type FakeProvider struct {
+	cfg Config
+
+	mu        sync.Mutex
+	seq       int
+	checkouts map[string]*fakeCheckout
+	refunded  map[string]int64 // payment ID -> amount refunded so far
+}
+
+type fakeCheckout struct {
+	kind      string
+	status    CheckoutStatus
+	captured  int64
+	paymentID string
+}
+
+func NewFakeProvider(cfg Config) *FakeProvider {
+	return &FakeProvider{
+		cfg:       cfg,
+		checkouts: map[string]*fakeCheckout{},
+		refunded:  map[string]int64{},
+	}
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) nextID(prefix string) string {
+	p.seq++
+	return fmt.Sprintf("%s_fake_%d_%d", prefix, time.Now().Unix(), p.seq)
+}
+
+func (p *FakeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	kind, ok := MethodKind(req.Method)
+	if !ok {
+		return nil, fmt.Errorf("unsupported payment method %q", req.Method)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prefix, clientKey := "src", ""
+	if kind == KindPaymentIntent {
+		prefix = "pi"
+	}
+	id := p.nextID(prefix)
+	if kind == KindPaymentIntent {
+		clientKey = id + "_client_fake"
+	}
+
+	p.checkouts[id] = &fakeCheckout{kind: kind, status: CheckoutStatus{ID: id, Status: "pending", Amount: req.Amount}}
+	return &Checkout{
+		ID:          id,
+		Kind:        kind,
+		CheckoutURL: p.cfg.CallbackBaseURL + "/fake-checkout/" + id,
+		ClientKey:   clientKey,
+		Status:      "pending",
+	}, nil
+}
+
+func (p *FakeProvider) FetchStatus(ctx context.Context, checkoutID string) (*CheckoutStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	co, ok := p.checkouts[checkoutID]
+	if !ok {
+		return nil, fmt.Errorf("checkout %s not found", checkoutID)
+	}
+	status := co.status
+	return &status, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, checkoutID string, amount int64) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	co, ok := p.checkouts[checkoutID]
+	if !ok {
+		return "", fmt.Errorf("checkout %s not found", checkoutID)
+	}
+
+	switch co.status.Status {
+	case "paid":
+		return co.paymentID, nil
+	case "chargeable":
+		if co.kind == KindPaymentIntent {
+			break
+		}
+		co.paymentID = p.nextID("pay")
+		co.captured = amount
+		co.status.Status = "paid"
+		co.status.PaymentID = co.paymentID
+		return co.paymentID, nil
+	}
+	return "", fmt.Errorf("%w: %s %s is %s", ErrNotChargeable, co.kind, checkoutID, co.status.Status)
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, paymentID string, amount int64, reason string) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var captured int64
+	found := false
+	for _, co := range p.checkouts {
+		if co.paymentID == paymentID {
+			captured, found = co.captured, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("payment %s not found", paymentID)
+	}
+	if amount <= 0 || p.refunded[paymentID]+amount > captured {
+		return nil, fmt.Errorf("refund amount %d exceeds refundable balance", amount)
+	}
+
+	p.refunded[paymentID] += amount
+	return &Refund{ID: p.nextID("ref"), Status: "succeeded", Amount: amount}, nil
+}
+
+// Authorize simulates the customer approving the checkout. Sources become
+// chargeable; payment intents are captured immediately, like PayMongo does.
+func (p *FakeProvider) Authorize(checkoutID string) error {
+	if err := p.transition(checkoutID, "chargeable"); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	co := p.checkouts[checkoutID]
+	if co.kind == KindPaymentIntent {
+		co.paymentID = p.nextID("pay")
+		co.captured = co.status.Amount
+		co.status.Status = "paid"
+		co.status.PaymentID = co.paymentID
+	}
+	return nil
+}
+
+// Decline simulates the customer cancelling or the wallet rejecting payment.
+func (p *FakeProvider) Decline(checkoutID string) error {
+	return p.transition(checkoutID, "failed")
+}
+
+func (p *FakeProvider) transition(checkoutID, to string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	co, ok := p.checkouts[checkoutID]
+	if !ok {
+		return fmt.Errorf("checkout %s not found", checkoutID)
+	}
+	if co.status.Status != "pending" {
+		return fmt.Errorf("checkout %s is already %s", checkoutID, co.status.Status)
+	}
+	co.status.Status = to
+	return nil
+}
+
+// Event returns the webhook body PayMongo would send for the checkout's
+// current state: source.chargeable for an authorized source, payment.paid or
+// payment.failed for a payment intent.
+func (p *FakeProvider) Event(checkoutID string) ([]byte, error) {
+	p.mu.Lock()
+	co, ok := p.checkouts[checkoutID]
+	var snapshot fakeCheckout
+	if ok {
+		snapshot = *co
+	}
+	eventID := p.nextID("evt")
+	p.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("checkout %s not found", checkoutID)
+	}
+
+	var eventType string
+	var resource map[string]interface{}
+	switch {
+	case snapshot.kind == KindSource && snapshot.status.Status == "chargeable":
+		eventType = "source.chargeable"
+		resource = map[string]interface{}{
+			"id":   checkoutID,
+			"type": "source",
+			"attributes": map[string]interface{}{
+				"amount": snapshot.status.Amount,
+				"status": "chargeable",
+			},
+		}
+	case snapshot.kind == KindPaymentIntent && (snapshot.status.Status == "paid" || snapshot.status.Status == "failed"):
+		eventType = "payment." + snapshot.status.Status
+		resource = map[string]interface{}{
+			"id":   snapshot.paymentID,
+			"type": "payment",
+			"attributes": map[string]interface{}{
+				"amount":            snapshot.status.Amount,
+				"status":            snapshot.status.Status,
+				"payment_intent_id": checkoutID,
+			},
+		}
+	default:
+		return nil, fmt.Errorf("checkout %s (%s) has no event to send", checkoutID, snapshot.status.Status)
+	}
+
+	event := map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   eventID,
+			"type": "event",
+			"attributes": map[string]interface{}{
+				"type":     eventType,
+				"livemode": false,
+				"data":     resource,
+			},
+		},
+	}
+	return json.Marshal(event)
+}
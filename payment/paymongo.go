@@ -0,0 +1,413 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PayMongoProvider talks to the PayMongo REST API. E-wallet checkouts are
+// PayMongo Sources and capturing one creates a Payment from it; card and Maya
+// checkouts are Payment Intents, which PayMongo captures on its own.
+type PayMongoProvider struct {
+	cfg    Config
+	client *http.Client
+}
+
+func NewPayMongoProvider(cfg Config) *PayMongoProvider {
+	return &PayMongoProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *PayMongoProvider) Name() string { return "paymongo" }
+
+type sourceResponse struct {
+	Data struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Amount   int64 `json:"amount"`
+			Redirect struct {
+				CheckoutURL string `json:"checkout_url"`
+			} `json:"redirect"`
+			Status string `json:"status"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+func (p *PayMongoProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	kind, ok := MethodKind(req.Method)
+	if !ok {
+		return nil, fmt.Errorf("unsupported payment method %q", req.Method)
+	}
+	if kind == KindPaymentIntent {
+		return p.createPaymentIntent(ctx, req)
+	}
+
+	sourceReq := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"amount":   req.Amount,
+				"currency": req.Currency,
+				"type":     req.Method,
+				"redirect": map[string]string{
+					"success": p.cfg.SuccessURL,
+					"failed":  p.cfg.FailedURL,
+				},
+			},
+		},
+	}
+
+	// Sources are created with the public key, everything else uses the secret key
+	respBody, err := p.do(ctx, http.MethodPost, "/v1/sources", p.cfg.PublicKey, sourceReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceResp sourceResponse
+	if err := json.Unmarshal(respBody, &sourceResp); err != nil {
+		return nil, fmt.Errorf("failed to parse PayMongo source response: %v", err)
+	}
+
+	return &Checkout{
+		ID:          sourceResp.Data.ID,
+		Kind:        KindSource,
+		CheckoutURL: sourceResp.Data.Attributes.Redirect.CheckoutURL,
+		Status:      sourceResp.Data.Attributes.Status,
+	}, nil
+}
+
+func (p *PayMongoProvider) FetchStatus(ctx context.Context, checkoutID string) (*CheckoutStatus, error) {
+	if strings.HasPrefix(checkoutID, "pi_") {
+		return p.fetchPaymentIntent(ctx, checkoutID)
+	}
+
+	respBody, err := p.do(ctx, http.MethodGet, "/v1/sources/"+url.PathEscape(checkoutID), p.cfg.SecretKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceResp sourceResponse
+	if err := json.Unmarshal(respBody, &sourceResp); err != nil {
+		return nil, fmt.Errorf("failed to parse PayMongo source response: %v", err)
+	}
+
+	status := &CheckoutStatus{
+		ID:     sourceResp.Data.ID,
+		Status: sourceResp.Data.Attributes.Status,
+		Amount: sourceResp.Data.Attributes.Amount,
+	}
+	if status.Status == "paid" {
+		if status.PaymentID, err = p.paymentIDForSource(ctx, checkoutID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+func (p *PayMongoProvider) Capture(ctx context.Context, checkoutID string, amount int64) (string, error) {
+	status, err := p.FetchStatus(ctx, checkoutID)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(checkoutID, "pi_") {
+		if status.Status != "paid" {
+			return "", fmt.Errorf("%w: payment intent %s is %s", ErrNotChargeable, checkoutID, status.Status)
+		}
+		return status.PaymentID, nil
+	}
+
+	switch status.Status {
+	case "paid":
+		log.Printf("✅ Source %s already paid, reusing payment %s", checkoutID, status.PaymentID)
+		return status.PaymentID, nil
+	case "chargeable":
+		return p.createPayment(ctx, checkoutID, amount)
+	default:
+		return "", fmt.Errorf("%w: source %s is %s", ErrNotChargeable, checkoutID, status.Status)
+	}
+}
+
+func (p *PayMongoProvider) Refund(ctx context.Context, paymentID string, amount int64, reason string) (*Refund, error) {
+	if reason == "" {
+		reason = "requested_by_customer"
+	}
+	refundReq := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"amount":     amount,
+				"payment_id": paymentID,
+				"reason":     reason,
+			},
+		},
+	}
+
+	respBody, err := p.do(ctx, http.MethodPost, "/v1/refunds", p.cfg.SecretKey, refundReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var refundResp struct {
+		Data struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				Amount int64  `json:"amount"`
+				Status string `json:"status"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &refundResp); err != nil {
+		return nil, fmt.Errorf("failed to parse PayMongo refund response: %v", err)
+	}
+
+	return &Refund{
+		ID:     refundResp.Data.ID,
+		Status: refundResp.Data.Attributes.Status,
+		Amount: refundResp.Data.Attributes.Amount,
+	}, nil
+}
+
+func (p *PayMongoProvider) createPayment(ctx context.Context, sourceID string, amount int64) (string, error) {
+	paymentReq := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"amount":      amount,
+				"source":      map[string]string{"id": sourceID, "type": "source"},
+				"currency":    p.cfg.Currency,
+				"description": "RentXpert payment",
+			},
+		},
+	}
+
+	respBody, err := p.do(ctx, http.MethodPost, "/v1/payments", p.cfg.SecretKey, paymentReq)
+	if err != nil {
+		return "", err
+	}
+
+	var paymentResp struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &paymentResp); err != nil {
+		return "", fmt.Errorf("failed to parse payment response: %v", err)
+	}
+	return paymentResp.Data.ID, nil
+}
+
+type paymentIntentResponse struct {
+	Data struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Amount           int64           `json:"amount"`
+			ClientKey        string          `json:"client_key"`
+			Status           string          `json:"status"`
+			LastPaymentError json.RawMessage `json:"last_payment_error"`
+			NextAction       struct {
+				Redirect struct {
+					URL string `json:"url"`
+				} `json:"redirect"`
+			} `json:"next_action"`
+			Payments []struct {
+				ID         string `json:"id"`
+				Attributes struct {
+					Status string `json:"status"`
+				} `json:"attributes"`
+			} `json:"payments"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// intentStatus maps a payment intent status onto the checkout statuses used
+// for sources, so callers do not have to care which kind they hold.
+func intentStatus(resp paymentIntentResponse) string {
+	attrs := resp.Data.Attributes
+	switch attrs.Status {
+	case "succeeded":
+		return "paid"
+	case "awaiting_payment_method":
+		// A failed attempt sends the intent back here with the error attached
+		if len(attrs.LastPaymentError) > 0 && string(attrs.LastPaymentError) != "null" {
+			return "failed"
+		}
+		return "pending"
+	}
+	return attrs.Status // awaiting_next_action, processing
+}
+
+// createPaymentIntent opens a Payment Intent. Maya is attached server-side so
+// the customer gets a redirect URL; card details are attached by the client
+// with the returned client key so they never reach this API.
+func (p *PayMongoProvider) createPaymentIntent(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	intentReq := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"amount":                 req.Amount,
+				"currency":               req.Currency,
+				"payment_method_allowed": []string{req.Method},
+				"capture_type":           "automatic",
+				"description":            req.Description,
+			},
+		},
+	}
+
+	respBody, err := p.do(ctx, http.MethodPost, "/v1/payment_intents", p.cfg.SecretKey, intentReq)
+	if err != nil {
+		return nil, err
+	}
+	var intent paymentIntentResponse
+	if err := json.Unmarshal(respBody, &intent); err != nil {
+		return nil, fmt.Errorf("failed to parse payment intent response: %v", err)
+	}
+
+	checkout := &Checkout{
+		ID:        intent.Data.ID,
+		Kind:      KindPaymentIntent,
+		ClientKey: intent.Data.Attributes.ClientKey,
+		Status:    intentStatus(intent),
+	}
+	if req.Method == MethodCard {
+		return checkout, nil
+	}
+
+	methodReq := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{"type": req.Method},
+		},
+	}
+	respBody, err = p.do(ctx, http.MethodPost, "/v1/payment_methods", p.cfg.PublicKey, methodReq)
+	if err != nil {
+		return nil, err
+	}
+	var method struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &method); err != nil {
+		return nil, fmt.Errorf("failed to parse payment method response: %v", err)
+	}
+
+	attachReq := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"payment_method": method.Data.ID,
+				"client_key":     checkout.ClientKey,
+				"return_url":     p.cfg.SuccessURL,
+			},
+		},
+	}
+	respBody, err = p.do(ctx, http.MethodPost, "/v1/payment_intents/"+url.PathEscape(checkout.ID)+"/attach", p.cfg.PublicKey, attachReq)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(respBody, &intent); err != nil {
+		return nil, fmt.Errorf("failed to parse payment intent response: %v", err)
+	}
+
+	checkout.CheckoutURL = intent.Data.Attributes.NextAction.Redirect.URL
+	checkout.Status = intentStatus(intent)
+	return checkout, nil
+}
+
+func (p *PayMongoProvider) fetchPaymentIntent(ctx context.Context, intentID string) (*CheckoutStatus, error) {
+	respBody, err := p.do(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(intentID), p.cfg.SecretKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var intent paymentIntentResponse
+	if err := json.Unmarshal(respBody, &intent); err != nil {
+		return nil, fmt.Errorf("failed to parse payment intent response: %v", err)
+	}
+
+	status := &CheckoutStatus{
+		ID:     intent.Data.ID,
+		Status: intentStatus(intent),
+		Amount: intent.Data.Attributes.Amount,
+	}
+	for _, payment := range intent.Data.Attributes.Payments {
+		if payment.Attributes.Status == "paid" {
+			status.PaymentID = payment.ID
+		}
+	}
+	return status, nil
+}
+
+// paymentIDForSource finds the paid payment created from a source.
+func (p *PayMongoProvider) paymentIDForSource(ctx context.Context, sourceID string) (string, error) {
+	respBody, err := p.do(ctx, http.MethodGet, "/v1/payments?source_id="+url.QueryEscape(sourceID), p.cfg.SecretKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var paymentsResp struct {
+		Data []struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				Source struct {
+					ID string `json:"id"`
+				} `json:"source"`
+				Status string `json:"status"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &paymentsResp); err != nil {
+		return "", fmt.Errorf("failed to parse payments response: %v", err)
+	}
+
+	for _, payment := range paymentsResp.Data {
+		if payment.Attributes.Source.ID == sourceID && payment.Attributes.Status == "paid" {
+			return payment.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no paid payment found for source %s", sourceID)
+}
+
+// do sends a request to the PayMongo API and returns the body of a 2xx response.
+func (p *PayMongoProvider) do(ctx context.Context, method, path, key string, payload interface{}) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal PayMongo request: %v", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.BaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PayMongo request: %v", err)
+	}
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(key+":")))
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("PayMongo API error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PayMongo response: %v", err)
+	}
+	log.Printf("PayMongo %s %s: status=%d, body=%s", method, path, resp.StatusCode, string(respBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("PayMongo %s %s failed: status=%d, body=%s", method, path, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
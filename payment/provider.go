@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotChargeable is returned by Capture when the customer has not yet
+// authorized the checkout (or it already failed/expired).
+var ErrNotChargeable = errors.New("checkout is not chargeable")
+
+// Payment methods accepted by CreateSource. E-wallets redirect through a
+// PayMongo Source; card and Maya go through a Payment Intent.
+const (
+	MethodGCash   = "gcash"
+	MethodGrabPay = "grab_pay"
+	MethodMaya    = "paymaya"
+	MethodCard    = "card"
+)
+
+// Checkout kinds, i.e. which provider object a checkout ID refers to.
+const (
+	KindSource        = "source"
+	KindPaymentIntent = "payment_intent"
+)
+
+// MethodKind reports whether method is supported and which checkout kind
+// it uses.
+func MethodKind(method string) (string, bool) {
+	switch method {
+	case MethodGCash, MethodGrabPay:
+		return KindSource, true
+	case MethodMaya, MethodCard:
+		return KindPaymentIntent, true
+	}
+	return "", false
+}
+
+// CheckoutRequest describes a payment the customer is about to authorize.
+type CheckoutRequest struct {
+	Amount      int64  // total in centavos, fees included
+	Currency    string // ISO code, e.g. "PHP"
+	Method      string // e.g. "gcash"
+	Description string
+}
+
+// Checkout is the provider-side object the customer is redirected to.
+type Checkout struct {
+	ID          string // provider reference stored on model.Transaction
+	Kind        string // KindSource or KindPaymentIntent
+	CheckoutURL string // empty for card, which the client completes with ClientKey
+	ClientKey   string // payment intent client key for attaching a card client-side
+	Status      string
+}
+
+// CheckoutStatus is the provider's current view of a checkout.
+type CheckoutStatus struct {
+	ID        string
+	Status    string // pending, chargeable, awaiting_next_action, processing, paid, failed, cancelled, expired
+	Amount    int64
+	PaymentID string // set once the checkout has been captured
+}
+
+// Refund is a refund issued against a captured payment.
+type Refund struct {
+	ID     string
+	Status string // pending, succeeded, failed
+	Amount int64
+}
+
+// PaymentProvider is implemented by every payment gateway the API can use.
+type PaymentProvider interface {
+	// Name identifies the provider in logs and config ("paymongo", "fake").
+	Name() string
+
+	// CreateCheckout starts a payment and returns where to send the customer.
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+
+	// FetchStatus looks up the current status of a checkout.
+	FetchStatus(ctx context.Context, checkoutID string) (*CheckoutStatus, error)
+
+	// Capture charges an authorized checkout and returns the payment ID. It is
+	// safe to call again for a checkout that was already captured. Payment
+	// intents capture automatically, so for them this only returns the ID.
+	Capture(ctx context.Context, checkoutID string, amount int64) (string, error)
+
+	// Refund returns all or part of a captured payment.
+	Refund(ctx context.Context, paymentID string, amount int64, reason string) (*Refund, error)
+}
+
+// NewProvider returns the provider selected by cfg.Provider.
+func NewProvider(cfg Config) PaymentProvider {
+	if cfg.Provider == "fake" {
+		return NewFakeProvider(cfg)
+	}
+	return NewPayMongoProvider(cfg)
+}
@@ -0,0 +1,79 @@
+package payment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixtureSecret is the webhook secret the recorded fixtures in testdata were
+// signed with; their Paymongo-Signature headers are in the matching .sig files.
+const fixtureSecret = "whsk_test_fixture_secret"
+
+// fixtureTime is the t= of the recorded signatures.
+var fixtureTime = time.Unix(1760000000, 0)
+
+func loadFixture(t *testing.T, name string) (body []byte, header string) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := os.ReadFile(filepath.Join("testdata", name+".sig"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body, strings.TrimSpace(string(sig))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		livemode bool
+		secret   string
+		now      time.Time
+		wantErr  string
+	}{
+		{"test mode signature in te", "source_chargeable", false, fixtureSecret, fixtureTime.Add(30 * time.Second), ""},
+		{"live mode signature in li", "payment_paid_live", true, fixtureSecret, fixtureTime, ""},
+		{"test event checked against li", "source_chargeable", true, fixtureSecret, fixtureTime, "malformed"},
+		{"wrong secret", "source_chargeable", false, "whsk_someone_else", fixtureTime, "mismatch"},
+		{"replayed too late", "source_chargeable", false, fixtureSecret, fixtureTime.Add(WebhookTolerance + time.Second), "tolerance"},
+		{"timestamp from the future", "payment_paid_live", true, fixtureSecret, fixtureTime.Add(-WebhookTolerance - time.Second), "tolerance"},
+		{"no secret configured", "source_chargeable", false, "", fixtureTime, "not configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, header := loadFixture(t, tt.fixture)
+			err := VerifyWebhookSignature(tt.secret, body, header, tt.livemode, tt.now)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookSignatureTamperedBody(t *testing.T) {
+	body, header := loadFixture(t, "source_chargeable")
+	tampered := []byte(strings.Replace(string(body), "501000", "100", 1))
+	if err := VerifyWebhookSignature(fixtureSecret, tampered, header, false, fixtureTime); err == nil {
+		t.Fatal("a changed amount must not verify")
+	}
+}
+
+func TestSignWebhookRoundTrip(t *testing.T) {
+	body, _ := loadFixture(t, "source_chargeable")
+	now := time.Now()
+	for _, livemode := range []bool{false, true} {
+		header := SignWebhook(fixtureSecret, body, livemode, now)
+		if err := VerifyWebhookSignature(fixtureSecret, body, header, livemode, now); err != nil {
+			t.Errorf("livemode=%v: %v", livemode, err)
+		}
+	}
+}
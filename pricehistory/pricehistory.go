@@ -0,0 +1,85 @@
+// Package pricehistory records every rent an apartment is listed at and tells
+// tenants who wishlisted an apartment when its rent drops.
+package pricehistory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Conding-Student/backend/config"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+
+	"gorm.io/gorm"
+)
+
+// Record adds a price history entry for apartmentID. Pass a nil oldPrice for
+// a new listing. Call it in the same transaction that saves the rent.
+func Record(tx *gorm.DB, apartmentID uint, oldPrice *float64, newPrice float64, changedBy string) error {
+	return tx.Create(&model.ApartmentPriceHistory{
+		ApartmentID: apartmentID,
+		OldPrice:    oldPrice,
+		NewPrice:    newPrice,
+		ChangedBy:   changedBy,
+		ChangedAt:   time.Now(),
+	}).Error
+}
+
+// Change records a rent change from oldPrice to newPrice and reports whether
+// it was a drop worth alerting about. It records nothing when the rent is
+// unchanged.
+func Change(tx *gorm.DB, apartmentID uint, oldPrice, newPrice float64, changedBy string) (bool, error) {
+	if newPrice == oldPrice {
+		return false, nil
+	}
+	if err := Record(tx, apartmentID, &oldPrice, newPrice, changedBy); err != nil {
+		return false, err
+	}
+	return newPrice < oldPrice, nil
+}
+
+// Load returns the price history of apartmentID, oldest first.
+func Load(db *gorm.DB, apartmentID uint) ([]model.ApartmentPriceHistory, error) {
+	history := []model.ApartmentPriceHistory{}
+	err := db.Where("apartment_id = ?", apartmentID).Order("changed_at, id").Find(&history).Error
+	return history, err
+}
+
+// NotifyDrop pushes a price-drop alert to every tenant with the apartment in
+// their wishlist. Only approved listings alert. Run it in a goroutine after
+// the new rent is committed.
+func NotifyDrop(apartmentID uint, oldPrice, newPrice float64) {
+	now := time.Now()
+
+	var apt model.Apartment
+	if err := middleware.DBConn.First(&apt, apartmentID).Error; err != nil {
+		fmt.Printf("[%s] Price drop alert: apartment %d not found: %v\n", now.Format(time.RFC3339), apartmentID, err)
+		return
+	}
+	if apt.Status != "Approved" {
+		return
+	}
+
+	var uids []string
+	if err := middleware.DBConn.Model(&model.Wishlist{}).
+		Where("apartment_id = ?", apartmentID).
+		Distinct().Pluck("uid", &uids).Error; err != nil {
+		fmt.Printf("[%s] Error loading wishlists of apartment %d: %v\n", now.Format(time.RFC3339), apartmentID, err)
+		return
+	}
+
+	title := fmt.Sprintf("Price drop: %s", apt.PropertyName)
+	body := fmt.Sprintf("Now ₱%.0f/month, down from ₱%.0f", newPrice, oldPrice)
+	alerted := 0
+	for _, uid := range uids {
+		if err := config.SendPushToUser(uid, title, body); err != nil {
+			fmt.Printf("[%s] Error sending price drop alert to %s: %v\n", now.Format(time.RFC3339), uid, err)
+			continue
+		}
+		alerted++
+	}
+
+	if alerted > 0 {
+		fmt.Printf("[%s] Alerted %d tenants about the price drop of apartment %d\n", now.Format(time.RFC3339), alerted, apartmentID)
+	}
+}
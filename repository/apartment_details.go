@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"github.com/Conding-Student/backend/availability"
+	"github.com/Conding-Student/backend/model"
+
+	"gorm.io/gorm"
+)
+
+// LoadApartmentDetails assembles model.ApartmentDetails for a page of
+// apartments with eight batched queries, however many apartments there are.
+// The input order is kept. Apartments whose landlord no longer exists are
+// left out, as the per-row handlers always did.
+func LoadApartmentDetails(db *gorm.DB, apartments []model.Apartment) ([]model.ApartmentDetails, error) {
+	details := make([]model.ApartmentDetails, 0, len(apartments))
+	if len(apartments) == 0 {
+		return details, nil
+	}
+
+	ids := make([]uint, 0, len(apartments))
+	uidSet := map[string]bool{}
+	var uids []string
+	for _, apt := range apartments {
+		ids = append(ids, apt.ID)
+		if !uidSet[apt.Uid] {
+			uidSet[apt.Uid] = true
+			uids = append(uids, apt.Uid)
+		}
+	}
+
+	var landlords []model.User
+	if err := db.Where("uid IN ?", uids).Find(&landlords).Error; err != nil {
+		return nil, err
+	}
+	landlordByUID := make(map[string]model.User, len(landlords))
+	for _, l := range landlords {
+		landlordByUID[l.Uid] = l
+	}
+
+	var images []model.ApartmentImage
+	if err := db.Select("apartment_id, image_url").Where("apartment_id IN ?", ids).Order("id").Find(&images).Error; err != nil {
+		return nil, err
+	}
+	imagesByID := map[uint][]string{}
+	for _, img := range images {
+		imagesByID[img.ApartmentID] = append(imagesByID[img.ApartmentID], img.ImageURL)
+	}
+
+	var videos []model.ApartmentVideo
+	if err := db.Select("apartment_id, video_url").Where("apartment_id IN ?", ids).Order("id").Find(&videos).Error; err != nil {
+		return nil, err
+	}
+	videosByID := map[uint][]string{}
+	for _, vid := range videos {
+		videosByID[vid.ApartmentID] = append(videosByID[vid.ApartmentID], vid.VideoURL)
+	}
+
+	var amenities []struct {
+		ApartmentID uint
+		Name        string
+	}
+	if err := db.Table("apartment_amenities").
+		Select("apartment_amenities.apartment_id, amenities.name").
+		Joins("JOIN amenities ON amenities.id = apartment_amenities.amenity_id").
+		Where("apartment_amenities.apartment_id IN ?", ids).
+		Order("apartment_amenities.id").
+		Scan(&amenities).Error; err != nil {
+		return nil, err
+	}
+	amenitiesByID := map[uint][]string{}
+	for _, a := range amenities {
+		amenitiesByID[a.ApartmentID] = append(amenitiesByID[a.ApartmentID], a.Name)
+	}
+
+	var houseRules []struct {
+		ApartmentID uint
+		Rule        string
+	}
+	if err := db.Table("apartment_house_rules").
+		Select("apartment_house_rules.apartment_id, house_rules.rule").
+		Joins("JOIN house_rules ON house_rules.id = apartment_house_rules.house_rule_id").
+		Where("apartment_house_rules.apartment_id IN ?", ids).
+		Order("apartment_house_rules.id").
+		Scan(&houseRules).Error; err != nil {
+		return nil, err
+	}
+	rulesByID := map[uint][]string{}
+	for _, r := range houseRules {
+		rulesByID[r.ApartmentID] = append(rulesByID[r.ApartmentID], r.Rule)
+	}
+
+	var inquiryCounts []struct {
+		PropertyID uint
+		Count      int64
+	}
+	if err := db.Model(&model.Inquiry{}).
+		Select("property_id, COUNT(*) AS count").
+		Where("property_id IN ?", ids).
+		Group("property_id").
+		Scan(&inquiryCounts).Error; err != nil {
+		return nil, err
+	}
+	inquiriesByID := map[uint]int64{}
+	for _, ic := range inquiryCounts {
+		inquiriesByID[ic.PropertyID] = ic.Count
+	}
+
+	var unitStats []struct {
+		ApartmentID    uint
+		PriceMin       float64
+		PriceMax       float64
+		UnitsTotal     int
+		UnitsAvailable int
+	}
+	if err := db.Model(&model.ApartmentUnit{}).
+		Select("apartment_id, MIN(rent_price) AS price_min, MAX(rent_price) AS price_max, "+
+			"COUNT(*) AS units_total, COUNT(*) FILTER (WHERE availability = 'Available') AS units_available").
+		Where("apartment_id IN ?", ids).
+		Group("apartment_id").
+		Scan(&unitStats).Error; err != nil {
+		return nil, err
+	}
+	type unitSummary struct {
+		priceMin, priceMax float64
+		total, available   int
+	}
+	unitsByID := map[uint]unitSummary{}
+	for _, u := range unitStats {
+		unitsByID[u.ApartmentID] = unitSummary{u.PriceMin, u.PriceMax, u.UnitsTotal, u.UnitsAvailable}
+	}
+
+	windows, err := availability.Upcoming(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	windowsByID := map[uint][]model.AvailabilityWindow{}
+	for _, w := range windows {
+		windowsByID[w.ApartmentID] = append(windowsByID[w.ApartmentID], w)
+	}
+
+	// Always send arrays, never null
+	orEmpty := func(items []string) []string {
+		if items == nil {
+			return []string{}
+		}
+		return items
+	}
+
+	for _, apt := range apartments {
+		landlord, ok := landlordByUID[apt.Uid]
+		if !ok {
+			continue
+		}
+		// Without units the apartment is one unit at its own rent
+		units, ok := unitsByID[apt.ID]
+		if !ok {
+			units = unitSummary{priceMin: apt.RentPrice, priceMax: apt.RentPrice, total: 1}
+			if apt.Availability == "Available" {
+				units.available = 1
+			}
+		}
+		details = append(details, model.ApartmentDetails{
+			Apartment:         apt,
+			LandlordName:      landlord.Fullname,
+			LandlordEmail:     landlord.Email,
+			LandlordPhone:     landlord.PhoneNumber,
+			LandlordAddress:   landlord.Address,
+			LandlordValidID:   landlord.ValidID,
+			LandlordPhotoURL:  landlord.PhotoURL,
+			LandlordUserType:  landlord.UserType,
+			LandlordStatus:    landlord.AccountStatus,
+			Images:            orEmpty(imagesByID[apt.ID]),
+			Videos:            orEmpty(videosByID[apt.ID]),
+			Amenities:         orEmpty(amenitiesByID[apt.ID]),
+			HouseRules:        orEmpty(rulesByID[apt.ID]),
+			InquiriesCount:    inquiriesByID[apt.ID],
+			IsFeatured:        apt.IsFeatured(),
+			PriceMin:          units.priceMin,
+			PriceMax:          units.priceMax,
+			UnitsTotal:        units.total,
+			UnitsAvailable:    units.available,
+			NextAvailableDate: availability.NextAvailable(apt, windowsByID[apt.ID]),
+		})
+	}
+	return details, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/testdb"
+
+	"gorm.io/gorm"
+)
+
+// BenchmarkLoadApartmentDetails reports queries/op next to the timings: it
+// must stay the same for every page size.
+func BenchmarkLoadApartmentDetails(b *testing.B) {
+	db := testdb.Open(b)
+	pageSizes := []int{1, 10, 50, 200}
+	seedApartmentDetails(b, db, pageSizes[len(pageSizes)-1])
+
+	// Find goes through the query callbacks, the joined Scans through row
+	var queries atomic.Int64
+	count := func(*gorm.DB) { queries.Add(1) }
+	if err := db.Callback().Query().After("gorm:query").Register("bench:count_queries", count); err != nil {
+		b.Fatal(err)
+	}
+	if err := db.Callback().Row().After("gorm:row").Register("bench:count_rows", count); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		db.Callback().Query().Remove("bench:count_queries")
+		db.Callback().Row().Remove("bench:count_rows")
+	})
+
+	for _, size := range pageSizes {
+		b.Run(fmt.Sprintf("page=%d", size), func(b *testing.B) {
+			var page []model.Apartment
+			if err := db.Order("id").Limit(size).Find(&page).Error; err != nil {
+				b.Fatal(err)
+			}
+
+			queries.Store(0)
+			b.ResetTimer()
+			for range b.N {
+				details, err := LoadApartmentDetails(db, page)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(details) != size {
+					b.Fatalf("got %d details, want %d", len(details), size)
+				}
+			}
+			b.ReportMetric(float64(queries.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
+
+// seedApartmentDetails creates n approved apartments spread over a few
+// landlords, each with images, a video, amenities, house rules and a unit.
+func seedApartmentDetails(b *testing.B, db *gorm.DB, n int) {
+	b.Helper()
+
+	amenities := []model.Amenity{{Name: "WiFi"}, {Name: "Parking"}, {Name: "Aircon"}}
+	rules := []model.HouseRule{{Rule: "No pets"}, {Rule: "No smoking"}}
+	if err := db.Create(&amenities).Error; err != nil {
+		b.Fatal(err)
+	}
+	if err := db.Create(&rules).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	var landlords []model.User
+	for i := range 5 {
+		uid := fmt.Sprintf("landlord-%d", i)
+		landlords = append(landlords, model.User{
+			Uid: uid, Email: uid + "@example.com", UserType: "Landlord", Provider: "email", AccountStatus: "Verified",
+		})
+	}
+	if err := db.Create(&landlords).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	apartments := make([]model.Apartment, n)
+	for i := range apartments {
+		uid := landlords[i%len(landlords)].Uid
+		apartments[i] = model.Apartment{
+			Uid: uid, UserID: uid, PropertyName: fmt.Sprintf("Bench Place %d", i), Address: "5 Bench St",
+			PropertyType: "Apartment", RentPrice: 5000, LocationLink: "-", Landmarks: "-",
+			Allowed_Gender: "Any", Status: "Approved", Availability: "Available",
+		}
+	}
+	if err := db.CreateInBatches(&apartments, 100).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	var (
+		images      []model.ApartmentImage
+		videos      []model.ApartmentVideo
+		withAmenity []model.ApartmentAmenity
+		withRule    []model.ApartmentHouseRule
+		units       []model.ApartmentUnit
+	)
+	for _, apt := range apartments {
+		for j := range 3 {
+			images = append(images, model.ApartmentImage{ApartmentID: apt.ID, ImageURL: fmt.Sprintf("https://example.com/%d/%d.jpg", apt.ID, j)})
+		}
+		videos = append(videos, model.ApartmentVideo{ApartmentID: apt.ID, VideoURL: fmt.Sprintf("https://example.com/%d.mp4", apt.ID)})
+		for _, amenity := range amenities[:2] {
+			withAmenity = append(withAmenity, model.ApartmentAmenity{ApartmentID: apt.ID, AmenityID: amenity.ID})
+		}
+		for _, rule := range rules {
+			withRule = append(withRule, model.ApartmentHouseRule{ApartmentID: apt.ID, HouseRuleID: rule.ID})
+		}
+		units = append(units, model.ApartmentUnit{ApartmentID: apt.ID, Label: "Unit A", RentPrice: 5000, Capacity: 2, AllowedGender: "Any"})
+	}
+	for _, rows := range []interface{}{&images, &videos, &withAmenity, &withRule, &units} {
+		if err := db.CreateInBatches(rows, 500).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
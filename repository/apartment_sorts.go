@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"strconv"
+
+	"github.com/Conding-Student/backend/pagination"
+)
+
+// FeaturedSQL is true while a paid promotion is boosting the apartment.
+const FeaturedSQL = "apartments.featured_until IS NOT NULL AND apartments.featured_until > NOW()"
+
+// AverageRatingSQL is the apartment's mean star rating, 0 when unrated.
+const AverageRatingSQL = "(SELECT COALESCE(AVG(ratings.rating), 0) FROM ratings WHERE ratings.apartment_id = apartments.id)"
+
+// InquiriesCountSQL is how many inquiries an apartment has received.
+const InquiriesCountSQL = "(SELECT COUNT(*) FROM inquiries WHERE inquiries.property_id = apartments.id)"
+
+// relevanceWeight keeps the filter relevance ahead of the inquiries count
+// when both are folded into one sort value.
+const relevanceWeight = 1000000000
+
+// PriceMinSQL is the cheapest unit rent of an apartment, or its own rent
+// when it has no units.
+const PriceMinSQL = "COALESCE((SELECT MIN(apartment_units.rent_price) FROM apartment_units " +
+	"WHERE apartment_units.apartment_id = apartments.id), apartments.rent_price)"
+
+// UnitsAvailableSQL counts an apartment's available units. An apartment
+// without units is one unit, available when the apartment is.
+const UnitsAvailableSQL = `(CASE WHEN EXISTS (SELECT 1 FROM apartment_units WHERE apartment_units.apartment_id = apartments.id)
+	THEN (SELECT COUNT(*) FROM apartment_units WHERE apartment_units.apartment_id = apartments.id AND apartment_units.availability = 'Available')
+	WHEN apartments.availability = 'Available' THEN 1 ELSE 0 END)`
+
+// HaversineSQL is the great-circle distance in km from (?, ?) to an
+// apartment. Its parameters are lat, lat, lng.
+const HaversineSQL = `(2 * 6371 * ASIN(SQRT(
+	POWER(SIN(RADIANS(apartments.latitude - ?) / 2), 2) +
+	COS(RADIANS(?)) * COS(RADIANS(apartments.latitude)) *
+	POWER(SIN(RADIANS(apartments.longitude - ?) / 2), 2))))`
+
+// ApartmentSorts is the ?sort= whitelist for tenant-facing listing feeds.
+// Featured apartments stay on top whatever the sort. The default, relevance,
+// ranks apartments by how many of the filter's amenities and house rules
+// they have, then by inquiries. distance is only offered when the caller
+// passes a point to measure from.
+func ApartmentSorts(point *[2]float64, filter ListingFilter) pagination.Sorts {
+	relevance, relevanceArgs := filter.RelevanceSQL()
+	sorts := pagination.Sorts{
+		Fields: map[string]pagination.Field{
+			"relevance": {
+				Column: "(" + relevance + ") * " + strconv.Itoa(relevanceWeight) + " + " + InquiriesCountSQL,
+				Args:   relevanceArgs,
+				Type:   pagination.TypeBigint,
+				Desc:   true,
+			},
+			"price":      {Column: PriceMinSQL, Type: pagination.TypeNumber},
+			"created_at": {Column: "apartments.created_at", Type: pagination.TypeTime, Desc: true},
+			"rating":     {Column: AverageRatingSQL, Type: pagination.TypeNumber, Desc: true},
+		},
+		Default: "relevance",
+		ID:      pagination.Field{Column: "apartments.id", Type: pagination.TypeBigint},
+		Pinned:  FeaturedSQL,
+	}
+	if point != nil {
+		sorts.Fields["distance"] = pagination.Field{
+			Column: HaversineSQL,
+			Args:   []interface{}{point[0], point[0], point[1]},
+			Type:   pagination.TypeNumber,
+		}
+	}
+	return sorts
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Conding-Student/backend/availability"
+
+	"gorm.io/gorm"
+)
+
+// ListingFilter holds the tenant-facing listing filters shared by the filter,
+// search and map endpoints and by saved searches:
+// ?property_types=&min_price=&max_price=&allowed_genders=&availability=&amenities=&house_rules=&move_in=
+type ListingFilter struct {
+	PropertyTypes  []string
+	AllowedGenders []string
+	Availability   []string
+	MinPrice       *float64
+	MaxPrice       *float64
+	Amenities      []string
+	HouseRules     []string
+	MoveIn         *time.Time // YYYY-MM-DD; listings open on that day
+}
+
+// SplitList splits a comma-separated query value, dropping empty items.
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ListingFilterKeys are the query parameters ParseListingFilter reads.
+var ListingFilterKeys = []string{"property_types", "min_price", "max_price", "allowed_genders", "availability", "amenities", "house_rules", "move_in"}
+
+// ParseListingFilter reads the filters through get, which returns a query
+// parameter by name.
+func ParseListingFilter(get func(key string) string) ListingFilter {
+	f := ListingFilter{
+		PropertyTypes:  SplitList(get("property_types")),
+		AllowedGenders: SplitList(get("allowed_genders")),
+		Availability:   SplitList(get("availability")),
+		Amenities:      SplitList(get("amenities")),
+		HouseRules:     SplitList(get("house_rules")),
+	}
+	if minPrice, err := strconv.ParseFloat(get("min_price"), 64); err == nil {
+		f.MinPrice = &minPrice
+	}
+	if maxPrice, err := strconv.ParseFloat(get("max_price"), 64); err == nil {
+		f.MaxPrice = &maxPrice
+	}
+	if moveIn, err := time.Parse(availability.DateLayout, get("move_in")); err == nil {
+		// Nobody can move in yesterday
+		if today := availability.Today(); moveIn.Before(today) {
+			moveIn = today
+		}
+		f.MoveIn = &moveIn
+	}
+	return f
+}
+
+// Apply adds the filters that can be answered in SQL to a query on apartments.
+func (f ListingFilter) Apply(db *gorm.DB) *gorm.DB {
+	if len(f.PropertyTypes) > 0 {
+		db = db.Where("apartments.property_type IN ?", f.PropertyTypes)
+	}
+	// A price range matches when any unit's rent falls in it, or the
+	// apartment's own rent when it has no units.
+	if f.MinPrice != nil || f.MaxPrice != nil {
+		unitPrice, unitArgs := f.priceRange("apartment_units.rent_price")
+		ownPrice, ownArgs := f.priceRange("apartments.rent_price")
+		db = db.Where("(EXISTS (SELECT 1 FROM apartment_units WHERE apartment_units.apartment_id = apartments.id AND "+unitPrice+") "+
+			"OR (NOT EXISTS (SELECT 1 FROM apartment_units WHERE apartment_units.apartment_id = apartments.id) AND "+ownPrice+"))",
+			append(unitArgs, ownArgs...)...)
+	}
+	if len(f.AllowedGenders) > 0 {
+		db = db.Where("(apartments.allowed_gender IN ? OR EXISTS (SELECT 1 FROM apartment_units "+
+			"WHERE apartment_units.apartment_id = apartments.id AND apartment_units.allowed_gender IN ?))",
+			f.AllowedGenders, f.AllowedGenders)
+	}
+	if len(f.Availability) > 0 {
+		// An apartment whose units are all taken is not available
+		db = db.Where("apartments.availability IN ? AND (apartments.availability <> 'Available' OR "+UnitsAvailableSQL+" > 0)", f.Availability)
+	}
+	if f.MoveIn != nil {
+		db = db.Where(availability.MoveInSQL, sql.Named("move_in", f.MoveIn.Format(availability.DateLayout)))
+	}
+
+	// Matching any requested amenity or house rule is enough.
+	var matches []string
+	var args []interface{}
+	if len(f.Amenities) > 0 {
+		matches = append(matches, "EXISTS (SELECT 1 FROM apartment_amenities "+
+			"JOIN amenities ON amenities.id = apartment_amenities.amenity_id "+
+			"WHERE apartment_amenities.apartment_id = apartments.id AND LOWER(TRIM(amenities.name)) IN ?)")
+		args = append(args, lowerAll(f.Amenities))
+	}
+	if len(f.HouseRules) > 0 {
+		matches = append(matches, "EXISTS (SELECT 1 FROM apartment_house_rules "+
+			"JOIN house_rules ON house_rules.id = apartment_house_rules.house_rule_id "+
+			"WHERE apartment_house_rules.apartment_id = apartments.id AND LOWER(TRIM(house_rules.rule)) IN ?)")
+		args = append(args, lowerAll(f.HouseRules))
+	}
+	if len(matches) > 0 {
+		db = db.Where("("+strings.Join(matches, " OR ")+")", args...)
+	}
+	return db
+}
+
+// RelevanceSQL counts how many of the requested amenities and house rules an
+// apartment has; it is 0 when neither filter is set.
+func (f ListingFilter) RelevanceSQL() (string, []interface{}) {
+	counts := []string{"0"}
+	var args []interface{}
+	if len(f.Amenities) > 0 {
+		counts = append(counts, "(SELECT COUNT(DISTINCT LOWER(TRIM(amenities.name))) FROM apartment_amenities "+
+			"JOIN amenities ON amenities.id = apartment_amenities.amenity_id "+
+			"WHERE apartment_amenities.apartment_id = apartments.id AND LOWER(TRIM(amenities.name)) IN ?)")
+		args = append(args, lowerAll(f.Amenities))
+	}
+	if len(f.HouseRules) > 0 {
+		counts = append(counts, "(SELECT COUNT(DISTINCT LOWER(TRIM(house_rules.rule))) FROM apartment_house_rules "+
+			"JOIN house_rules ON house_rules.id = apartment_house_rules.house_rule_id "+
+			"WHERE apartment_house_rules.apartment_id = apartments.id AND LOWER(TRIM(house_rules.rule)) IN ?)")
+		args = append(args, lowerAll(f.HouseRules))
+	}
+	return strings.Join(counts, " + "), args
+}
+
+// priceRange is the min_price/max_price condition on column.
+func (f ListingFilter) priceRange(column string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.MinPrice != nil {
+		conditions = append(conditions, column+" >= ?")
+		args = append(args, *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		conditions = append(conditions, column+" <= ?")
+		args = append(args, *f.MaxPrice)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func lowerAll(items []string) []string {
+	lowered := make([]string, len(items))
+	for i, item := range items {
+		lowered[i] = strings.ToLower(item)
+	}
+	return lowered
+}
@@ -0,0 +1,499 @@
+// Package revision holds landlords' edits to approved listings for admin
+// review. The live listing keeps its current values until a revision is
+// approved. Price-only edits within REVISION_AUTO_APPROVE_PRICE_PCT percent
+// of the rent an admin last approved are approved straight away.
+package revision
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
+	"github.com/Conding-Student/backend/pricehistory"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusWithdrawn = "withdrawn"
+)
+
+var (
+	ErrNotPending = errors.New("revision is no longer pending")
+	ErrNoChanges  = errors.New("no changes to the listing")
+)
+
+// Changes are proposed listing values. Nil fields are left as they are.
+type Changes struct {
+	PropertyName *string   `json:"property_name,omitempty"`
+	Address      *string   `json:"address,omitempty"`
+	PropertyType *string   `json:"property_type,omitempty"`
+	RentPrice    *float64  `json:"rent_price,omitempty"`
+	LocationLink *string   `json:"location_link,omitempty"`
+	Landmarks    *string   `json:"landmarks,omitempty"`
+	Latitude     *float64  `json:"latitude,omitempty"`
+	Longitude    *float64  `json:"longitude,omitempty"`
+	Amenities    *[]string `json:"amenities,omitempty"`
+	HouseRules   *[]string `json:"house_rules,omitempty"`
+	Images       *[]string `json:"images,omitempty"` // uploaded URLs replacing the listing's images
+	Videos       *[]string `json:"videos,omitempty"`
+}
+
+// FieldChange is one field of a revision next to the live value.
+type FieldChange struct {
+	Field    string      `json:"field"`
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+}
+
+// Detail is a revision with its proposed changes and, for pending ones, a
+// field-by-field comparison with the live listing.
+type Detail struct {
+	model.ApartmentRevision
+	Changes Changes       `json:"changes"`
+	Diff    []FieldChange `json:"diff,omitempty"`
+}
+
+// Describe builds the Detail of r. Only pending revisions are compared, as
+// the live listing has moved on from the others.
+func Describe(db *gorm.DB, r model.ApartmentRevision) (Detail, error) {
+	ch, err := Decode(r)
+	if err != nil {
+		return Detail{}, err
+	}
+	detail := Detail{ApartmentRevision: r, Changes: ch}
+	if r.Status == StatusPending {
+		current, err := load(db, r.ApartmentID)
+		if err != nil {
+			return detail, err
+		}
+		detail.Diff = Diff(current, ch)
+	}
+	return detail, nil
+}
+
+// Decode reads the changes stored on r.
+func Decode(r model.ApartmentRevision) (Changes, error) {
+	var ch Changes
+	err := json.Unmarshal([]byte(r.Changes), &ch)
+	return ch, err
+}
+
+// merge overlays the fields set in next onto ch.
+func (ch Changes) merge(next Changes) Changes {
+	if next.PropertyName != nil {
+		ch.PropertyName = next.PropertyName
+	}
+	if next.Address != nil {
+		ch.Address = next.Address
+	}
+	if next.PropertyType != nil {
+		ch.PropertyType = next.PropertyType
+	}
+	if next.RentPrice != nil {
+		ch.RentPrice = next.RentPrice
+	}
+	if next.LocationLink != nil {
+		ch.LocationLink = next.LocationLink
+	}
+	if next.Landmarks != nil {
+		ch.Landmarks = next.Landmarks
+	}
+	if next.Latitude != nil {
+		ch.Latitude = next.Latitude
+	}
+	if next.Longitude != nil {
+		ch.Longitude = next.Longitude
+	}
+	if next.Amenities != nil {
+		ch.Amenities = next.Amenities
+	}
+	if next.HouseRules != nil {
+		ch.HouseRules = next.HouseRules
+	}
+	if next.Images != nil {
+		ch.Images = next.Images
+	}
+	if next.Videos != nil {
+		ch.Videos = next.Videos
+	}
+	return ch
+}
+
+func sameSet(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// Diff lists the fields of ch that differ from the live listing, in a fixed
+// order.
+func Diff(current model.ApartmentDetails, ch Changes) []FieldChange {
+	diff := []FieldChange{}
+	text := func(field string, live string, proposed *string) {
+		if proposed != nil && *proposed != live {
+			diff = append(diff, FieldChange{Field: field, Current: live, Proposed: *proposed})
+		}
+	}
+	number := func(field string, live float64, proposed *float64) {
+		if proposed != nil && *proposed != live {
+			diff = append(diff, FieldChange{Field: field, Current: live, Proposed: *proposed})
+		}
+	}
+	list := func(field string, live []string, proposed *[]string) {
+		if live == nil {
+			live = []string{}
+		}
+		if proposed != nil && !sameSet(live, *proposed) {
+			diff = append(diff, FieldChange{Field: field, Current: live, Proposed: *proposed})
+		}
+	}
+
+	text("property_name", current.PropertyName, ch.PropertyName)
+	text("address", current.Address, ch.Address)
+	text("property_type", current.PropertyType, ch.PropertyType)
+	number("rent_price", current.RentPrice, ch.RentPrice)
+	text("location_link", current.LocationLink, ch.LocationLink)
+	text("landmarks", current.Landmarks, ch.Landmarks)
+	number("latitude", current.Latitude, ch.Latitude)
+	number("longitude", current.Longitude, ch.Longitude)
+	list("amenities", current.Amenities, ch.Amenities)
+	list("house_rules", current.HouseRules, ch.HouseRules)
+	list("images", current.Images, ch.Images)
+	list("videos", current.Videos, ch.Videos)
+	return diff
+}
+
+// trim drops the fields of ch that already match the live listing.
+func trim(current model.ApartmentDetails, ch Changes) Changes {
+	if ch.PropertyName != nil && *ch.PropertyName == current.PropertyName {
+		ch.PropertyName = nil
+	}
+	if ch.Address != nil && *ch.Address == current.Address {
+		ch.Address = nil
+	}
+	if ch.PropertyType != nil && *ch.PropertyType == current.PropertyType {
+		ch.PropertyType = nil
+	}
+	if ch.RentPrice != nil && *ch.RentPrice == current.RentPrice {
+		ch.RentPrice = nil
+	}
+	if ch.LocationLink != nil && *ch.LocationLink == current.LocationLink {
+		ch.LocationLink = nil
+	}
+	if ch.Landmarks != nil && *ch.Landmarks == current.Landmarks {
+		ch.Landmarks = nil
+	}
+	if ch.Latitude != nil && *ch.Latitude == current.Latitude {
+		ch.Latitude = nil
+	}
+	if ch.Longitude != nil && *ch.Longitude == current.Longitude {
+		ch.Longitude = nil
+	}
+	if ch.Amenities != nil && sameSet(current.Amenities, *ch.Amenities) {
+		ch.Amenities = nil
+	}
+	if ch.HouseRules != nil && sameSet(current.HouseRules, *ch.HouseRules) {
+		ch.HouseRules = nil
+	}
+	if ch.Images != nil && sameSet(current.Images, *ch.Images) {
+		ch.Images = nil
+	}
+	if ch.Videos != nil && sameSet(current.Videos, *ch.Videos) {
+		ch.Videos = nil
+	}
+	return ch
+}
+
+// autoApprovePricePct reads REVISION_AUTO_APPROVE_PRICE_PCT. Zero, the
+// default, turns auto-approval off.
+func autoApprovePricePct() float64 {
+	pct, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("REVISION_AUTO_APPROVE_PRICE_PCT")), 64)
+	if err != nil || pct < 0 {
+		return 0
+	}
+	return pct
+}
+
+// autoApprovable reports whether diff only changes the rent, by at most the
+// configured percentage of approvedPrice.
+func autoApprovable(diff []FieldChange, approvedPrice float64) bool {
+	pct := autoApprovePricePct()
+	if pct == 0 || approvedPrice <= 0 || len(diff) != 1 || diff[0].Field != "rent_price" {
+		return false
+	}
+	proposed := diff[0].Proposed.(float64)
+	return math.Abs(proposed-approvedPrice)/approvedPrice*100 <= pct
+}
+
+// approvedPrice is the rent an admin last signed off on, when approving the
+// listing or a revision of it. Auto-approved revisions do not move it, so a
+// run of small price edits cannot add up to an unreviewed one.
+func approvedPrice(tx *gorm.DB, apt model.Apartment) (float64, error) {
+	var approval struct{ At *time.Time }
+	if err := tx.Raw(`SELECT MAX(at) AS at FROM (
+		SELECT created_at AS at FROM moderation_events WHERE apartment_id = ? AND to_status = ? AND actor_role = ?
+		UNION ALL
+		SELECT reviewed_at FROM apartment_revisions WHERE apartment_id = ? AND status = ? AND NOT auto_approved
+	) approvals`, apt.ID, moderation.StatusApproved, moderation.RoleAdmin, apt.ID, StatusApproved).Scan(&approval).Error; err != nil {
+		return 0, err
+	}
+	if approval.At == nil {
+		return apt.RentPrice, nil
+	}
+
+	// The rent then is the last one set by that time, or else the one the
+	// first later change replaced
+	var entry model.ApartmentPriceHistory
+	err := tx.Where("apartment_id = ? AND changed_at <= ?", apt.ID, *approval.At).
+		Order("changed_at DESC, id DESC").First(&entry).Error
+	if err == nil {
+		return entry.NewPrice, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	err = tx.Where("apartment_id = ? AND changed_at > ? AND old_price IS NOT NULL", apt.ID, *approval.At).
+		Order("changed_at, id").First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apt.RentPrice, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return *entry.OldPrice, nil
+}
+
+// load returns the live listing with its amenities, house rules and media.
+func load(tx *gorm.DB, apartmentID uint) (model.ApartmentDetails, error) {
+	var details model.ApartmentDetails
+	if err := tx.First(&details.Apartment, apartmentID).Error; err != nil {
+		return details, err
+	}
+	if err := tx.Table("apartment_amenities").
+		Joins("JOIN amenities ON amenities.id = apartment_amenities.amenity_id").
+		Where("apartment_amenities.apartment_id = ?", apartmentID).
+		Pluck("amenities.name", &details.Amenities).Error; err != nil {
+		return details, err
+	}
+	if err := tx.Table("apartment_house_rules").
+		Joins("JOIN house_rules ON house_rules.id = apartment_house_rules.house_rule_id").
+		Where("apartment_house_rules.apartment_id = ?", apartmentID).
+		Pluck("house_rules.rule", &details.HouseRules).Error; err != nil {
+		return details, err
+	}
+	if err := tx.Model(&model.ApartmentImage{}).Where("apartment_id = ?", apartmentID).
+		Order("id").Pluck("image_url", &details.Images).Error; err != nil {
+		return details, err
+	}
+	err := tx.Model(&model.ApartmentVideo{}).Where("apartment_id = ?", apartmentID).
+		Order("id").Pluck("video_url", &details.Videos).Error
+	return details, err
+}
+
+// Apply writes ch to the live listing apt, including amenities, house rules,
+// media and a price history entry for a new rent. It reports whether the rent
+// dropped so the caller can alert wishlists once the transaction commits.
+func Apply(tx *gorm.DB, apt *model.Apartment, ch Changes, changedBy string) (priceDropped bool, oldPrice float64, err error) {
+	oldPrice = apt.RentPrice
+	if ch.PropertyName != nil {
+		apt.PropertyName = *ch.PropertyName
+	}
+	if ch.Address != nil {
+		apt.Address = *ch.Address
+	}
+	if ch.PropertyType != nil {
+		apt.PropertyType = *ch.PropertyType
+	}
+	if ch.RentPrice != nil {
+		apt.RentPrice = *ch.RentPrice
+	}
+	if ch.LocationLink != nil {
+		apt.LocationLink = *ch.LocationLink
+	}
+	if ch.Landmarks != nil {
+		apt.Landmarks = *ch.Landmarks
+	}
+	if ch.Latitude != nil {
+		apt.Latitude = *ch.Latitude
+	}
+	if ch.Longitude != nil {
+		apt.Longitude = *ch.Longitude
+	}
+	if err := tx.Save(apt).Error; err != nil {
+		return false, oldPrice, err
+	}
+
+	if ch.Amenities != nil {
+		if err := tx.Where("apartment_id = ?", apt.ID).Delete(&model.ApartmentAmenity{}).Error; err != nil {
+			return false, oldPrice, err
+		}
+		for _, name := range *ch.Amenities {
+			var amenity model.Amenity
+			if err := tx.FirstOrCreate(&amenity, model.Amenity{Name: name}).Error; err != nil {
+				return false, oldPrice, err
+			}
+			if err := tx.Create(&model.ApartmentAmenity{ApartmentID: apt.ID, AmenityID: amenity.ID}).Error; err != nil {
+				return false, oldPrice, err
+			}
+		}
+	}
+
+	if ch.HouseRules != nil {
+		if err := tx.Where("apartment_id = ?", apt.ID).Delete(&model.ApartmentHouseRule{}).Error; err != nil {
+			return false, oldPrice, err
+		}
+		for _, rule := range *ch.HouseRules {
+			var houseRule model.HouseRule
+			if err := tx.FirstOrCreate(&houseRule, model.HouseRule{Rule: rule}).Error; err != nil {
+				return false, oldPrice, err
+			}
+			if err := tx.Create(&model.ApartmentHouseRule{ApartmentID: apt.ID, HouseRuleID: houseRule.ID}).Error; err != nil {
+				return false, oldPrice, err
+			}
+		}
+	}
+
+	if ch.Images != nil {
+		if err := tx.Where("apartment_id = ?", apt.ID).Delete(&model.ApartmentImage{}).Error; err != nil {
+			return false, oldPrice, err
+		}
+		for _, url := range *ch.Images {
+			if err := tx.Create(&model.ApartmentImage{ApartmentID: apt.ID, ImageURL: url}).Error; err != nil {
+				return false, oldPrice, err
+			}
+		}
+	}
+
+	if ch.Videos != nil {
+		if err := tx.Where("apartment_id = ?", apt.ID).Delete(&model.ApartmentVideo{}).Error; err != nil {
+			return false, oldPrice, err
+		}
+		for _, url := range *ch.Videos {
+			if err := tx.Create(&model.ApartmentVideo{ApartmentID: apt.ID, VideoURL: url}).Error; err != nil {
+				return false, oldPrice, err
+			}
+		}
+	}
+
+	priceDropped, err = pricehistory.Change(tx, apt.ID, oldPrice, apt.RentPrice, changedBy)
+	return priceDropped, oldPrice, err
+}
+
+// Submit stores ch as the pending revision of apt, folding it into the
+// pending revision if there already is one. Changes that match the live
+// listing are dropped, and ErrNoChanges is returned if nothing is left. A
+// price-only revision within the auto-approve threshold is applied at once;
+// priceDropped is then set as for Apply.
+func Submit(tx *gorm.DB, apt *model.Apartment, landlordUID string, ch Changes) (r model.ApartmentRevision, priceDropped bool, err error) {
+	err = tx.Where("apartment_id = ? AND status = ?", apt.ID, StatusPending).First(&r).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		r = model.ApartmentRevision{ApartmentID: apt.ID, LandlordUID: landlordUID, Status: StatusPending}
+	case err != nil:
+		return r, false, err
+	default:
+		pending, err := Decode(r)
+		if err != nil {
+			return r, false, err
+		}
+		ch = pending.merge(ch)
+	}
+
+	current, err := load(tx, apt.ID)
+	if err != nil {
+		return r, false, err
+	}
+	diff := Diff(current, ch)
+	if len(diff) == 0 {
+		if r.ID != 0 {
+			// The edit undid everything pending
+			err = tx.Model(&r).Updates(map[string]interface{}{"status": StatusWithdrawn}).Error
+			r.Status = StatusWithdrawn
+			return r, false, err
+		}
+		return r, false, ErrNoChanges
+	}
+
+	// Keep only what differs so reviewers see the real edit
+	ch = trim(current, ch)
+	encoded, err := json.Marshal(ch)
+	if err != nil {
+		return r, false, err
+	}
+	r.Changes = string(encoded)
+
+	baseline, err := approvedPrice(tx, current.Apartment)
+	if err != nil {
+		return r, false, err
+	}
+	if autoApprovable(diff, baseline) {
+		if priceDropped, _, err = Apply(tx, apt, ch, landlordUID); err != nil {
+			return r, false, err
+		}
+		now := time.Now()
+		r.Status, r.AutoApproved, r.ReviewedAt = StatusApproved, true, &now
+	}
+	return r, priceDropped, tx.Save(&r).Error
+}
+
+// Review approves or rejects a pending revision. Approving applies it to
+// the live listing. The returned apartment reflects the outcome.
+func Review(tx *gorm.DB, revisionID uint, approve bool, adminUID, note string) (r model.ApartmentRevision, apt model.Apartment, priceDropped bool, oldPrice float64, err error) {
+	if err = tx.First(&r, revisionID).Error; err != nil {
+		return
+	}
+	if err = tx.First(&apt, r.ApartmentID).Error; err != nil {
+		return
+	}
+
+	now := time.Now()
+	status := StatusRejected
+	if approve {
+		status = StatusApproved
+	}
+	result := tx.Model(&model.ApartmentRevision{}).
+		Where("id = ? AND status = ?", r.ID, StatusPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": adminUID,
+			"review_note": note,
+			"reviewed_at": now,
+		})
+	if err = result.Error; err != nil {
+		return
+	}
+	if result.RowsAffected == 0 {
+		err = ErrNotPending
+		return
+	}
+	r.Status, r.ReviewedBy, r.ReviewNote, r.ReviewedAt = status, adminUID, note, &now
+
+	if approve {
+		var ch Changes
+		if ch, err = Decode(r); err != nil {
+			return
+		}
+		priceDropped, oldPrice, err = Apply(tx, &apt, ch, adminUID)
+	}
+	return
+}
+
+// Withdraw cancels landlordUID's pending revision.
+func Withdraw(db *gorm.DB, revisionID uint, landlordUID string) (bool, error) {
+	result := db.Model(&model.ApartmentRevision{}).
+		Where("id = ? AND landlord_uid = ? AND status = ?", revisionID, landlordUID, StatusPending).
+		Update("status", StatusWithdrawn)
+	return result.RowsAffected > 0, result.Error
+}
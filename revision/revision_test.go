@@ -0,0 +1,99 @@
+package revision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/moderation"
+	"github.com/Conding-Student/backend/pricehistory"
+	"github.com/Conding-Student/backend/testdb"
+
+	"gorm.io/gorm"
+)
+
+func approvedApartment(t *testing.T, db *gorm.DB, rent float64) model.Apartment {
+	t.Helper()
+	apt := model.Apartment{
+		Uid: "landlord-1", UserID: "landlord-1", PropertyName: "Revision Flats", Address: "5 Test St",
+		PropertyType: "Apartment", RentPrice: rent, LocationLink: "-", Landmarks: "-",
+		Allowed_Gender: "Any", Status: moderation.StatusApproved, Availability: "Available",
+	}
+	if err := db.Create(&apt).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := pricehistory.Record(db, apt.ID, nil, rent, "landlord-1"); err != nil {
+		t.Fatal(err)
+	}
+	admin := moderation.Actor{UID: "admin-1", Role: moderation.RoleAdmin}
+	if err := moderation.Record(db, apt.ID, moderation.StatusPending, moderation.StatusApproved, admin, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	return apt
+}
+
+func TestAutoApproveMeasuresFromTheApprovedPrice(t *testing.T) {
+	db := testdb.Open(t)
+	t.Setenv("REVISION_AUTO_APPROVE_PRICE_PCT", "5")
+	apt := approvedApartment(t, db, 10000)
+
+	// 4% over the approved rent goes live at once
+	step := 10400.0
+	r, _, err := Submit(db, &apt, "landlord-1", Changes{RentPrice: &step})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != StatusApproved || !r.AutoApproved {
+		t.Fatalf("first step is %s (auto %v), want auto-approved", r.Status, r.AutoApproved)
+	}
+
+	// Another 4% is within 5% of the live rent but 8% over the approved one
+	time.Sleep(time.Millisecond)
+	next := 10800.0
+	r, _, err = Submit(db, &apt, "landlord-1", Changes{RentPrice: &next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != StatusPending {
+		t.Errorf("second step is %s, want pending", r.Status)
+	}
+
+	var live model.Apartment
+	db.First(&live, apt.ID)
+	if live.RentPrice != step {
+		t.Errorf("live rent is %g, want %g", live.RentPrice, step)
+	}
+}
+
+func TestMediaWaitsForReview(t *testing.T) {
+	db := testdb.Open(t)
+	apt := approvedApartment(t, db, 10000)
+	if err := db.Create(&model.ApartmentImage{ApartmentID: apt.ID, ImageURL: "https://cdn.test/old.jpg"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	images := []string{"https://cdn.test/new.jpg"}
+	r, _, err := Submit(db, &apt, "landlord-1", Changes{Images: &images})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != StatusPending {
+		t.Fatalf("revision is %s, want pending", r.Status)
+	}
+
+	live := func() []string {
+		var urls []string
+		db.Model(&model.ApartmentImage{}).Where("apartment_id = ?", apt.ID).Pluck("image_url", &urls)
+		return urls
+	}
+	if got := live(); len(got) != 1 || got[0] != "https://cdn.test/old.jpg" {
+		t.Errorf("live images before review are %v, want the old one", got)
+	}
+
+	if _, _, _, _, err := Review(db, r.ID, true, "admin-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := live(); len(got) != 1 || got[0] != "https://cdn.test/new.jpg" {
+		t.Errorf("live images after approval are %v, want the new one", got)
+	}
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/Conding-Student/backend/controller"
+	billingcontroller "github.com/Conding-Student/backend/controller/billing"
+	"github.com/Conding-Student/backend/ledger"
+	"github.com/Conding-Student/backend/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func BillingRoutes(app *fiber.App, paymentService *controller.PayMongoService) {
+	// Invoices are paid through the regular checkout and settled by a paid hook
+	billingcontroller.Payments = paymentService
+	controller.RegisterPaidHook(billingcontroller.SettleInvoice)
+	controller.RegisterPaidHook(billingcontroller.ActivatePromotion)
+	controller.RegisterPaidHook(ledger.PostPayment)
+	controller.RegisterRefundedHook(billingcontroller.CancelPromotion)
+	go billingcontroller.ManageRentInvoices()
+
+	app.Get("/tenant/invoices", middleware.AuthMiddleware, middleware.RequireRole("Tenant"), billingcontroller.FetchTenantInvoices) // ?status=unpaid|overdue|paid
+	app.Post("/tenant/invoices/:id/pay", middleware.AuthMiddleware, middleware.RequireRole("Tenant"), billingcontroller.PayInvoice)
+	app.Get("/landlord/invoices", middleware.AuthMiddleware, middleware.RequireRole("Landlord"), billingcontroller.FetchLandlordInvoices) // ?status=&apartment_id=
+
+	app.Get("/landlord/promotions/packages", middleware.AuthMiddleware, middleware.RequireRole("Landlord"), billingcontroller.FetchPromotionPackages)
+	app.Post("/landlord/apartments/:id/promote", middleware.AuthMiddleware, middleware.RequireRole("Landlord"), billingcontroller.PromoteApartment)
+	app.Get("/landlord/promotions", middleware.AuthMiddleware, middleware.RequireRole("Landlord"), billingcontroller.FetchLandlordPromotions) // ?apartment_id=
+
+	app.Get("/landlord/balance", middleware.AuthMiddleware, middleware.RequireRole("Landlord"), billingcontroller.FetchLandlordBalance) // ?from=&to=
+
+	app.Post("/admin/payouts", middleware.AuthMiddleware, middleware.RequireRole("Admin"), billingcontroller.RecordPayout)
+	app.Get("/admin/payouts", middleware.AuthMiddleware, middleware.RequireRole("Admin"), billingcontroller.FetchPayouts)                // ?landlord_id=
+	app.Get("/admin/reconciliation", middleware.AuthMiddleware, middleware.RequireRole("Admin"), billingcontroller.ReconciliationReport) // ?from=&to=&format=csv
+}
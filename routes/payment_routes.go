@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"github.com/Conding-Student/backend/controller"
+	"github.com/Conding-Student/backend/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func PaymentRoutes(app *fiber.App, paymentService *controller.PayMongoService) {
+	// Settles transactions whose webhook never arrived
+	go paymentService.RunReconciler()
+
+	app.Post("/api/create-source", middleware.AuthMiddleware, paymentService.CreateSource)
+	app.Post("/api/webhook", paymentService.HandleWebhook)
+	app.Get("/api/transaction/:source_id", middleware.AuthMiddleware, paymentService.GetTransaction)
+	app.Get("/api/transactions", middleware.AuthMiddleware, paymentService.GetTransactions)
+	app.Get("/api/get-all/transaction", middleware.AuthMiddleware, middleware.RequireRole("Admin"), paymentService.GetAllTransactions)
+	app.Post("/admin/transactions/:id/refund", middleware.AuthMiddleware, middleware.RequireRole("Admin"), paymentService.RefundTransaction) // full refund when amount is omitted
+	app.Get("/admin/transactions/:id/refunds", middleware.AuthMiddleware, middleware.RequireRole("Admin"), paymentService.GetTransactionRefunds)
+	app.Get("/admin/reconciliation/transactions", middleware.AuthMiddleware, middleware.RequireRole("Admin"), paymentService.GetReconciliationLogs) // ?resolution=&transaction_id=&discrepancies=true
+	app.Post("/admin/reconciliation/transactions/run", middleware.AuthMiddleware, middleware.RequireRole("Admin"), paymentService.ReconcileNow)
+
+	// PayMongo redirect routes
+	app.Get("/success", paymentService.HandleSuccessRedirect)
+	app.Get("/failed", paymentService.HandleFailedRedirect) // Optional for failed redirects
+
+	// Offline checkout page, only answers when PAYMENT_PROVIDER=fake
+	app.Get("/fake-checkout/:id", paymentService.HandleFakeCheckout)
+}
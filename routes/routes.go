@@ -26,8 +26,10 @@ import (
 
 	tenantscontroller "github.com/Conding-Student/backend/controller/tenants"
 	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/savedsearch"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	//"golang.org/x/crypto/nacl/auth"
 )
 
@@ -39,69 +41,97 @@ func AppRoutes(app *fiber.App) {
 	//go tenantscontroller.DeleteExpiredInquiries()
 	go landlordcontroller.ManageApartmentExpirations()
 	go landlordcontroller.ManageExpiredDeletions()
+	go savedsearch.RunDigest()
+
+	// Role guards, always registered after middleware.AuthMiddleware
+	adminOnly := middleware.RequireRole("Admin")
+	landlordOnly := middleware.RequireRole("Landlord")
+	tenantOnly := middleware.RequireRole("Tenant")
+	tenantOrLandlord := middleware.RequireRole("Tenant", "Landlord")
 
 	//////////////////// Landlord //////////////////
 
 	/////////////////// PUT ////////////////////////
-	app.Put("/apartments/:id/media", middleware.AuthMiddleware, landlordcontroller.UpdateApartmentMedia) // Adding images and videos
-	app.Put("/landlord/apartmentupdate/:id", middleware.AuthMiddleware, landlordcontroller.UpdateApartment)
-	app.Put("/landlord/apartments/updateavailability/:id", middleware.AuthMiddleware, landlordcontroller.UpdateApartmentAvailability) // Update the apartment details
-	app.Put("/landlord/inquiry/status", middleware.AuthMiddleware, landlordcontroller_inquiries.UpdateInquiryStatusByLandlord)
-	app.Put("/update-inquiry-status/:uid", landlordcontroller.FetchInquiriesByLandlord) // Approve/Reject a users inquiry
+	app.Put("/apartments/:id/media", middleware.AuthMiddleware, landlordOnly, landlordcontroller.UpdateApartmentMedia) // Adding images and videos
+	app.Put("/landlord/apartmentupdate/:id", middleware.AuthMiddleware, landlordOnly, landlordcontroller.UpdateApartment)
+	app.Put("/landlord/apartments/updateavailability/:id", middleware.AuthMiddleware, landlordOnly, landlordcontroller.UpdateApartmentAvailability) // Update the apartment details
+	app.Put("/landlord/inquiry/status", middleware.AuthMiddleware, landlordOnly, landlordcontroller_inquiries.UpdateInquiryStatusByLandlord)
+	app.Put("/update-inquiry-status/:uid", middleware.AuthMiddleware, landlordOnly, landlordcontroller.FetchInquiriesByLandlord) // Approve/Reject a users inquiry
 
 	/////////////////// POST ////////////////////////
-	app.Post("/property/add", middleware.AuthMiddleware, landlordcontroller.CreateApartment) //insert application for landlord apartment
+	app.Post("/property/add", middleware.AuthMiddleware, landlordOnly, landlordcontroller.CreateApartment)     //insert application for landlord apartment
+	app.Post("/property/import", middleware.AuthMiddleware, landlordOnly, landlordcontroller.ImportApartments) // bulk add listings from CSV or JSON Lines; ?dry_run=true only checks
 	//app.Post("/create/businessname", middleware.AuthMiddleware, landlordcontroller2.UpdateBusinessName)             // insert business name
 	//app.Post("/create/businesspermit", middleware.AuthMiddleware, landlordcontroller2.SetUpdateBusinessPermitImage) //business permit
 
-	app.Post("/admin/login", admincontroller.LoginHandler)
-	app.Post("/bealandlord", middleware.AuthMiddleware, landlordcontroller.RegisterLandlord)                    //business permit
-	app.Get("/property/get", middleware.AuthMiddleware, landlordcontroller.FetchApartmentsByLandlord)           //Property get by landlord
-	app.Get("/tenants/inquiry/display", middleware.AuthMiddleware, landlordcontroller.FetchInquiriesByLandlord) // Fetch tenants inquiry
+	app.Post("/bealandlord", middleware.AuthMiddleware, tenantOnly, landlordcontroller.RegisterLandlord)                                        //business permit
+	app.Get("/property/get", middleware.AuthMiddleware, landlordOnly, landlordcontroller.FetchApartmentsByLandlord)                             //Property get by landlord
+	app.Get("/tenants/inquiry/display", middleware.AuthMiddleware, landlordOnly, landlordcontroller.FetchInquiriesByLandlord)                   // Fetch tenants inquiry
+	app.Post("/landlord/apartments/:id/resubmit", middleware.AuthMiddleware, landlordOnly, landlordcontroller.ResubmitApartment)                // send an edited rejected listing back for review
+	app.Get("/landlord/apartments/:id/moderation", middleware.AuthMiddleware, landlordOnly, landlordcontroller.FetchApartmentModerationHistory) // review decisions on a listing
+	app.Get("/landlord/apartments/:id/revisions", middleware.AuthMiddleware, landlordOnly, landlordcontroller.FetchApartmentRevisions)          // edits to an approved listing and their review state
+	app.Delete("/landlord/revisions/:id", middleware.AuthMiddleware, landlordOnly, landlordcontroller.WithdrawApartmentRevision)                // withdraw a pending edit
+	app.Get("/landlord/apartments/:id/units", middleware.AuthMiddleware, landlordOnly, landlordcontroller.FetchApartmentUnits)                  // rooms and bedspaces of a listing
+	app.Post("/landlord/apartments/:id/units", middleware.AuthMiddleware, landlordOnly, landlordcontroller.CreateApartmentUnit)
+	app.Put("/landlord/apartments/:id/units/:unitId", middleware.AuthMiddleware, landlordOnly, landlordcontroller.UpdateApartmentUnit)
+	app.Delete("/landlord/apartments/:id/units/:unitId", middleware.AuthMiddleware, landlordOnly, landlordcontroller.DeleteApartmentUnit)
+	app.Get("/landlord/apartments/:id/availability", middleware.AuthMiddleware, landlordOnly, landlordcontroller.FetchAvailabilityWindows)  // availability calendar of a listing
+	app.Post("/landlord/apartments/:id/availability", middleware.AuthMiddleware, landlordOnly, landlordcontroller.CreateAvailabilityWindow) // open or block dates
+	app.Delete("/landlord/apartments/:id/availability/:windowId", middleware.AuthMiddleware, landlordOnly, landlordcontroller.DeleteAvailabilityWindow)
 
 	/////////////////// DELETE ////////////////////////
-	app.Delete("/apartment/delete/:id", middleware.AuthMiddleware, landlordcontroller.DeleteApartment)       // landlord confirms rejected apartment
-	app.Delete("/apartment/deleteany/:id", middleware.AuthMiddleware, landlordcontroller.DeleteApartmentAny) // landlord delete any apartment
+	app.Delete("/apartment/delete/:id", middleware.AuthMiddleware, landlordOnly, landlordcontroller.DeleteApartment)       // landlord confirms rejected apartment
+	app.Delete("/apartment/deleteany/:id", middleware.AuthMiddleware, landlordOnly, landlordcontroller.DeleteApartmentAny) // landlord delete any apartment
 
 	//////////////////// Landlord //////////////////
 
 	//////////////////// Admin //////////////////
 
 	//////////////////// PUT //////////////////
-	app.Put("/users/update", admincontroller2.UpdateUserDetails)                  // Updating user values in the admin
-	app.Put("/admin/update-profile", admincontroller.UpdateAdminProfile)          // updating admin email or password
-	app.Put("/admin/apartments/update/:id", admincontroller.UpdateApartmentInfo)  // Update the apartment details
-	app.Put("/admin/promoting/account/:uid", admincontroller.UpdateUserType)      //update user type tenant / landlord
-	app.Put("/admin/verifying/validid/:uid", admincontroller.UpdateAccountStatus) //update account status tenant / landlord
-	app.Put("/apartments/verify/:id", admincontroller.VerifyApartment)            // Approve/Reject an apartment
-	app.Put("/user/verify/:id", admincontroller.VerifyUsers)                      // Approve/Reject a users
+	app.Put("/users/update", middleware.AuthMiddleware, adminOnly, admincontroller2.UpdateUserDetails)                  // Updating user values in the admin
+	app.Put("/admin/update-profile", middleware.AuthMiddleware, adminOnly, admincontroller.UpdateAdminProfile)          // updating admin email or password
+	app.Put("/admin/apartments/update/:id", middleware.AuthMiddleware, adminOnly, admincontroller.UpdateApartmentInfo)  // Update the apartment details
+	app.Put("/admin/promoting/account/:uid", middleware.AuthMiddleware, adminOnly, admincontroller.UpdateUserType)      //update user type tenant / landlord
+	app.Put("/admin/verifying/validid/:uid", middleware.AuthMiddleware, adminOnly, admincontroller.UpdateAccountStatus) //update account status tenant / landlord
+	app.Put("/apartments/verify/:id", middleware.AuthMiddleware, adminOnly, admincontroller.VerifyApartment)            // Approve/Reject an apartment
+	app.Put("/user/verify/:id", middleware.AuthMiddleware, adminOnly, admincontroller.VerifyUsers)                      // Approve/Reject a users
 
 	//////////////////// POST //////////////////
-	app.Post("/admin/register", admincontroller.RegisterAdmin)                               // register admin
-	app.Post("/admin/login", admincontroller.LoginHandler)                                   // login admin //password: yourSecurePassword123
-	app.Put("/accept/landlordrequest/:uid", landlordcontroller2.VerifyLandlordUsingAdmin)    // aacepting landlord request
-	app.Post("/rejecting/landlordrequest/:uid", landlordcontroller2.RejectLandlordRequest)   // rejecting landlord request
-	app.Post("/rejecting/landlordApartment/:id", landlordcontroller2.RejectApartmentRequest) // rejecting landlord request
+	app.Post("/admin/register", middleware.AuthMiddleware, adminOnly, admincontroller.RegisterAdmin)                               // register admin
+	app.Post("/admin/login", admincontroller.LoginHandler)                                                                         // login admin //password: yourSecurePassword123
+	app.Put("/accept/landlordrequest/:uid", middleware.AuthMiddleware, adminOnly, landlordcontroller2.VerifyLandlordUsingAdmin)    // aacepting landlord request
+	app.Post("/rejecting/landlordrequest/:uid", middleware.AuthMiddleware, adminOnly, landlordcontroller2.RejectLandlordRequest)   // rejecting landlord request
+	app.Post("/rejecting/landlordApartment/:id", middleware.AuthMiddleware, adminOnly, landlordcontroller2.RejectApartmentRequest) // rejecting landlord request
+	app.Post("/admin/moderation/:id/claim", middleware.AuthMiddleware, adminOnly, admincontroller.ClaimApartmentReview)            // reserve a listing for review
+	app.Post("/admin/moderation/:id/unclaim", middleware.AuthMiddleware, adminOnly, admincontroller.UnclaimApartmentReview)        // release it
+	app.Post("/admin/revisions/:id/approve", middleware.AuthMiddleware, adminOnly, admincontroller.ApproveApartmentRevision)       // apply a landlord's edit to the live listing
+	app.Post("/admin/revisions/:id/reject", middleware.AuthMiddleware, adminOnly, admincontroller.RejectApartmentRevision)         // discard it, note required
 	app.Post("/firebase/login", authcontroller.VerifyFirebaseTokenAdmin)
 
 	//////////////////// GET //////////////////
-	app.Get("/adminuserinfo/search", admincontroller2.GetFilteredUserDetailspart2)
-	app.Get("/api/stats/users-by-year", admincontroller4.GetUserStatsByYear)                            // chart per year
-	app.Get("/display/users", admincontroller2.GetFilteredUserDetails)                                  // Fetch all users can be filtered through name=John,accountname=artem&user_type=Landlord                                //# Search by fullname GET /users/search?field=fullname&search_term=Artem# Search by email	GET /users/search?field=email&search_term=example.com # Search by phone number GET /users/search?field=phone_number&search_term=+12345
-	app.Get("/admin/count/:user_type", admincontroller2.CountUsersByType)                               //displaying number of users by usertype
-	app.Get("/admin/count-user/:account_status/:user_type", admincontroller2.CountUsersByStatusAndType) //displaying number of users whose verified and still pending
-	app.Get("/admin/count_apartment/:status", admincontroller2.CountApartmentsByStatus)                 //displaying number of users by usertype
-	app.Get("/admin/count-property-type/:property_type", admincontroller2.CountApartmentsByPropertyType)
-	app.Get("/admin/count-apartment/:status/:property_type", admincontroller2.CountApartmentsByStatusAndType) //displaying toal number of both pending & property type
-	app.Get("/admin/apartments/details", admincontroller3.GetFilteredApartments)                              //Get complete apartment details along with other data and can be filtered
-	app.Get("/apartments/pending", admincontroller.GetPendingApartments)                                      // Fetch unverified apartments
-	app.Get("/user/pending", admincontroller.GetPendingUsers)                                                 // Fetch unverified users
-	app.Get("/admin/apartmentfilter", admincontroller2.Apartmentfilteradmin)
-	app.Get("/landlord/profileid/:uid", admincontroller2.GetLatestLandlordID)
+	app.Get("/adminuserinfo/search", middleware.AuthMiddleware, adminOnly, admincontroller2.GetFilteredUserDetailspart2)
+	app.Get("/api/stats/users-by-year", middleware.AuthMiddleware, adminOnly, admincontroller4.GetUserStatsByYear)                            // chart per year
+	app.Get("/api/stats/median-rent", middleware.AuthMiddleware, adminOnly, admincontroller4.GetMedianRentByPropertyType)                     // ?months=12&property_type= median rent per month and property type
+	app.Get("/display/users", middleware.AuthMiddleware, adminOnly, admincontroller2.GetFilteredUserDetails)                                  // Fetch all users can be filtered through name=John,accountname=artem&user_type=Landlord                                //# Search by fullname GET /users/search?field=fullname&search_term=Artem# Search by email	GET /users/search?field=email&search_term=example.com # Search by phone number GET /users/search?field=phone_number&search_term=+12345
+	app.Get("/admin/count/:user_type", middleware.AuthMiddleware, adminOnly, admincontroller2.CountUsersByType)                               //displaying number of users by usertype
+	app.Get("/admin/count-user/:account_status/:user_type", middleware.AuthMiddleware, adminOnly, admincontroller2.CountUsersByStatusAndType) //displaying number of users whose verified and still pending
+	app.Get("/admin/count_apartment/:status", middleware.AuthMiddleware, adminOnly, admincontroller2.CountApartmentsByStatus)                 //displaying number of users by usertype
+	app.Get("/admin/count-property-type/:property_type", middleware.AuthMiddleware, adminOnly, admincontroller2.CountApartmentsByPropertyType)
+	app.Get("/admin/count-apartment/:status/:property_type", middleware.AuthMiddleware, adminOnly, admincontroller2.CountApartmentsByStatusAndType) //displaying toal number of both pending & property type
+	app.Get("/admin/apartments/details", middleware.AuthMiddleware, adminOnly, admincontroller3.GetFilteredApartments)                              //Get complete apartment details along with other data and can be filtered
+	app.Get("/apartments/pending", middleware.AuthMiddleware, adminOnly, admincontroller.GetPendingApartments)                                      // Fetch unverified apartments
+	app.Get("/admin/moderation/queue", middleware.AuthMiddleware, adminOnly, admincontroller.GetModerationQueue)                                    // ?claimed=mine|unclaimed, oldest submission first
+	app.Get("/admin/moderation/reasons", middleware.AuthMiddleware, adminOnly, admincontroller.GetRejectionReasons)                                 // rejection reason codes
+	app.Get("/admin/moderation/:id/history", middleware.AuthMiddleware, adminOnly, admincontroller.GetModerationHistory)                            // every status change of a listing
+	app.Get("/admin/revisions", middleware.AuthMiddleware, adminOnly, admincontroller.GetApartmentRevisions)                                        // ?status=pending edits to approved listings
+	app.Get("/admin/revisions/:id", middleware.AuthMiddleware, adminOnly, admincontroller.GetApartmentRevision)                                     // field-by-field comparison
+	app.Get("/user/pending", middleware.AuthMiddleware, adminOnly, admincontroller.GetPendingUsers)                                                 // Fetch unverified users
+	app.Get("/admin/apartmentfilter", middleware.AuthMiddleware, adminOnly, admincontroller2.Apartmentfilteradmin)
+	app.Get("/landlord/profileid/:uid", middleware.AuthMiddleware, adminOnly, admincontroller2.GetLatestLandlordID)
 
 	//////////////////// DELETE //////////////////
-	app.Delete("/admin/apartment/delete/:id", admincontroller3.DeleteApartmentByID) // Delete speific apartment
-	app.Delete("/admin/user/:uid", admincontroller2.SoftDeleteUser)                 // Mark the account status as deleted
+	app.Delete("/admin/apartment/delete/:id", middleware.AuthMiddleware, adminOnly, admincontroller3.DeleteApartmentByID) // Delete speific apartment
+	app.Delete("/admin/user/:uid", middleware.AuthMiddleware, adminOnly, admincontroller2.SoftDeleteUser)                 // Mark the account status as deleted
 
 	//////////////////// Admin //////////////////
 
@@ -111,12 +141,17 @@ func AppRoutes(app *fiber.App) {
 	app.Put("/api/user/update-contact", middleware.AuthMiddleware, landlordcontroller2.UpdateContactInfo)
 
 	//////////////////// POST //////////////////
-	app.Post("/create/validid", middleware.AuthMiddleware, all.SetValidID)
+	// multipart/form-data with purpose=listing_image|listing_video|valid_id|business_permit and file
+	app.Post("/media/uploads", middleware.AuthMiddleware, all.UploadMedia)
 	app.Post("/create/validid", middleware.AuthMiddleware, all.SetValidID)
 	app.Post("/signup", authcontroller.Signup) // Register a new us
 	//////////////////// GET //////////////////
 	app.Get("/all/filter-apartments/", all.FetchApprovedApartmentsForTenant) //http://localhost:3000/all/filter-apartments?amenities=Wifi,Laundry&house_rules=No Smoking&min_price=3000&max_price=8000&property_types=Condo,Apartment
+	app.Get("/all/filter-apartments/facets", all.FetchApartmentFacets)       // counts per filter value, same filters plus ?price_buckets=3000,5000,8000
 	app.Get("/allapartments/search", all.SearchApartments)
+	app.Get("/all/search/suggest", all.SuggestSearch)                         // ?q= typeahead, call per keystroke
+	app.Get("/all/apartments/nearby", all.FetchNearbyApartments)              // ?lat=&lng=&radius_km= plus the filter-apartments filters
+	app.Get("/all/apartments/bounds", all.FetchApartmentsInBounds)            // ?min_lat=&min_lng=&max_lat=&max_lng= (map viewport)
 	app.Get("/all/apartmentfulldetails/:id", all.FetchSingleApartmentDetails) // view all of the specific apartment details
 
 	//////////////////// FOR ALL //////////////////
@@ -126,23 +161,34 @@ func AppRoutes(app *fiber.App) {
 	//////////////////// PUT //////////////////
 
 	//////////////////// POST //////////////////
-	app.Post("/create/inquiry", middleware.AuthMiddleware, tenantscontroller.CreateInquiry)
+	app.Post("/create/inquiry", middleware.AuthMiddleware, tenantOnly, tenantscontroller.CreateInquiry)
 	// app.Post("/tenant/delete-inquiry", middleware.AuthMiddleware, tenantscontroller.DeleteInquiryAfterViewingNotification)
-	app.Post("/add/wishlist", middleware.AuthMiddleware, tenantscontroller.AddToWishlist)
-	app.Post("/add/recentlyviewed", middleware.AuthMiddleware, tenantscontroller.AddToRecentlyViewed)
-	app.Get("/get/recently-viewed", middleware.AuthMiddleware, controller.FetchRecentlyViewed)
+	app.Post("/add/wishlist", middleware.AuthMiddleware, tenantOnly, tenantscontroller.AddToWishlist)
+	app.Post("/add/recentlyviewed", middleware.AuthMiddleware, tenantOnly, tenantscontroller.AddToRecentlyViewed)
+	app.Get("/get/recently-viewed", middleware.AuthMiddleware, tenantOnly, controller.FetchRecentlyViewed)
 	//////////////////// GET //////////////////
 	// app.Get("/tenant/inquiries/count-status", middleware.AuthMiddleware, tenantscontroller.CountAcceptedOrRejectedInquiries)
 	// app.Get("/tenant/inquiries/get-notification", middleware.AuthMiddleware, tenantscontroller.GetAllinquiries) // Display all inquiries
 	app.Get("/api/apartments/Approved", tenantscontroller.FetchApprovedApartmentsForTenant) //Display all the Approved apartment
-	app.Get("/get/wishlist", middleware.AuthMiddleware, tenantscontroller.FetchwishlistForTenant)
+	app.Get("/get/wishlist", middleware.AuthMiddleware, tenantOnly, tenantscontroller.FetchwishlistForTenant)
 
 	//////////////////// DELETE //////////////////
-	app.Delete("/wishlist/:apartment_id", middleware.AuthMiddleware, tenantscontroller.RemoveFromWishlist)
+	app.Delete("/wishlist/:apartment_id", middleware.AuthMiddleware, tenantOnly, tenantscontroller.RemoveFromWishlist)
+
+	// Saved searches with new-listing alerts (frequency: instant or daily)
+	app.Post("/tenants/saved-searches", middleware.AuthMiddleware, tenantOnly, tenantscontroller.CreateSavedSearch)
+	app.Get("/tenants/saved-searches", middleware.AuthMiddleware, tenantOnly, tenantscontroller.FetchSavedSearches)
+	app.Put("/tenants/saved-searches/:id", middleware.AuthMiddleware, tenantOnly, tenantscontroller.UpdateSavedSearch)
+	app.Post("/tenants/saved-searches/:id/pause", middleware.AuthMiddleware, tenantOnly, tenantscontroller.PauseSavedSearch)
+	app.Post("/tenants/saved-searches/:id/resume", middleware.AuthMiddleware, tenantOnly, tenantscontroller.ResumeSavedSearch)
+	app.Delete("/tenants/saved-searches/:id", middleware.AuthMiddleware, tenantOnly, tenantscontroller.DeleteSavedSearch)
+	app.Get("/tenants/recommendations", middleware.AuthMiddleware, tenantOnly, tenantscontroller.FetchRecommendations)
 
 	//////////////////// Tenant //////////////////
 
 	app.Post("/firebase", authcontroller.VerifyFirebaseToken)
+	app.Post("/auth/refresh", authcontroller.RefreshTokenHandler) // rotate refresh token, returns a new access token
+	app.Post("/auth/logout", authcontroller.LogoutHandler)        // revoke the refresh token family
 
 	// app.Post("/api/send-notification", func(c *fiber.Ctx) error {
 	// 	type RequestBody struct {
@@ -208,7 +254,8 @@ func AppRoutes(app *fiber.App) {
 	// 	return c.JSON(response)
 	// })
 
-	app.Post("/api/send-notification", func(c *fiber.Ctx) error {
+	// Chat push between a tenant and a landlord; the sender is always the caller
+	app.Post("/api/send-notification", middleware.AuthMiddleware, tenantOrLandlord, func(c *fiber.Ctx) error {
 		type RequestBody struct {
 			FcmToken       string `json:"fcmToken"`
 			Title          string `json:"title"` // Optional, may be set dynamically
@@ -235,6 +282,15 @@ func AppRoutes(app *fiber.App) {
 			})
 		}
 
+		userClaims := c.Locals("user").(jwt.MapClaims)
+		uid, _ := userClaims["uid"].(string)
+		if req.SenderId != "" && req.SenderId != uid {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "senderId does not match the logged-in user",
+			})
+		}
+		req.SenderId = uid
+
 		// Optional: Generate a default conversation ID if needed
 		if req.ConversationId == "" {
 			req.ConversationId = "general" // Or generate a UUID if needed
@@ -280,17 +336,17 @@ func AppRoutes(app *fiber.App) {
 	})
 
 	app.Post("/api/track-open/:logId", handlers.TrackNotificationOpenHandler)
-	app.Get("/notifications/:uid", config.GetNotificationsHandler)
+	app.Get("/notifications/:uid", middleware.AuthMiddleware, middleware.RequireSelf("uid"), config.GetNotificationsHandler)
 
-	app.Get("/unverifyAndResend/:uid", config.UnverifyAndResendHandler)
+	app.Get("/unverifyAndResend/:uid", middleware.AuthMiddleware, adminOnly, config.UnverifyAndResendHandler)
 
 	ratingGroup := app.Group("/api/ratings")
-	ratingGroup.Post("/confirm", middleware.AuthMiddleware, controller.ConfirmRental)
-	ratingGroup.Post("/submit", middleware.AuthMiddleware, controller.SubmitRating)
+	ratingGroup.Post("/confirm", middleware.AuthMiddleware, tenantOrLandlord, controller.ConfirmRental)
+	ratingGroup.Post("/submit", middleware.AuthMiddleware, tenantOnly, controller.SubmitRating)
 	ratingGroup.Get("/apartment/:id", controller.GetApartmentRatings)
-	ratingGroup.Get("/tenant/:id", middleware.AuthMiddleware, controller.GetTenantIDByRentalAgreementID)
-	ratingGroup.Get("/check", middleware.AuthMiddleware, controller.CheckRatingEligibility)
-	app.Get("/api/inquiries/has-inquiry", middleware.AuthMiddleware, controller.CheckHasInquiry)
+	ratingGroup.Get("/tenant/:id", middleware.AuthMiddleware, tenantOrLandlord, controller.GetTenantIDByRentalAgreementID)
+	ratingGroup.Get("/check", middleware.AuthMiddleware, tenantOrLandlord, controller.CheckRatingEligibility)
+	app.Get("/api/inquiries/has-inquiry", middleware.AuthMiddleware, tenantOnly, controller.CheckHasInquiry)
 
 	//Payment using Gcash routes
 
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/testdb"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRoleGuards(t *testing.T) {
+	db := testdb.Open(t)
+
+	users := []model.User{
+		{Uid: "tenant-1", Email: "tenant-1@example.com", UserType: "Tenant", Provider: "email", AccountStatus: "Verified"},
+		{Uid: "landlord-1", Email: "landlord-1@example.com", UserType: "Landlord", Provider: "email", AccountStatus: "Verified"},
+		{Uid: "admin-1", Email: "admin-1@example.com", UserType: "Admin", Provider: "email", AccountStatus: "Verified"},
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	newApartment := func(t *testing.T) uint {
+		apartment := model.Apartment{
+			Uid: "landlord-1", UserID: "landlord-1", PropertyName: "Guarded Place", Address: "1 Test St",
+			PropertyType: "Apartment", RentPrice: 5000, LocationLink: "-", Landmarks: "-",
+			Allowed_Gender: "Any", Status: "Pending", Availability: "Not Available",
+		}
+		if err := db.Create(&apartment).Error; err != nil {
+			t.Fatal(err)
+		}
+		return apartment.ID
+	}
+
+	tokens := map[string]string{
+		"Tenant":   testdb.Token(t, "tenant-1", "Tenant"),
+		"Landlord": testdb.Token(t, "landlord-1", "Landlord"),
+		"Admin":    testdb.Token(t, "admin-1", "Admin"),
+	}
+
+	app := fiber.New()
+	AppRoutes(app)
+
+	do := func(t *testing.T, method, path, token, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string // %d is replaced by a new pending apartment
+		role   string
+		body   string
+		want   int
+	}{
+		{"verify without token", http.MethodPut, "/apartments/verify/%d", "", `{"status":"Approved"}`, http.StatusUnauthorized},
+		{"tenant verifies", http.MethodPut, "/apartments/verify/%d", "Tenant", `{"status":"Approved"}`, http.StatusForbidden},
+		{"landlord verifies", http.MethodPut, "/apartments/verify/%d", "Landlord", `{"status":"Approved"}`, http.StatusForbidden},
+		{"admin verifies", http.MethodPut, "/apartments/verify/%d", "Admin", `{"status":"Approved"}`, http.StatusOK},
+
+		{"tenant deletes", http.MethodDelete, "/admin/apartment/delete/%d", "Tenant", "", http.StatusForbidden},
+		{"landlord deletes", http.MethodDelete, "/admin/apartment/delete/%d", "Landlord", "", http.StatusForbidden},
+		{"admin deletes", http.MethodDelete, "/admin/apartment/delete/%d", "Admin", "", http.StatusOK},
+
+		{"landlord registers as landlord", http.MethodPost, "/bealandlord", "Landlord", "{}", http.StatusForbidden},
+		{"tenant reads another user's notifications", http.MethodGet, "/notifications/landlord-1", "Tenant", "", http.StatusForbidden},
+
+		{"push without token", http.MethodPost, "/api/send-notification", "", `{"fcmToken":"fcm-1"}`, http.StatusUnauthorized},
+		{"admin pushes a chat message", http.MethodPost, "/api/send-notification", "Admin", `{"fcmToken":"fcm-1"}`, http.StatusForbidden},
+		{"tenant pushes as someone else", http.MethodPost, "/api/send-notification", "Tenant", `{"fcmToken":"fcm-1","senderId":"landlord-1"}`, http.StatusForbidden},
+		{"tenant pushes a chat message", http.MethodPost, "/api/send-notification", "Tenant", `{"fcmToken":"fcm-1"}`, http.StatusOK},
+		{"landlord pushes a chat message", http.MethodPost, "/api/send-notification", "Landlord", `{"fcmToken":"fcm-1","senderId":"landlord-1"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if strings.Contains(path, "%d") {
+				path = fmt.Sprintf(path, newApartment(t))
+			}
+			if got := do(t, tt.method, path, tokens[tt.role], tt.body); got != tt.want {
+				t.Errorf("%s as %q: got %d, want %d", tt.method, tt.role, got, tt.want)
+			}
+		})
+	}
+
+	// The admin's delete is kept in the moderation history
+	var deleted []model.ModerationEvent
+	db.Where("to_status = ?", "Deleted").Find(&deleted)
+	if len(deleted) != 1 || deleted[0].ActorUID != "admin-1" || deleted[0].FromStatus != "Pending" {
+		t.Errorf("got Deleted events %+v, want one by admin-1 from Pending", deleted)
+	}
+}
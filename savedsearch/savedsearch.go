@@ -0,0 +1,177 @@
+// Package savedsearch matches listings that are approved or become available
+// again against tenants' saved searches and sends the alerts, either right
+// away or as a daily digest.
+package savedsearch
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Conding-Student/backend/config"
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	FrequencyInstant = "instant"
+	FrequencyDaily   = "daily"
+)
+
+// Filter returns the listing filter s was saved with.
+func Filter(s model.SavedSearch) repository.ListingFilter {
+	values, _ := url.ParseQuery(s.Filters)
+	return repository.ParseListingFilter(values.Get)
+}
+
+// Query narrows a query on apartments to the listings s matches.
+func Query(db *gorm.DB, s model.SavedSearch) *gorm.DB {
+	db = Filter(s).Apply(db)
+	if s.Latitude != nil && s.Longitude != nil && s.RadiusKm != nil {
+		db = db.Where(repository.HaversineSQL+" <= ?", *s.Latitude, *s.Latitude, *s.Longitude, *s.RadiusKm)
+	}
+	return db
+}
+
+// matchBatchSize is how many saved searches are checked against a listing
+// in one query.
+const matchBatchSize = 200
+
+// matchingSearches returns the searches apartmentID matches. Each batch of
+// searches is evaluated in a single query, one UNION ALL branch per search.
+func matchingSearches(db *gorm.DB, apartmentID uint, searches []model.SavedSearch) ([]model.SavedSearch, error) {
+	var matched []model.SavedSearch
+	for start := 0; start < len(searches); start += matchBatchSize {
+		batch := searches[start:min(start+matchBatchSize, len(searches))]
+		byID := make(map[uint]model.SavedSearch, len(batch))
+		branches := make([]interface{}, len(batch))
+		for i, s := range batch {
+			byID[s.ID] = s
+			branches[i] = Query(db.Model(&model.Apartment{}).
+				Select(fmt.Sprintf("%d AS saved_search_id", s.ID)).
+				Where("apartments.id = ?", apartmentID), s)
+		}
+
+		var ids []uint
+		union := strings.TrimSuffix(strings.Repeat("(?) UNION ALL ", len(batch)), " UNION ALL ")
+		if err := db.Raw(union, branches...).Scan(&ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			matched = append(matched, byID[id])
+		}
+	}
+	return matched, nil
+}
+
+// NotifyListing records which active saved searches an approved, available
+// listing matches. Instant searches are alerted straight away; daily ones
+// wait for the digest. A listing alerts each search only once, however often
+// it is relisted. Run it in a goroutine after the listing is updated.
+func NotifyListing(apartmentID uint) {
+	now := time.Now()
+
+	var apt model.Apartment
+	if err := middleware.DBConn.First(&apt, apartmentID).Error; err != nil {
+		fmt.Printf("[%s] Saved search alert: apartment %d not found: %v\n", now.Format(time.RFC3339), apartmentID, err)
+		return
+	}
+	if apt.Status != "Approved" || apt.Availability != "Available" {
+		return
+	}
+
+	var searches []model.SavedSearch
+	if err := middleware.DBConn.Where("paused = ?", false).Find(&searches).Error; err != nil {
+		fmt.Printf("[%s] Error loading saved searches: %v\n", now.Format(time.RFC3339), err)
+		return
+	}
+
+	matched, err := matchingSearches(middleware.DBConn, apt.ID, searches)
+	if err != nil {
+		fmt.Printf("[%s] Error matching saved searches against apartment %d: %v\n", now.Format(time.RFC3339), apt.ID, err)
+		return
+	}
+
+	alerted := 0
+	for _, s := range matched {
+		match := model.SavedSearchMatch{SavedSearchID: s.ID, ApartmentID: apt.ID}
+		result := middleware.DBConn.Clauses(clause.OnConflict{DoNothing: true}).Create(&match)
+		if result.Error != nil {
+			fmt.Printf("[%s] Error recording saved search match %d/%d: %v\n", now.Format(time.RFC3339), s.ID, apt.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 || s.Frequency != FrequencyInstant {
+			continue
+		}
+
+		title := fmt.Sprintf("New listing for \"%s\"", s.Name)
+		body := fmt.Sprintf("%s · ₱%.0f/month · %s", apt.PropertyName, apt.RentPrice, apt.Address)
+		if err := config.SendPushToUser(s.TenantUID, title, body); err != nil {
+			fmt.Printf("[%s] Error alerting saved search %d: %v\n", now.Format(time.RFC3339), s.ID, err)
+			continue
+		}
+		middleware.DBConn.Model(&match).Update("notified_at", now)
+		alerted++
+	}
+
+	if alerted > 0 {
+		fmt.Printf("[%s] Alerted %d saved searches about apartment %d\n", now.Format(time.RFC3339), alerted, apt.ID)
+	}
+}
+
+// RunDigest sends each daily saved search one push summing up the listings
+// it matched since its last digest, at most once a day.
+func RunDigest() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		sendDigests(time.Now())
+	}
+}
+
+func sendDigests(now time.Time) {
+	var searches []model.SavedSearch
+	if err := middleware.DBConn.
+		Where("paused = ? AND frequency = ?", false, FrequencyDaily).
+		Where("last_digest_at IS NULL OR last_digest_at <= ?", now.Add(-24*time.Hour)).
+		Find(&searches).Error; err != nil {
+		fmt.Printf("[%s] Error loading saved search digests: %v\n", now.Format(time.RFC3339), err)
+		return
+	}
+
+	sent := 0
+	for _, s := range searches {
+		var matchIDs []uint
+		if err := middleware.DBConn.Model(&model.SavedSearchMatch{}).
+			Where("saved_search_id = ? AND notified_at IS NULL", s.ID).
+			Pluck("id", &matchIDs).Error; err != nil {
+			fmt.Printf("[%s] Error loading matches of saved search %d: %v\n", now.Format(time.RFC3339), s.ID, err)
+			continue
+		}
+		if len(matchIDs) == 0 {
+			continue
+		}
+
+		title := fmt.Sprintf("1 new listing for \"%s\"", s.Name)
+		if len(matchIDs) > 1 {
+			title = fmt.Sprintf("%d new listings for \"%s\"", len(matchIDs), s.Name)
+		}
+		if err := config.SendPushToUser(s.TenantUID, title, "Open the app to see today's matches."); err != nil {
+			fmt.Printf("[%s] Error sending digest for saved search %d: %v\n", now.Format(time.RFC3339), s.ID, err)
+			continue
+		}
+
+		middleware.DBConn.Model(&model.SavedSearchMatch{}).Where("id IN ?", matchIDs).Update("notified_at", now)
+		middleware.DBConn.Model(&s).Update("last_digest_at", now)
+		sent++
+	}
+
+	fmt.Printf("[%s] Sent %d saved search digests\n", now.Format(time.RFC3339), sent)
+}
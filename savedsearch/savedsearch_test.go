@@ -0,0 +1,62 @@
+package savedsearch
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/testdb"
+)
+
+func TestMatchingSearches(t *testing.T) {
+	db := testdb.Open(t)
+
+	apartment := model.Apartment{
+		Uid: "landlord-1", UserID: "landlord-1", PropertyName: "Alert Flats", Address: "6 Test St",
+		PropertyType: "Apartment", RentPrice: 5000, LocationLink: "-", Landmarks: "-",
+		Allowed_Gender: "Female", Status: "Approved", Availability: "Available",
+	}
+	if err := db.Create(&apartment).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	filters := []struct {
+		query string
+		match bool
+	}{
+		{"", true},
+		{"max_price=6000&property_types=Apartment", true},
+		{"max_price=4000", false},
+		{"allowed_genders=Male", false},
+		{"property_types=Boarding House,Apartment&allowed_genders=Female", true},
+	}
+	// More searches than one batch, so the batches are stitched together
+	var searches []model.SavedSearch
+	for i := range matchBatchSize + len(filters) {
+		searches = append(searches, model.SavedSearch{
+			TenantUID: "tenant-1", Name: fmt.Sprintf("search %d", i), Filters: filters[i%len(filters)].query,
+		})
+	}
+	if err := db.Create(&searches).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	matched, err := matchingSearches(db, apartment.ID, searches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got []uint
+	for i, s := range searches {
+		if filters[i%len(filters)].match {
+			want = append(want, s.ID)
+		}
+	}
+	for _, s := range matched {
+		got = append(got, s.ID)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("matched %d searches %v, want %d %v", len(got), got, len(want), want)
+	}
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidToken        = errors.New("invalid or expired token")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, session revoked")
+	ErrAccountDisabled     = errors.New("account no longer exists or is disabled, session revoked")
+)
+
+// Tokens is the process-wide token service, set up in main once the
+// environment is loaded.
+var Tokens *TokenService
+
+// TokenService issues short-lived access tokens and rotating refresh tokens
+// for both users and admins.
+//
+// Access tokens are HS256 JWTs whose "kid" header names the signing key, so
+// new keys can be added to JWT_SIGNING_KEYS and made active without
+// invalidating tokens signed with the previous one. Refresh tokens are
+// opaque random strings; only their SHA-256 hash is stored. Every refresh
+// revokes the presented token and issues a new one in the same family, and
+// presenting an already revoked token revokes the whole family.
+type TokenService struct {
+	DB         *gorm.DB
+	Keys       map[string][]byte // kid -> HMAC secret
+	ActiveKID  string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// TokenPair is what login and refresh endpoints hand back to clients.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+// NewTokenServiceFromEnv reads signing keys from JWT_SIGNING_KEYS
+// ("kid1:secret1,kid2:secret2") and the key to sign with from JWT_ACTIVE_KID.
+// A single JWT_SECRET is accepted as key "default" when no key list is set.
+func NewTokenServiceFromEnv(db *gorm.DB) (*TokenService, error) {
+	keys := map[string][]byte{}
+	for _, pair := range strings.Split(os.Getenv("JWT_SIGNING_KEYS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(pair, ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("malformed JWT_SIGNING_KEYS entry %q", pair)
+		}
+		keys[kid] = []byte(secret)
+	}
+
+	activeKID := os.Getenv("JWT_ACTIVE_KID")
+	if len(keys) == 0 {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, errors.New("no JWT signing key configured (set JWT_SIGNING_KEYS or JWT_SECRET)")
+		}
+		keys["default"] = []byte(secret)
+		activeKID = "default"
+	}
+	if activeKID == "" && len(keys) == 1 {
+		for kid := range keys {
+			activeKID = kid
+		}
+	}
+	if _, ok := keys[activeKID]; !ok {
+		return nil, fmt.Errorf("JWT_ACTIVE_KID %q is not one of the configured signing keys", activeKID)
+	}
+
+	return &TokenService{
+		DB:         db,
+		Keys:       keys,
+		ActiveKID:  activeKID,
+		AccessTTL:  durationFromEnv("JWT_ACCESS_TTL", 15*time.Minute),
+		RefreshTTL: durationFromEnv("JWT_REFRESH_TTL", 30*24*time.Hour),
+	}, nil
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// IssuePair starts a new refresh token family, e.g. on login.
+func (s *TokenService) IssuePair(uid, email, role string) (*TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issuePair(s.DB, familyID, uid, email, role)
+}
+
+// Refresh rotates a refresh token and returns a fresh pair. Reuse of a
+// rotated token is treated as theft and revokes every token in its family,
+// as does refreshing for a user or admin that was deleted since login.
+func (s *TokenService) Refresh(rawRefreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	var revokedWith error
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var current model.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(rawRefreshToken)).
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			revokedWith = ErrRefreshTokenReused
+			return revokeFamily(tx, current.FamilyID)
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		// Pick up role changes (e.g. a tenant approved as landlord) on refresh,
+		// and end the session of an account that is gone
+		role, active, err := currentRole(tx, current.UID, current.Role)
+		if err != nil {
+			return err
+		}
+		if !active {
+			revokedWith = ErrAccountDisabled
+			return revokeFamily(tx, current.FamilyID)
+		}
+
+		if err := tx.Model(&current).Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		pair, err = s.issuePair(tx, current.FamilyID, current.UID, current.Email, role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revokedWith != nil {
+		return nil, revokedWith
+	}
+	return pair, nil
+}
+
+// currentRole looks up the account a refresh token was issued to. active is
+// false when the user or admin no longer exists or the user was deleted.
+func currentRole(db *gorm.DB, uid, role string) (string, bool, error) {
+	if role == "Admin" {
+		query := db.Model(&model.Admins{}).Where("uid = ?", uid)
+		// Admins without a uid get tokens for "admin-<id>"
+		if suffix, ok := strings.CutPrefix(uid, "admin-"); ok {
+			if id, err := strconv.Atoi(suffix); err == nil {
+				query = query.Or("id = ? AND (uid IS NULL OR uid = '')", id)
+			}
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return "", false, err
+		}
+		return role, count > 0, nil
+	}
+
+	var user model.User
+	if err := db.Select("user_type, account_status").Where("uid = ?", uid).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if user.AccountStatus == "Deleted" {
+		return "", false, nil
+	}
+	if user.UserType != "" {
+		role = user.UserType
+	}
+	return role, true, nil
+}
+
+// Revoke ends the session the refresh token belongs to by revoking its
+// whole family. Unknown tokens are ignored so logout is idempotent.
+func (s *TokenService) Revoke(rawRefreshToken string) error {
+	var current model.RefreshToken
+	if err := s.DB.Where("token_hash = ?", hashToken(rawRefreshToken)).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return revokeFamily(s.DB, current.FamilyID)
+}
+
+// ParseAccessToken validates an access token and returns its claims in the
+// jwt.MapClaims form handlers read from c.Locals("user").
+func (s *TokenService) ParseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if typ, _ := claims["typ"].(string); typ != "access" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *TokenService) issuePair(db *gorm.DB, familyID, uid, email, role string) (*TokenPair, error) {
+	accessToken, err := s.signAccessToken(uid, email, role)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := model.RefreshToken{
+		FamilyID:  familyID,
+		UID:       uid,
+		Email:     email,
+		Role:      role,
+		TokenHash: hashToken(rawRefresh),
+		ExpiresAt: time.Now().Add(s.RefreshTTL),
+	}
+	if err := db.Create(&refresh).Error; err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawRefresh,
+		ExpiresIn:    int64(s.AccessTTL.Seconds()),
+	}, nil
+}
+
+func (s *TokenService) signAccessToken(uid, email, role string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"uid":   uid,
+		"email": email,
+		"role":  role,
+		"typ":   "access",
+		"iat":   now.Unix(),
+		"exp":   now.Add(s.AccessTTL).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = s.ActiveKID
+	return token.SignedString(s.Keys[s.ActiveKID])
+}
+
+// RevokeUser ends every session of uid, e.g. when the account is deleted.
+// Pass the transaction that deletes the account so both happen together.
+func RevokeUser(db *gorm.DB, uid string) error {
+	return db.Model(&model.RefreshToken{}).
+		Where("uid = ? AND revoked_at IS NULL", uid).
+		Update("revoked_at", time.Now()).Error
+}
+
+func revokeFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,216 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/service"
+	"github.com/Conding-Student/backend/testdb"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+func newService(db *gorm.DB) *service.TokenService {
+	return &service.TokenService{
+		DB:         db,
+		Keys:       map[string][]byte{"k1": []byte("first-secret"), "k2": []byte("second-secret")},
+		ActiveKID:  "k1",
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	}
+}
+
+// signed returns an access token signed with secret under kid.
+func signed(t *testing.T, kid string, secret []byte, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestParseAccessTokenKeyRotation(t *testing.T) {
+	svc := newService(nil)
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{"uid": "tenant-1", "role": "Tenant", "typ": "access", "exp": time.Now().Add(time.Minute).Unix()}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"active key", signed(t, "k1", []byte("first-secret"), claims()), true},
+		{"previous key still configured", signed(t, "k2", []byte("second-secret"), claims()), true},
+		{"unknown kid", signed(t, "k3", []byte("first-secret"), claims()), false},
+		{"kid with the wrong secret", signed(t, "k2", []byte("first-secret"), claims()), false},
+		{"expired", signed(t, "k1", []byte("first-secret"), jwt.MapClaims{"uid": "tenant-1", "typ": "access", "exp": time.Now().Add(-time.Minute).Unix()}), false},
+		{"refresh typ", signed(t, "k1", []byte("first-secret"), jwt.MapClaims{"uid": "tenant-1", "typ": "refresh", "exp": time.Now().Add(time.Minute).Unix()}), false},
+		{"no expiry", signed(t, "k1", []byte("first-secret"), jwt.MapClaims{"uid": "tenant-1", "typ": "access"}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ParseAccessToken(tt.token)
+			if tt.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, service.ErrInvalidToken) {
+				t.Fatalf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	// Switching the active key keeps earlier tokens valid until k1 is removed
+	old := signed(t, "k1", []byte("first-secret"), claims())
+	svc.ActiveKID = "k2"
+	if _, err := svc.ParseAccessToken(old); err != nil {
+		t.Errorf("token signed with the previous active key: %v", err)
+	}
+	delete(svc.Keys, "k1")
+	if _, err := svc.ParseAccessToken(old); !errors.Is(err, service.ErrInvalidToken) {
+		t.Errorf("token signed with a removed key: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func seedUser(t *testing.T, db *gorm.DB, uid, userType, status string) {
+	t.Helper()
+	user := model.User{Uid: uid, Email: uid + "@example.com", UserType: userType, Provider: "email", AccountStatus: status}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshRotates(t *testing.T) {
+	db := testdb.Open(t)
+	seedUser(t, db, "tenant-1", "Tenant", "Verified")
+	svc := newService(db)
+
+	first, err := svc.IssuePair("tenant-1", "tenant-1@example.com", "Tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh returned the same refresh token")
+	}
+	claims, err := svc.ParseAccessToken(second.AccessToken)
+	if err != nil || claims["uid"] != "tenant-1" || claims["role"] != "Tenant" {
+		t.Fatalf("new access token: claims %v, err %v", claims, err)
+	}
+
+	// The role is re-read on refresh
+	db.Model(&model.User{}).Where("uid = ?", "tenant-1").Update("user_type", "Landlord")
+	third, err := svc.Refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, _ := svc.ParseAccessToken(third.AccessToken); claims["role"] != "Landlord" {
+		t.Errorf("role after refresh is %v, want Landlord", claims["role"])
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	db := testdb.Open(t)
+	seedUser(t, db, "tenant-1", "Tenant", "Verified")
+	svc := newService(db)
+
+	first, _ := svc.IssuePair("tenant-1", "tenant-1@example.com", "Tenant")
+	other, _ := svc.IssuePair("tenant-1", "tenant-1@example.com", "Tenant") // another device
+	second, err := svc.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Refresh(first.RefreshToken); !errors.Is(err, service.ErrRefreshTokenReused) {
+		t.Fatalf("replaying a rotated token: got %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := svc.Refresh(second.RefreshToken); !errors.Is(err, service.ErrRefreshTokenReused) {
+		t.Errorf("latest token of the revoked family: got %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := svc.Refresh(other.RefreshToken); err != nil {
+		t.Errorf("another family must not be revoked: %v", err)
+	}
+}
+
+func TestRefreshExpired(t *testing.T) {
+	db := testdb.Open(t)
+	seedUser(t, db, "tenant-1", "Tenant", "Verified")
+	svc := newService(db)
+	svc.RefreshTTL = -time.Second
+
+	pair, err := svc.IssuePair("tenant-1", "tenant-1@example.com", "Tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Refresh(pair.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Fatalf("got %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := svc.Refresh("not-a-token"); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Fatalf("unknown token: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshRevokesGoneAccounts(t *testing.T) {
+	db := testdb.Open(t)
+	seedUser(t, db, "tenant-1", "Tenant", "Verified")
+	seedUser(t, db, "tenant-2", "Tenant", "Verified")
+	admin := model.Admins{Email: "admin@example.com", Password: "-"}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	svc := newService(db)
+
+	deleted, _ := svc.IssuePair("tenant-1", "tenant-1@example.com", "Tenant")
+	missing, _ := svc.IssuePair("tenant-2", "tenant-2@example.com", "Tenant")
+	adminUID := fmt.Sprintf("admin-%d", admin.ID)
+	adminPair, _ := svc.IssuePair(adminUID, admin.Email, "Admin")
+
+	// Admins still on file keep refreshing
+	adminPair, err := svc.Refresh(adminPair.RefreshToken)
+	if err != nil {
+		t.Fatalf("existing admin: %v", err)
+	}
+
+	db.Model(&model.User{}).Where("uid = ?", "tenant-1").Update("account_status", "Deleted")
+	db.Where("uid = ?", "tenant-2").Delete(&model.User{})
+	db.Delete(&admin)
+
+	for name, pair := range map[string]*service.TokenPair{"deleted user": deleted, "missing user": missing, "missing admin": adminPair} {
+		if _, err := svc.Refresh(pair.RefreshToken); !errors.Is(err, service.ErrAccountDisabled) {
+			t.Errorf("%s: got %v, want ErrAccountDisabled", name, err)
+		}
+	}
+
+	var live int64
+	db.Model(&model.RefreshToken{}).Where("revoked_at IS NULL").Count(&live)
+	if live != 0 {
+		t.Errorf("%d refresh tokens still live after their accounts went away", live)
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	db := testdb.Open(t)
+	seedUser(t, db, "tenant-1", "Tenant", "Verified")
+	svc := newService(db)
+
+	phone, _ := svc.IssuePair("tenant-1", "tenant-1@example.com", "Tenant")
+	laptop, _ := svc.IssuePair("tenant-1", "tenant-1@example.com", "Tenant")
+	if err := service.RevokeUser(db, "tenant-1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range []*service.TokenPair{phone, laptop} {
+		if _, err := svc.Refresh(pair.RefreshToken); err == nil {
+			t.Error("a session survived RevokeUser")
+		}
+	}
+}
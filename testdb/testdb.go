@@ -0,0 +1,104 @@
+// Package testdb connects tests to a throwaway Postgres database. Point
+// TEST_DATABASE_URL at an empty database, e.g.
+//
+//	TEST_DATABASE_URL="host=localhost user=postgres password=postgres dbname=rentxpert_test sslmode=disable" go test ./...
+//
+// Tests that need it are skipped when it is not set. Every table is emptied
+// each time a test opens the database, so do not point it at real data.
+package testdb
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conding-Student/backend/middleware"
+	"github.com/Conding-Student/backend/model"
+	"github.com/Conding-Student/backend/service"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	once    sync.Once
+	shared  *gorm.DB
+	openErr error
+)
+
+// Open returns the test database with every table emptied and installs it as
+// middleware.DBConn, which most handlers use. The schema is created on first
+// use: the tables production already had, then middleware.Migrate.
+func Open(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	once.Do(func() {
+		shared, openErr = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if openErr != nil {
+			return
+		}
+		// middleware.Migrate leaves these to the existing production schema
+		openErr = shared.AutoMigrate(
+			&model.User{},
+			&model.Admins{},
+			&model.AdminToken{},
+			&model.Apartment{},
+			&model.LandlordProfile{},
+			&model.ApartmentImage{},
+			&model.ApartmentVideo{},
+			&model.Inquiry{},
+			&model.Amenity{},
+			&model.ApartmentAmenity{},
+			&model.HouseRule{},
+			&model.ApartmentHouseRule{},
+			&model.Wishlist{},
+			&model.RecentlyViewed{},
+			&model.RentalAgreement{},
+			&model.Rating{},
+			&model.Transaction{},
+		)
+		if openErr == nil {
+			openErr = middleware.Migrate(shared)
+		}
+	})
+	if openErr != nil {
+		tb.Fatalf("testdb: %v", openErr)
+	}
+
+	if err := shared.Exec(`DO $$ DECLARE t text; BEGIN
+		FOR t IN SELECT tablename FROM pg_tables WHERE schemaname = current_schema() LOOP
+			EXECUTE format('TRUNCATE TABLE %I RESTART IDENTITY CASCADE', t);
+		END LOOP; END $$`).Error; err != nil {
+		tb.Fatalf("testdb: emptying tables: %v", err)
+	}
+
+	middleware.DBConn = shared
+	return shared
+}
+
+// Token installs a token service with a fixed test key as service.Tokens,
+// which AuthMiddleware verifies against, and returns a bearer token for uid
+// with the given role. Call Open first; the refresh token is stored there.
+func Token(tb testing.TB, uid, role string) string {
+	tb.Helper()
+
+	service.Tokens = &service.TokenService{
+		DB:         shared,
+		Keys:       map[string][]byte{"test": []byte("testdb-signing-key")},
+		ActiveKID:  "test",
+		AccessTTL:  time.Hour,
+		RefreshTTL: time.Hour,
+	}
+	pair, err := service.Tokens.IssuePair(uid, uid+"@example.com", role)
+	if err != nil {
+		tb.Fatalf("testdb: issuing token: %v", err)
+	}
+	return "Bearer " + pair.AccessToken
+}